@@ -16,20 +16,50 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/onsi/ginkgo"
+	ginkgoconfig "github.com/onsi/ginkgo/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"k8s.io/klog/v2"
 
 	"github.com/kubernetes-csi/csi-test/v4/pkg/sanity"
+	"github.com/kubernetes-csi/csi-test/v4/pkg/sanity/channelz"
+	"github.com/kubernetes-csi/csi-test/v4/utils"
 )
 
 const (
 	prefix string = "csi."
+
+	// envPrefix is prepended to the flag name in upper case, with
+	// dots and dashes replaced by underscores, to get the
+	// environment variable for a flag. "csi." is stripped, so
+	// --csi.endpoint becomes CSI_SANITY_ENDPOINT and --ginkgo.focus
+	// becomes CSI_SANITY_GINKGO_FOCUS.
+	envPrefix string = "CSI_SANITY_"
+	// fileSuffix is appended to the environment variable name to
+	// read the value from a file instead, for example one that was
+	// mounted from a ConfigMap or Secret.
+	fileSuffix string = "_FILE"
+)
+
+// Exit codes.
+const (
+	exitSucceeded         = 0
+	exitTestsFailed       = 1
+	exitInvalidConfig     = 2
+	exitDriverUnavailable = 3
+	exitReportFailed      = 4
 )
 
 var (
@@ -52,10 +82,109 @@ func int64Var(p *int64, name string, usage string) {
 	flag.Int64Var(p, prefix+name, *p, usage)
 }
 
+func float64Var(p *float64, name string, usage string) {
+	flag.Float64Var(p, prefix+name, *p, usage)
+}
+
 func durationVar(p *time.Duration, name string, usage string) {
 	flag.DurationVar(p, prefix+name, *p, usage)
 }
 
+// parseCode returns the gRPC status code with the given name, for
+// example Unavailable.
+func parseCode(name string) (codes.Code, bool) {
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		if strings.EqualFold(code.String(), strings.TrimSpace(name)) {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// configFileFromArgs returns the value of --csi.config from the
+// command line or, if not given there, from the environment.
+func configFileFromArgs(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if name == prefix+"config" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, prefix+"config=") {
+			return strings.TrimPrefix(name, prefix+"config=")
+		}
+	}
+	return os.Getenv(envPrefix + "CONFIG")
+}
+
+// setFlagsFromEnv sets all flags for which an environment variable
+// is set. Command line flags are parsed later and take precedence.
+func setFlagsFromEnv() error {
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(strings.TrimPrefix(f.Name, prefix)))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			path, ok := os.LookupEnv(name + fileSuffix)
+			if !ok {
+				return
+			}
+			content, readErr := ioutil.ReadFile(path)
+			if readErr != nil {
+				err = fmt.Errorf("%s%s: %v", name, fileSuffix, readErr)
+				return
+			}
+			value = strings.TrimSpace(string(content))
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("%s: %v", name, setErr)
+		}
+	})
+	return err
+}
+
+// writeResults stores the results and the channelz snapshots as JSON
+// and the conformance certificate as plain text in the given
+// directory.
+func writeResults(dir string, results *sanity.Results) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "results.json"), data, 0644); err != nil {
+		return err
+	}
+	for i, diagnostic := range results.ConnectionDiagnostics {
+		data, err := json.MarshalIndent(diagnostic, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("channelz-%d.json", i+1)), data, 0644); err != nil {
+			return err
+		}
+	}
+	coverage := results.Coverage()
+	data, err = json.MarshalIndent(coverage, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "coverage.json"), data, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "coverage.txt"), []byte(coverage.String()), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "certificate.txt"), []byte(results.Certificate(VERSION)), 0644)
+}
+
 type testing struct {
 	result int
 }
@@ -67,8 +196,20 @@ func (t *testing) Fail() {
 func main() {
 	version := flag.Bool("version", false, "print version of this program")
 
-	// Get configuration with defaults.
+	// Get configuration with defaults, modified by the config file.
+	// The file must be loaded before defining the flags because
+	// their defaults come from the config.
+	configFile := configFileFromArgs(os.Args[1:])
 	config := sanity.NewTestConfig()
+	if configFile != "" {
+		var err error
+		if config, err = sanity.LoadConfig(configFile); err != nil {
+			fmt.Printf("--%sconfig: %v\n", prefix, err)
+			os.Exit(exitInvalidConfig)
+		}
+	}
+	config.SuiteVersion = VERSION
+	stringVar(&configFile, "config", "YAML or JSON file with TestConfig fields (see sanity.LoadConfig), overridden by other flags and environment variables")
 
 	// Support overriding the default configuration via flags.
 	stringVar(&config.Address, "endpoint", "CSI endpoint")
@@ -83,15 +224,97 @@ func main() {
 	durationVar(&config.RemovePathCmdTimeout, "removepathcmdtimeout", "Timeout for the commands to remove target and staging paths, in seconds")
 	stringVar(&config.CheckPathCmd, "checkpathcmd", "Command to run to check a given path. It must print 'file', 'directory', 'not_found', or 'other' on stdout.")
 	durationVar(&config.CheckPathCmdTimeout, "checkpathcmdtimeout", "Timeout for the command to check a given path, in seconds")
+	stringVar(&config.QuiesceCmd, "quiescecmd", "Command to run with the path of a published volume before creating a snapshot of it")
+	stringVar(&config.UnquiesceCmd, "unquiescecmd", "Command to run with the path of a published volume after creating a snapshot of it")
+	durationVar(&config.QuiesceCmdTimeout, "quiescecmdtimeout", "Timeout for the quiesce and unquiesce commands, in seconds")
+	stringVar(&config.WriteDataCmd, "writedatacmd", "Command to run with the path of a published volume to write the data passed on stdin into it, enables the data integrity test together with -csi.readdatacmd")
+	stringVar(&config.ReadDataCmd, "readdatacmd", "Command to run with the path of a published volume to print the data written by -csi.writedatacmd")
+	durationVar(&config.DataCmdTimeout, "datacmdtimeout", "Timeout for the write and read data commands, in seconds")
 	stringVar(&config.SecretsFile, "secrets", "CSI secrets file")
+	boolVar(&config.ExpandSecrets, "expandsecrets", "Replace ${VAR} with environment variables and execute template functions like {{ file \"/path\" }} in the values of the secrets files")
+	stringVar(&config.MetadataFile, "metadatafile", "YAML file with gRPC metadata (for example an authorization header) which gets attached to every call")
+	stringVar(&config.BadSecretsFile, "badsecrets", "CSI secrets file with wrong credentials which the driver must reject")
 	stringVar(&config.TestVolumeAccessType, "testvolumeaccesstype", "Volume capability access type, valid values are mount or block")
+	accessModes := ""
+	stringVar(&accessModes, "testvolumeaccessmodes", "Comma-separated list of access modes like MULTI_NODE_READER_ONLY for the volumes created by the tests, SINGLE_NODE_WRITER if empty")
+	stringVar(&config.TestVolumeNamePrefix, "testvolumenameprefix", "Prefix for the names of all volumes created by the tests")
+	stringVar(&config.RunID, "runid", "ID which gets appended to the names of all volumes and snapshots created by the tests")
+	stringVar(&config.RunIDParameter, "runidparameter", "Parameter key under which --csi.runid is passed to CreateVolume and CreateSnapshot")
+	cleanupRun := flag.Bool(prefix+"cleanuprun", false, "Delete the volumes and snapshots of an aborted run with --csi.runid instead of running the tests")
 	int64Var(&config.TestVolumeSize, "testvolumesize", "Base volume size used for provisioned volumes")
 	int64Var(&config.TestVolumeExpandSize, "testvolumeexpandsize", "Target size for expanded volumes")
+	boolVar(&config.NoDefaultCapacity, "nodefaultcapacity", "The driver has no default volume size, so CreateVolume without required and limit bytes must fail")
+	float64Var(&config.CapacityTolerance, "capacitytolerance", "Fraction by which the capacity returned by repeated GetCapacity calls may vary")
+	int64Var(&config.MaxTotalProvisionedBytes, "maxtotalprovisionedbytes", "Maximum total size of all volumes that exist at the same time, 0 for no limit")
 	stringVar(&config.TestVolumeParametersFile, "testvolumeparameters", "YAML file of volume parameters for provisioned volumes")
 	stringVar(&config.TestSnapshotParametersFile, "testsnapshotparameters", "YAML file of snapshot parameters for provisioned snapshots")
 	boolVar(&config.TestNodeVolumeAttachLimit, "testnodevolumeattachlimit", "Test node volume attach limit")
 	stringVar(&config.JUnitFile, "junitfile", "JUnit XML output file where test results will be written")
+	stringVar(&config.JSONReportFile, "jsonreportfile", "JSON output file with name, state, duration, failure and last gRPC error code of each test")
+	stringVar(&config.TAPFile, "tapfile", "TAP (Test Anything Protocol) output file where test results will be written")
+	stringVar(&config.HTMLReportFile, "htmlreportfile", "HTML output file with a single-page conformance report")
+	stringVar(&config.LatencyReportFile, "latencyreportfile", "File where the p50/p95/max latency per gRPC method will be written")
+	boolVar(&config.DumpMessages, "dumpmessages", "Show each gRPC request and response (secrets masked) in the output of the tests")
+	stringVar(&config.ArtifactsDir, "artifactsdir", "Directory which gets the recent gRPC calls and a listing of the target and staging paths for each failed test")
+	intVar(&config.ArtifactRPCs, "artifactrpcs", "Number of recent gRPC calls stored for each failed test, 20 if zero")
+	stringVar(&config.RecordTrafficFile, "recordtrafficfile", "File where every gRPC call with request and response (secrets redacted) gets written as one JSON line")
+	captureChannelz := flag.Bool(prefix+"channelz", false, "Capture the gRPC channelz state when a call fails with Unavailable or DeadlineExceeded and write it to channelz-<n>.json in --csi.resultsdir")
+	resultsDir := ""
+	stringVar(&resultsDir, "resultsdir", "Directory where results.json, certificate.txt and, unless --csi.junitfile is set, junit.xml will be written")
+	boolVar(&config.FailFast, "failfast", "Skip all remaining tests when the driver fails Probe, GetPluginInfo or the capability calls")
+	boolVar(&config.IdentityOnly, "identityonly", "Only run the Identity Service and endpoint tests, for drivers which implement nothing else yet")
+	stringVar(&config.PauseBetweenAreas, "pausebetweenareas", "File which must be created to continue the tests after switching to a different area, for example after replacing the driver; a volume created before the first pause must survive all pauses")
+	durationVar(&config.InterruptTimeout, "interrupttimeout", "Time to wait after SIGINT or SIGTERM for the current test to finish before cleaning up its volumes, snapshots and mounts, 0 to clean up immediately")
+	durationVar(&config.PauseTimeout, "pausetimeout", "Maximum time to wait for the --csi.pausebetweenareas file, 0 for no limit")
+	stringVar(&config.SpecGroupsFile, "specgroupsfile", "YAML file which maps spec IDs to lists of groups that get added to the spec names as [group:<name>]")
+	int64Var(&config.RandomSeed, "randomseed", "Seed for the order of the tests and the generated names and IDs, printed at the start of each run for repeating it")
+	boolVar(&config.DryRun, "dryrun", "List which tests would run and which would be skipped, without calls that modify the driver state")
+	boolVar(&config.StrictCapabilities, "strictcapabilities", "Fail instead of skipping tests which need one of the required capabilities")
+	stringVar(&config.RequiredCapabilitiesFile, "requiredcapabilitiesfile", "YAML file with a list of capabilities which the driver must support, enables strict mode")
+	durationVar(&config.ProbeTimeout, "probetimeout", "Timeout for the driver to become ready in the Probe test")
+	durationVar(&config.RPCTimeout, "rpctimeout", "Timeout for each gRPC call made by the tests, 0 disables it")
+	stringVar(&config.VendorVersionPattern, "vendorversionpattern", "Regular expression that the vendor version returned by GetPluginInfo must match")
+	stringVar(&config.MigratedInTreePluginName, "migratedintreepluginname", "Name of the in-tree volume plugin (for example kubernetes.io/gce-pd) which the driver replaces with CSI migration")
+	manifestKeys := ""
+	stringVar(&manifestKeys, "manifestkeys", "Comma-separated list of keys that the manifest returned by GetPluginInfo must contain")
+	stringVar(&config.CallbackListenAddress, "callbacklistenaddress", "Address (for example :8080) on which to receive HTTP callbacks from the driver while publishing a volume")
+	durationVar(&config.CallbackTimeout, "callbacktimeout", "Timeout for receiving a callback from the driver")
+	callbackAllowedIPs := ""
+	stringVar(&callbackAllowedIPs, "callbackallowedips", "Comma-separated list of IP addresses from which callbacks are accepted, all if empty")
+	unimplementedPolicies := ""
+	stringVar(&unimplementedPolicies, "unimplementedpolicies", "Comma-separated list of <RPC>=skip|fail (for example NodeGetVolumeStats=skip) which determines what happens when the driver returns Unimplemented for that RPC")
+	leakCheck := string(config.LeakCheck)
+	stringVar(&leakCheck, "leakcheck", "Check for volumes and snapshots that were not deleted by the tests, valid values are warn or fail")
+	expectedSocketMode := ""
+	stringVar(&expectedSocketMode, "expectedsocketmode", "Permission bits in octal notation (for example 0660) which the unix domain sockets of the driver must have")
+	intVar(&config.RetryPolicy.MaxAttempts, "retrymaxattempts", "Number of attempts for calls which fail with one of --csi.retrycodes, 1 disables retries")
+	durationVar(&config.RetryPolicy.InitialBackoff, "retrybackoff", "Delay before the first retry, 100ms if zero")
+	float64Var(&config.RetryPolicy.Multiplier, "retrymultiplier", "Factor by which the delay grows for each further retry, 2 if zero")
+	durationVar(&config.RetryPolicy.MaxBackoff, "retrymaxbackoff", "Maximum delay between retries, 10s if zero")
+	retryCodes := ""
+	stringVar(&retryCodes, "retrycodes", "Comma-separated list of gRPC status codes (for example Unavailable,DeadlineExceeded) for which calls are retried, Unavailable if empty")
+	specRetries := ""
+	stringVar(&specRetries, "specretries", "Comma-separated list of <spec ID>=<retries> (for example listsnapshots/new-snapshots=2) for specs which are repeated after a failure")
+	resultsDB := ""
+	stringVar(&resultsDB, "resultsdb", "SQLite database to which the results of each run get appended, created if it does not exist")
+	sqliteCmd := "sqlite3"
+	stringVar(&sqliteCmd, "sqlitecmd", "sqlite3 command line tool which is used to write --csi.resultsdb")
+	requiredCapabilities := ""
+	stringVar(&requiredCapabilities, "requiredcapabilities", "Comma-separated list of capabilities like Controller.CREATE_DELETE_SNAPSHOT which the driver must support in strict mode")
+	ssh := sanity.SSHConfig{}
+	if config.SSH != nil {
+		ssh = *config.SSH
+	}
+	stringVar(&ssh.Host, "sshhost", "Node on which target and staging paths get managed through ssh, for drivers tested from another host")
+	intVar(&ssh.Port, "sshport", "Port for --csi.sshhost, the ssh default if zero")
+	stringVar(&ssh.User, "sshuser", "User for --csi.sshhost, the ssh default if empty")
+	stringVar(&ssh.KeyFile, "sshkey", "Private key file for --csi.sshhost")
+	stringVar(&ssh.KnownHostsFile, "sshknownhosts", "known_hosts file for --csi.sshhost")
 
+	if err := setFlagsFromEnv(); err != nil {
+		fmt.Printf("invalid environment variable %v\n", err)
+		os.Exit(exitInvalidConfig)
+	}
 	flag.Parse()
 	if *version {
 		fmt.Printf("Version = %s\n", VERSION)
@@ -99,15 +322,223 @@ func main() {
 	}
 	if config.Address == "" {
 		fmt.Printf("--%sendpoint must be provided with an CSI endpoint\n", prefix)
-		os.Exit(1)
+		os.Exit(exitInvalidConfig)
 	}
 	if at := strings.TrimSpace(strings.ToLower(config.TestVolumeAccessType)); !(at == "mount" || at == "block") {
 		fmt.Printf("--%stestvolumeaccesstype valid values are mount or block\n", prefix)
-		os.Exit(1)
+		os.Exit(exitInvalidConfig)
+	}
+
+	if *captureChannelz {
+		config.ConnectionDiagnostics = channelz.Dump
 	}
 
+	switch mode := sanity.LeakCheckMode(leakCheck); mode {
+	case sanity.LeakCheckDisabled, sanity.LeakCheckWarn, sanity.LeakCheckFail:
+		config.LeakCheck = mode
+	default:
+		fmt.Printf("--%sleakcheck valid values are warn or fail\n", prefix)
+		os.Exit(exitInvalidConfig)
+	}
+	if expectedSocketMode != "" {
+		mode, err := strconv.ParseUint(expectedSocketMode, 8, 32)
+		if err != nil || mode > 0777 {
+			fmt.Printf("--%sexpectedsocketmode must be permission bits in octal notation\n", prefix)
+			os.Exit(exitInvalidConfig)
+		}
+		config.ExpectedSocketMode = os.FileMode(mode)
+	}
+	if manifestKeys != "" {
+		config.ManifestKeys = strings.Split(manifestKeys, ",")
+	}
+	if accessModes != "" {
+		config.TestVolumeAccessModes = nil
+		for _, name := range strings.Split(accessModes, ",") {
+			mode, ok := csi.VolumeCapability_AccessMode_Mode_value[name]
+			if !ok || mode == int32(csi.VolumeCapability_AccessMode_UNKNOWN) {
+				fmt.Printf("--%stestvolumeaccessmodes contains an unknown access mode: %q\n", prefix, name)
+				os.Exit(exitInvalidConfig)
+			}
+			config.TestVolumeAccessModes = append(config.TestVolumeAccessModes, csi.VolumeCapability_AccessMode_Mode(mode))
+		}
+	}
+	if requiredCapabilities != "" {
+		config.RequiredCapabilities = strings.Split(requiredCapabilities, ",")
+	}
+	if unimplementedPolicies != "" {
+		config.UnimplementedPolicies = map[string]sanity.UnimplementedPolicy{}
+		for _, entry := range strings.Split(unimplementedPolicies, ",") {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				fmt.Printf("--%sunimplementedpolicies entries must have the format <RPC>=skip|fail: %q\n", prefix, entry)
+				os.Exit(exitInvalidConfig)
+			}
+			switch policy := sanity.UnimplementedPolicy(parts[1]); policy {
+			case sanity.UnimplementedSkip, sanity.UnimplementedFail:
+				config.UnimplementedPolicies[parts[0]] = policy
+			default:
+				fmt.Printf("--%sunimplementedpolicies valid values are skip or fail: %q\n", prefix, entry)
+				os.Exit(exitInvalidConfig)
+			}
+		}
+	}
+	if config.RetryPolicy.Multiplier != 0 && config.RetryPolicy.Multiplier < 1 {
+		fmt.Printf("--%sretrymultiplier must be at least 1\n", prefix)
+		os.Exit(exitInvalidConfig)
+	}
+	if retryCodes != "" {
+		config.RetryPolicy.RetryableCodes = nil
+		for _, name := range strings.Split(retryCodes, ",") {
+			code, ok := parseCode(name)
+			if !ok {
+				fmt.Printf("--%sretrycodes contains an unknown gRPC status code: %q\n", prefix, name)
+				os.Exit(exitInvalidConfig)
+			}
+			config.RetryPolicy.RetryableCodes = append(config.RetryPolicy.RetryableCodes, code)
+		}
+	}
+	if specRetries != "" {
+		config.SpecRetries = map[string]int{}
+		for _, entry := range strings.Split(specRetries, ",") {
+			// Spec IDs with parameters contain = themselves.
+			separator := strings.LastIndex(entry, "=")
+			retries := -1
+			if separator > 0 {
+				retries, _ = strconv.Atoi(entry[separator+1:])
+			}
+			if retries < 0 {
+				fmt.Printf("--%sspecretries entries must have the format <spec ID>=<retries>: %q\n", prefix, entry)
+				os.Exit(exitInvalidConfig)
+			}
+			config.SpecRetries[entry[:separator]] = retries
+		}
+	}
+	if ssh.Host != "" {
+		config.SSH = &ssh
+	}
+	if callbackAllowedIPs != "" {
+		config.CallbackAllowedIPs = strings.Split(callbackAllowedIPs, ",")
+	}
+
+	if resultsDir != "" {
+		if err := os.MkdirAll(resultsDir, 0755); err != nil {
+			fmt.Printf("creating results directory: %v\n", err)
+			os.Exit(exitReportFailed)
+		}
+		if config.JUnitFile == "" {
+			config.JUnitFile = filepath.Join(resultsDir, "junit.xml")
+		}
+		if config.LatencyReportFile == "" {
+			config.LatencyReportFile = filepath.Join(resultsDir, "latency.txt")
+		}
+		if config.HTMLReportFile == "" {
+			config.HTMLReportFile = filepath.Join(resultsDir, "report.html")
+		}
+		if config.ArtifactsDir == "" {
+			config.ArtifactsDir = filepath.Join(resultsDir, "artifacts")
+		}
+		if config.JSONReportFile == "" {
+			config.JSONReportFile = filepath.Join(resultsDir, "report.json")
+		}
+	}
+
+	// Fail early with a distinct exit code when the driver
+	// cannot be reached, for example because its socket is not
+	// shared correctly with this container.
+	endpoints := map[string][]grpc.DialOption{config.Address: config.DialOptions}
+	if config.ControllerAddress != "" {
+		endpoints[config.ControllerAddress] = config.ControllerDialOptions
+		if len(config.ControllerDialOptions) == 0 {
+			endpoints[config.ControllerAddress] = config.DialOptions
+		}
+	}
+	for address, dialOptions := range endpoints {
+		conn, err := utils.Connect(address, dialOptions...)
+		if err != nil {
+			fmt.Printf("connecting to CSI driver at %s: %v\n", address, err)
+			os.Exit(exitDriverUnavailable)
+		}
+		conn.Close()
+	}
+
+	if *cleanupRun {
+		if config.RunID == "" {
+			fmt.Printf("--%scleanuprun requires --%srunid\n", prefix, prefix)
+			os.Exit(exitInvalidConfig)
+		}
+		os.Exit(runCleanup(config))
+	}
+
+	report := func(results *sanity.Results) {
+		if results.DryRun {
+			fmt.Printf("\nDry run of %d specs:\n%s", len(results.Specs), results.DryRunReport())
+		} else if len(results.RPCs) > 0 {
+			fmt.Printf("\nLatency per gRPC method:\n%s", results.LatencyReport())
+		}
+		fmt.Printf("\n%s", results.Certificate(VERSION))
+		if resultsDir != "" {
+			if err := writeResults(resultsDir, results); err != nil {
+				fmt.Printf("writing results: %v\n", err)
+				os.Exit(exitReportFailed)
+			}
+		}
+		if resultsDB != "" && !results.DryRun {
+			if err := appendResultsDB(resultsDB, sqliteCmd, results, VERSION); err != nil {
+				fmt.Printf("writing results database: %v\n", err)
+				os.Exit(exitReportFailed)
+			}
+		}
+	}
+	// Ginkgo exits by itself after an interrupt, so the partial
+	// results must be reported before that.
+	config.OnInterrupt = report
+
+	// The library only logs the seed, which ends up in the
+	// GinkgoWriter, so print it here where it is always visible.
+	if config.RandomSeed == 0 {
+		config.RandomSeed = ginkgoconfig.GinkgoConfig.RandomSeed
+	}
+	fmt.Printf("Random seed: %d\n", config.RandomSeed)
+
 	klog.SetOutput(ginkgo.GinkgoWriter)
 	t := testing{}
-	sanity.Test(&t, config)
-	os.Exit(t.result)
+	results := sanity.Test(&t, config)
+	report(results)
+	if t.result != 0 {
+		os.Exit(exitTestsFailed)
+	}
+	os.Exit(exitSucceeded)
+}
+
+// runCleanup deletes the resources of the run given by config.RunID
+// and returns the exit code.
+func runCleanup(config sanity.TestConfig) int {
+	secrets, err := config.LoadSecrets()
+	if err != nil {
+		fmt.Printf("loading secrets: %v\n", err)
+		return exitInvalidConfig
+	}
+	address := config.ControllerAddress
+	dialOptions := config.ControllerDialOptions
+	if address == "" {
+		address = config.Address
+	}
+	if len(dialOptions) == 0 {
+		dialOptions = config.DialOptions
+	}
+	conn, err := utils.Connect(address, dialOptions...)
+	if err != nil {
+		fmt.Printf("connecting to CSI driver at %s: %v\n", address, err)
+		return exitDriverUnavailable
+	}
+	defer conn.Close()
+	deleted, err := sanity.CleanupRun(conn, config.RunID, secrets)
+	for _, id := range deleted {
+		fmt.Printf("deleted %s\n", id)
+	}
+	if err != nil {
+		fmt.Printf("cleaning up run %s: %v\n", config.RunID, err)
+		return exitTestsFailed
+	}
+	return exitSucceeded
 }
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	// main.go has its own testing type.
+	gotesting "testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestParseCode(t *gotesting.T) {
+	for name, tc := range map[string]struct {
+		code codes.Code
+		ok   bool
+	}{
+		"OK":                {codes.OK, true},
+		"Unavailable":       {codes.Unavailable, true},
+		"unavailable":       {codes.Unavailable, true},
+		" DeadlineExceeded": {codes.DeadlineExceeded, true},
+		"Unauthenticated":   {codes.Unauthenticated, true},
+		"14":                {0, false},
+		"":                  {0, false},
+		"NoSuchCode":        {0, false},
+	} {
+		code, ok := parseCode(name)
+		if code != tc.code || ok != tc.ok {
+			t.Errorf("%q: expected %s %v, got %s %v", name, tc.code, tc.ok, code, ok)
+		}
+	}
+}
+
+func TestConfigFileFromArgs(t *gotesting.T) {
+	for name, tc := range map[string]struct {
+		args     []string
+		env      string
+		expected string
+	}{
+		"none":           {[]string{"--csi.endpoint", "/csi.sock"}, "", ""},
+		"separate":       {[]string{"--csi.endpoint=/csi.sock", "--csi.config", "a.yaml"}, "", "a.yaml"},
+		"equals":         {[]string{"-csi.config=a.yaml", "--csi.dryrun"}, "", "a.yaml"},
+		"missing value":  {[]string{"--csi.config"}, "", ""},
+		"environment":    {[]string{"--csi.dryrun"}, "b.yaml", "b.yaml"},
+		"flag wins":      {[]string{"--csi.config=a.yaml"}, "b.yaml", "a.yaml"},
+		"after --":       {[]string{"--", "--csi.config=a.yaml"}, "", ""},
+		"value not flag": {[]string{"--csi.endpoint", "csi.config=a.yaml"}, "", ""},
+		"other prefix":   {[]string{"--csi.configfile=a.yaml"}, "", ""},
+	} {
+		t.Run(name, func(t *gotesting.T) {
+			setenv(t, envPrefix+"CONFIG", tc.env)
+			if actual := configFileFromArgs(tc.args); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// setenv sets the environment variable, or unsets it for an empty
+// value, and restores the original state when the test is done.
+// t.Setenv is not available in Go 1.16.
+func setenv(t *gotesting.T, key, value string) {
+	original, set := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(key, original)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+	var err error
+	if value != "" {
+		err = os.Setenv(key, value)
+	} else {
+		err = os.Unsetenv(key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-csi/csi-test/v4/pkg/sanity"
+)
+
+// resultsDBSchema creates the tables of the results database. Each
+// run gets a row in runs, specs and rpcs refer to it with run_id.
+const resultsDBSchema = `CREATE TABLE IF NOT EXISTS runs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  start_time TEXT NOT NULL,
+  duration_seconds REAL NOT NULL,
+  succeeded INTEGER NOT NULL,
+  driver_name TEXT NOT NULL,
+  driver_version TEXT NOT NULL,
+  spec_version TEXT NOT NULL,
+  suite_version TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS specs (
+  run_id INTEGER NOT NULL REFERENCES runs(id),
+  spec_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  area TEXT NOT NULL,
+  state TEXT NOT NULL,
+  duration_seconds REAL NOT NULL,
+  failure TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS rpcs (
+  run_id INTEGER NOT NULL REFERENCES runs(id),
+  method TEXT NOT NULL,
+  calls INTEGER NOT NULL,
+  errors INTEGER NOT NULL,
+  total_duration_seconds REAL NOT NULL
+);
+`
+
+// sqlQuote returns s as an SQL string literal.
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%f", d.Seconds())
+}
+
+// resultsDBScript returns the SQL statements which add the results
+// as one run in a single transaction.
+func resultsDBScript(results *sanity.Results, suiteVersion string) string {
+	var b strings.Builder
+	b.WriteString(resultsDBSchema)
+	b.WriteString("BEGIN;\n")
+
+	succeeded := 0
+	if results.Succeeded {
+		succeeded = 1
+	}
+	fmt.Fprintf(&b, "INSERT INTO runs (start_time, duration_seconds, succeeded, driver_name, driver_version, spec_version, suite_version) VALUES (%s, %s, %d, %s, %s, %s, %s);\n",
+		sqlQuote(results.StartTime.UTC().Format(time.RFC3339)), seconds(results.Duration), succeeded,
+		sqlQuote(results.DriverName), sqlQuote(results.DriverVersion), sqlQuote(results.SpecVersion), sqlQuote(suiteVersion))
+	b.WriteString("CREATE TEMP TABLE current_run AS SELECT last_insert_rowid() AS id;\n")
+
+	for _, spec := range results.Specs {
+		fmt.Fprintf(&b, "INSERT INTO specs VALUES ((SELECT id FROM current_run), %s, %s, %s, %s, %s, %s);\n",
+			sqlQuote(spec.ID), sqlQuote(spec.Name), sqlQuote(spec.Area), sqlQuote(string(spec.State)),
+			seconds(spec.Duration), sqlQuote(spec.Failure))
+	}
+
+	methods := make([]string, 0, len(results.RPCs))
+	for method := range results.RPCs {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		stats := results.RPCs[method]
+		errors := 0
+		for _, count := range stats.Errors {
+			errors += count
+		}
+		fmt.Fprintf(&b, "INSERT INTO rpcs VALUES ((SELECT id FROM current_run), %s, %d, %d, %s);\n",
+			sqlQuote(method), stats.Calls, errors, seconds(stats.TotalDuration))
+	}
+
+	b.WriteString("COMMIT;\n")
+	return b.String()
+}
+
+// appendResultsDB adds the results to the SQLite database at path,
+// which gets created if needed. The sqlite3 command line tool is
+// used, so that csi-sanity can remain a static binary.
+func appendResultsDB(path, sqliteCmd string, results *sanity.Results, suiteVersion string) error {
+	cmd := exec.Command(sqliteCmd, "-bail", path)
+	cmd.Stdin = strings.NewReader(resultsDBScript(results, suiteVersion))
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %v: %s", sqliteCmd, path, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
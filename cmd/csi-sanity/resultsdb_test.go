@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	// main.go has its own testing type.
+	gotesting "testing"
+	"time"
+
+	"github.com/kubernetes-csi/csi-test/v4/pkg/sanity"
+)
+
+func TestSQLQuote(t *gotesting.T) {
+	for value, expected := range map[string]string{
+		"":                  "''",
+		"plain":             "'plain'",
+		"it's":              "'it''s'",
+		"'); DROP TABLE x;": "'''); DROP TABLE x;'",
+		"line\nbreak":       "'line\nbreak'",
+	} {
+		if quoted := sqlQuote(value); quoted != expected {
+			t.Errorf("%q: expected %s, got %s", value, expected, quoted)
+		}
+	}
+}
+
+func TestResultsDBScript(t *gotesting.T) {
+	results := &sanity.Results{
+		StartTime:     time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:      90 * time.Second,
+		Succeeded:     true,
+		DriverName:    "o'driver",
+		DriverVersion: "1.0",
+		SpecVersion:   "1.5.0",
+		Specs: []sanity.SpecResult{
+			{ID: "node/publish", Name: "should publish", Area: "Node Service", State: sanity.SpecFailed, Duration: time.Second, Failure: "it's broken"},
+		},
+		RPCs: map[string]sanity.RPCStats{
+			"/csi.v1.Node/NodePublishVolume": {Calls: 3, Errors: map[string]int{"Internal": 1, "NotFound": 1}, TotalDuration: 2 * time.Second},
+			"/csi.v1.Identity/Probe":         {Calls: 1, Errors: map[string]int{}},
+		},
+	}
+	script := resultsDBScript(results, "v4")
+	for _, expected := range []string{
+		"INSERT INTO runs (start_time, duration_seconds, succeeded, driver_name, driver_version, spec_version, suite_version) VALUES ('2022-01-02T03:04:05Z', 90.000000, 1, 'o''driver', '1.0', '1.5.0', 'v4');\n",
+		"INSERT INTO specs VALUES ((SELECT id FROM current_run), 'node/publish', 'should publish', 'Node Service', 'failed', 1.000000, 'it''s broken');\n",
+		"INSERT INTO rpcs VALUES ((SELECT id FROM current_run), '/csi.v1.Identity/Probe', 1, 0, 0.000000);\nINSERT INTO rpcs VALUES ((SELECT id FROM current_run), '/csi.v1.Node/NodePublishVolume', 3, 2, 2.000000);\n",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("script does not contain:\n%s\nscript:\n%s", expected, script)
+		}
+	}
+	if !strings.HasPrefix(script, resultsDBSchema+"BEGIN;\n") || !strings.HasSuffix(script, "COMMIT;\n") {
+		t.Errorf("script is not a single transaction after the schema:\n%s", script)
+	}
+}
@@ -42,6 +42,8 @@ type CSIDriverController struct {
 	running          bool
 	lock             sync.Mutex
 	creds            *CSICreds
+	faults           Faults
+	serverOptions    []grpc.ServerOption
 }
 
 func NewCSIDriverController(controllerServer *CSIDriverControllerServer) *CSIDriverController {
@@ -66,9 +68,9 @@ func (c *CSIDriverController) Start(l net.Listener) error {
 	c.listener = l
 
 	// Create a new grpc server.
-	c.server = grpc.NewServer(
+	c.server = grpc.NewServer(append([]grpc.ServerOption{
 		grpc.UnaryInterceptor(c.callInterceptor),
-	)
+	}, c.serverOptions...)...)
 
 	if c.controllerServer.Controller != nil {
 		csi.RegisterControllerServer(c.server, c.controllerServer.Controller)
@@ -105,6 +107,18 @@ func (c *CSIDriverController) SetDefaultCreds() {
 	setDefaultCreds(c.creds)
 }
 
+// SetServerOptions sets additional options for the gRPC server, for
+// example grpc.Creds with TLS credentials. It must be called before
+// Start.
+func (c *CSIDriverController) SetServerOptions(opts ...grpc.ServerOption) {
+	c.serverOptions = opts
+}
+
 func (c *CSIDriverController) callInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	return callInterceptor(ctx, c.creds, req, info, handler)
+	return callInterceptor(ctx, c.creds, &c.faults, req, info, handler)
+}
+
+// Faults returns the fault table of the driver.
+func (c *CSIDriverController) Faults() *Faults {
+	return &c.faults
 }
@@ -34,13 +34,15 @@ type CSIDriverNodeServer struct {
 
 // CSIDriverNode is the CSI Driver Node backend.
 type CSIDriverNode struct {
-	listener   net.Listener
-	server     *grpc.Server
-	nodeServer *CSIDriverNodeServer
-	wg         sync.WaitGroup
-	running    bool
-	lock       sync.Mutex
-	creds      *CSICreds
+	listener      net.Listener
+	server        *grpc.Server
+	nodeServer    *CSIDriverNodeServer
+	wg            sync.WaitGroup
+	running       bool
+	lock          sync.Mutex
+	creds         *CSICreds
+	faults        Faults
+	serverOptions []grpc.ServerOption
 }
 
 func NewCSIDriverNode(nodeServer *CSIDriverNodeServer) *CSIDriverNode {
@@ -65,9 +67,9 @@ func (c *CSIDriverNode) Start(l net.Listener) error {
 	c.listener = l
 
 	// Create a new grpc server.
-	c.server = grpc.NewServer(
+	c.server = grpc.NewServer(append([]grpc.ServerOption{
 		grpc.UnaryInterceptor(c.callInterceptor),
-	)
+	}, c.serverOptions...)...)
 
 	if c.nodeServer.Node != nil {
 		csi.RegisterNodeServer(c.server, c.nodeServer.Node)
@@ -104,6 +106,18 @@ func (c *CSIDriverNode) SetDefaultCreds() {
 	setDefaultCreds(c.creds)
 }
 
+// SetServerOptions sets additional options for the gRPC server, for
+// example grpc.Creds with TLS credentials. It must be called before
+// Start.
+func (c *CSIDriverNode) SetServerOptions(opts ...grpc.ServerOption) {
+	c.serverOptions = opts
+}
+
 func (c *CSIDriverNode) callInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	return callInterceptor(ctx, c.creds, req, info, handler)
+	return callInterceptor(ctx, c.creds, &c.faults, req, info, handler)
+}
+
+// Faults returns the fault table of the driver.
+func (c *CSIDriverNode) Faults() *Faults {
+	return &c.faults
 }
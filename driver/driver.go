@@ -69,13 +69,15 @@ type CSICreds struct {
 }
 
 type CSIDriver struct {
-	listener net.Listener
-	server   *grpc.Server
-	servers  *CSIDriverServers
-	wg       sync.WaitGroup
-	running  bool
-	lock     sync.Mutex
-	creds    *CSICreds
+	listener      net.Listener
+	server        *grpc.Server
+	servers       *CSIDriverServers
+	wg            sync.WaitGroup
+	running       bool
+	lock          sync.Mutex
+	creds         *CSICreds
+	faults        Faults
+	serverOptions []grpc.ServerOption
 }
 
 func NewCSIDriver(servers *CSIDriverServers) *CSIDriver {
@@ -99,9 +101,9 @@ func (c *CSIDriver) Start(l net.Listener) error {
 	c.listener = l
 
 	// Create a new grpc server
-	c.server = grpc.NewServer(
+	c.server = grpc.NewServer(append([]grpc.ServerOption{
 		grpc.UnaryInterceptor(c.callInterceptor),
-	)
+	}, c.serverOptions...)...)
 
 	// Register Mock servers
 	if c.servers.Controller != nil {
@@ -143,8 +145,20 @@ func (c *CSIDriver) SetDefaultCreds() {
 	setDefaultCreds(c.creds)
 }
 
+// SetServerOptions sets additional options for the gRPC server, for
+// example grpc.Creds with TLS credentials. It must be called before
+// Start.
+func (c *CSIDriver) SetServerOptions(opts ...grpc.ServerOption) {
+	c.serverOptions = opts
+}
+
 func (c *CSIDriver) callInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	return callInterceptor(ctx, c.creds, req, info, handler)
+	return callInterceptor(ctx, c.creds, &c.faults, req, info, handler)
+}
+
+// Faults returns the fault table of the driver.
+func (c *CSIDriver) Faults() *Faults {
+	return &c.faults
 }
 
 // goServe starts a grpc server.
@@ -189,7 +203,11 @@ func setDefaultCreds(creds *CSICreds) {
 	}
 }
 
-func callInterceptor(ctx context.Context, creds *CSICreds, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+func callInterceptor(ctx context.Context, creds *CSICreds, faults *Faults, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := faults.inject(ctx, info.FullMethod); err != nil {
+		logGRPC(info.FullMethod, req, nil, err)
+		return nil, err
+	}
 	err := authInterceptor(creds, req)
 	if err != nil {
 		logGRPC(info.FullMethod, req, nil, err)
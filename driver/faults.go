@@ -0,0 +1,255 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v2"
+)
+
+// Fault makes calls of a method fail with an error instead of
+// reaching the server, or slows them down.
+type Fault struct {
+	// Method is the name of the RPC, for example CreateVolume, or
+	// the full gRPC method name.
+	Method string
+	// Code and Message are returned to the client. With codes.OK
+	// the call is passed on to the server after the delay.
+	Code    codes.Code
+	Message string
+	// After is the number of calls which are not affected before
+	// the fault starts, for example 2 to make the third call fail.
+	After int
+	// Count is the number of calls which are affected, zero for
+	// all calls until the fault gets removed.
+	Count int
+	// Delay is added before the call fails or gets passed on,
+	// plus a random duration up to Jitter. The delay ends early
+	// when the client cancels the call.
+	Delay  time.Duration
+	Jitter time.Duration
+}
+
+// ParseFault parses a fault in the form
+// <method>:<code>[:<count>[:<delay>[:<jitter>]]], for example
+// CreateVolume:ResourceExhausted:2 or NodeStageVolume:OK:0:5s:1s.
+// The code is either a name like in codes.Code.String or a number,
+// durations are as accepted by time.ParseDuration.
+func ParseFault(value string) (Fault, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 5 || parts[0] == "" {
+		return Fault{}, fmt.Errorf("invalid fault %q, must be <method>:<code>[:<count>[:<delay>[:<jitter>]]]", value)
+	}
+	fault := Fault{Method: parts[0]}
+	code, err := parseCode(parts[1])
+	if err != nil {
+		return Fault{}, fmt.Errorf("invalid fault %q: %v", value, err)
+	}
+	fault.Code = code
+	if len(parts) >= 3 {
+		count, err := strconv.Atoi(parts[2])
+		if err != nil || count < 0 {
+			return Fault{}, fmt.Errorf("invalid fault %q: count must be a non-negative number", value)
+		}
+		fault.Count = count
+	}
+	for i, d := range []*time.Duration{&fault.Delay, &fault.Jitter} {
+		if len(parts) <= 3+i {
+			break
+		}
+		duration, err := time.ParseDuration(parts[3+i])
+		if err != nil || duration < 0 {
+			return Fault{}, fmt.Errorf("invalid fault %q: %q is not a valid duration", value, parts[3+i])
+		}
+		*d = duration
+	}
+	return fault, nil
+}
+
+// faultFile is the format of one entry in a file for LoadFaults.
+type faultFile struct {
+	Method  string        `yaml:"method"`
+	Code    string        `yaml:"code"`
+	Message string        `yaml:"message"`
+	After   int           `yaml:"after"`
+	Count   int           `yaml:"count"`
+	Delay   time.Duration `yaml:"delay"`
+	Jitter  time.Duration `yaml:"jitter"`
+}
+
+// LoadFaults reads a list of faults from a YAML or JSON file, so that
+// failure scenarios can be scripted without recompiling, for example:
+//
+//	# The third NodeStageVolume fails, all CreateVolume calls are slow.
+//	- method: NodeStageVolume
+//	  code: Internal
+//	  message: disk not ready
+//	  after: 2
+//	  count: 1
+//	- method: CreateVolume
+//	  delay: 5s
+//	  jitter: 1s
+//
+// The code defaults to OK, durations are strings as accepted by
+// time.ParseDuration.
+func LoadFaults(path string) ([]Fault, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %q: %v", path, err)
+	}
+	var entries []faultFile
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		return nil, fmt.Errorf("error unmarshaling yaml from %q: %v", path, err)
+	}
+	faults := make([]Fault, 0, len(entries))
+	for i, entry := range entries {
+		if entry.Method == "" {
+			return nil, fmt.Errorf("%q: fault #%d: method is missing", path, i+1)
+		}
+		if entry.After < 0 || entry.Count < 0 || entry.Delay < 0 || entry.Jitter < 0 {
+			return nil, fmt.Errorf("%q: fault #%d: negative values are not allowed", path, i+1)
+		}
+		fault := Fault{
+			Method:  entry.Method,
+			Message: entry.Message,
+			After:   entry.After,
+			Count:   entry.Count,
+			Delay:   entry.Delay,
+			Jitter:  entry.Jitter,
+		}
+		if entry.Code != "" {
+			if fault.Code, err = parseCode(entry.Code); err != nil {
+				return nil, fmt.Errorf("%q: fault #%d: %v", path, i+1, err)
+			}
+		}
+		faults = append(faults, fault)
+	}
+	return faults, nil
+}
+
+func parseCode(value string) (codes.Code, error) {
+	if number, err := strconv.ParseUint(value, 10, 32); err == nil && number <= uint64(codes.Unauthenticated) {
+		return codes.Code(number), nil
+	}
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.EqualFold(c.String(), value) {
+			return c, nil
+		}
+	}
+	return codes.OK, fmt.Errorf("unknown code %q", value)
+}
+
+// Faults is the fault table of a driver. It can be modified while
+// the driver is running. The zero value has no faults.
+type Faults struct {
+	lock   sync.Mutex
+	faults []Fault
+}
+
+// Add appends faults. When several faults match a call, the one
+// which was added first is used.
+func (f *Faults) Add(faults ...Fault) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.faults = append(f.faults, faults...)
+}
+
+// Clear removes all faults.
+func (f *Faults) Clear() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.faults = nil
+}
+
+// List returns the remaining faults. After and Count are the number
+// of calls which will still be skipped and affected.
+func (f *Faults) List() []Fault {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]Fault(nil), f.faults...)
+}
+
+// inject waits for the delay of the first fault which matches the
+// full method name and then returns its error, nil for codes.OK.
+func (f *Faults) inject(ctx context.Context, fullMethod string) error {
+	delay, err := f.match(fullMethod)
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return err
+}
+
+// match finds the first active fault for the full method name and
+// removes that fault once it has been used up. Faults which still
+// skip calls count down instead.
+func (f *Faults) match(fullMethod string) (time.Duration, error) {
+	if f == nil {
+		return 0, nil
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i := range f.faults {
+		fault := &f.faults[i]
+		if fault.Method != fullMethod && !strings.HasSuffix(fullMethod, "/"+fault.Method) {
+			continue
+		}
+		if fault.After > 0 {
+			fault.After--
+			continue
+		}
+		var err error
+		if fault.Code != codes.OK {
+			message := fault.Message
+			if message == "" {
+				message = "injected fault"
+			}
+			err = status.Error(fault.Code, message)
+		}
+		delay := fault.Delay
+		if fault.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(fault.Jitter)))
+		}
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				f.faults = append(f.faults[:i], f.faults[i+1:]...)
+			}
+		}
+		return delay, err
+	}
+	return 0, nil
+}
@@ -68,6 +68,13 @@ func (m *MockCSIDriver) Start() error {
 }
 
 func (m *MockCSIDriver) Nexus() (*grpc.ClientConn, error) {
+	return m.NexusWithDialOptions(grpc.WithInsecure())
+}
+
+// NexusWithDialOptions is like Nexus, but connects with the given
+// options instead of an insecure connection, for example with TLS
+// credentials which match the server options.
+func (m *MockCSIDriver) NexusWithDialOptions(opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	// Start server
 	err := m.Start()
 	if err != nil {
@@ -75,7 +82,7 @@ func (m *MockCSIDriver) Nexus() (*grpc.ClientConn, error) {
 	}
 
 	// Create a client connection
-	m.conn, err = utils.Connect(m.Address(), grpc.WithInsecure())
+	m.conn, err = utils.Connect(m.Address(), opts...)
 	if err != nil {
 		return nil, err
 	}
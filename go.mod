@@ -3,6 +3,7 @@ module github.com/kubernetes-csi/csi-test/v4
 go 1.16
 
 require (
+	github.com/Microsoft/go-winio v0.5.2
 	github.com/container-storage-interface/spec v1.6.0
 	github.com/golang/mock v1.6.0
 	github.com/golang/protobuf v1.5.2
@@ -11,6 +12,7 @@ require (
 	github.com/onsi/gomega v1.19.0
 	google.golang.org/genproto v0.0.0-20201209185603-f92720507ed4 // indirect
 	google.golang.org/grpc v1.47.0
+	google.golang.org/protobuf v1.27.1
 	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/klog/v2 v2.60.1
 )
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// defaultArtifactRPCs is the number of calls written for a failed
+// spec if TestConfig.ArtifactRPCs is not set.
+const defaultArtifactRPCs = 20
+
+// unsafeFileChars matches characters which are replaced in the names
+// of artifact directories.
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// collectFailureArtifacts runs after a spec and before its cleanup,
+// so the mounts of a failed spec are still in place.
+func (sc *TestContext) collectFailureArtifacts() {
+	desc := CurrentGinkgoTestDescription()
+	if !desc.Failed || (sc.Config.ArtifactsDir == "" && sc.Config.OnFailure == nil) {
+		return
+	}
+	id, name := parseSpecID(desc.FullTestText)
+	_, name = parseSpecGroups(name)
+
+	var dir string
+	if sc.Config.ArtifactsDir != "" {
+		var err error
+		dir, err = sc.writeFailureArtifacts(id, name)
+		if err != nil {
+			sc.Config.logger().Error(err, "collecting failure artifacts failed", "spec", name)
+		}
+	}
+	if sc.Config.OnFailure != nil {
+		sc.Config.OnFailure(name, dir)
+	}
+}
+
+// writeFailureArtifacts creates a directory for the spec and writes
+// the most recent calls and a listing of the target and staging
+// paths into it.
+func (sc *TestContext) writeFailureArtifacts(id, name string) (string, error) {
+	base := id
+	if base == "" {
+		base = name
+	}
+	base = strings.Trim(unsafeFileChars.ReplaceAllString(base, "-"), "-")
+	if len(base) > 100 {
+		base = base[:100]
+	}
+	dir := filepath.Join(sc.Config.ArtifactsDir, base)
+	// Retries of the same spec get their own directory.
+	for i := 2; ; i++ {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			break
+		}
+		dir = filepath.Join(sc.Config.ArtifactsDir, fmt.Sprintf("%s-%d", base, i))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "spec.txt"), []byte(fmt.Sprintf("%s\n%s\n", name, id)), 0644); err != nil {
+		return dir, err
+	}
+
+	var rpcs bytes.Buffer
+	encoder := json.NewEncoder(&rpcs)
+	for _, record := range sc.recorder.recentRecords() {
+		if err := encoder.Encode(record); err != nil {
+			return dir, err
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "rpcs.jsonl"), rpcs.Bytes(), 0644); err != nil {
+		return dir, err
+	}
+
+	var paths bytes.Buffer
+	for _, path := range []string{sc.TargetPath, sc.StagingPath} {
+		if path == "" {
+			continue
+		}
+		fmt.Fprintf(&paths, "%s:\n", path)
+		sc.listPath(&paths, path)
+		paths.WriteString("\n")
+	}
+	return dir, ioutil.WriteFile(filepath.Join(dir, "paths.txt"), paths.Bytes(), 0644)
+}
+
+// listPath writes the files under the path with their mode and size,
+// using ls on the node for TestConfig.SSH.
+func (sc *TestContext) listPath(out *bytes.Buffer, path string) {
+	if sc.Config.SSH != nil {
+		if err := sc.Config.SSH.run(sc.Config.CheckPathCmdTimeout, "ls -laR "+shellQuote(path), out); err != nil {
+			fmt.Fprintf(out, "  %v\n", err)
+		}
+		return
+	}
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Fprintf(out, "  %s: %v\n", file, err)
+			return nil
+		}
+		fmt.Fprintf(out, "  %s %10d %s\n", info.Mode(), info.Size(), file)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(out, "  %v\n", err)
+	}
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// callback is one request that a driver sent to the callback
+// listener. The payload must be a JSON object with at least a
+// "volume_id" field.
+type callback struct {
+	RemoteIP string
+	VolumeID string
+	Payload  map[string]interface{}
+}
+
+// callbackServer receives callbacks from the driver over HTTP and
+// records them. Requests from addresses which are not allowed and
+// requests with an invalid payload are rejected and recorded as
+// errors. All methods can be called concurrently.
+type callbackServer struct {
+	allowedIPs []net.IP
+	listener   net.Listener
+	server     *http.Server
+	logger     Logger
+
+	mutex     sync.Mutex
+	callbacks []callback
+	errors    []string
+}
+
+// startCallbackServer starts listening on the address. An empty
+// allowlist accepts callbacks from all addresses.
+func startCallbackServer(address string, allowedIPs []string, logger Logger) (*callbackServer, error) {
+	cs := &callbackServer{logger: logger}
+	for _, ip := range allowedIPs {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, fmt.Errorf("invalid IP address %q in callback allowlist", ip)
+		}
+		cs.allowedIPs = append(cs.allowedIPs, parsed)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("listening for callbacks on %s: %v", address, err)
+	}
+	cs.listener = listener
+	cs.server = &http.Server{Handler: cs}
+	go func() {
+		if err := cs.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error(err, "serving callbacks failed", "address", address)
+		}
+	}()
+	return cs, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (cs *callbackServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if !cs.isAllowed(net.ParseIP(host)) {
+		cs.addError(fmt.Sprintf("callback from %s which is not in the allowlist", host))
+		http.Error(w, "address not allowed", http.StatusForbidden)
+		return
+	}
+	if req.Method != http.MethodPost {
+		cs.addError(fmt.Sprintf("callback from %s with method %s instead of POST", host, req.Method))
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		cs.addError(fmt.Sprintf("reading callback from %s: %v", host, err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		cs.addError(fmt.Sprintf("callback from %s is not a JSON object: %v", host, err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	volumeID, _ := payload["volume_id"].(string)
+	if volumeID == "" {
+		cs.addError(fmt.Sprintf("callback from %s has no volume_id", host))
+		http.Error(w, "volume_id missing", http.StatusBadRequest)
+		return
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.callbacks = append(cs.callbacks, callback{RemoteIP: host, VolumeID: volumeID, Payload: payload})
+}
+
+func (cs *callbackServer) isAllowed(ip net.IP) bool {
+	if len(cs.allowedIPs) == 0 {
+		return true
+	}
+	for _, allowed := range cs.allowedIPs {
+		if allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cs *callbackServer) addError(message string) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.errors = append(cs.errors, message)
+}
+
+// forVolume returns all valid callbacks for the volume and all
+// errors recorded so far.
+func (cs *callbackServer) forVolume(volumeID string) ([]callback, []string) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	var callbacks []callback
+	for _, cb := range cs.callbacks {
+		if cb.VolumeID == volumeID {
+			callbacks = append(callbacks, cb)
+		}
+	}
+	return callbacks, append([]string(nil), cs.errors...)
+}
+
+// callbackShutdownTimeout is how long stop waits for callbacks which
+// are still being received.
+var callbackShutdownTimeout = 10 * time.Second
+
+// stop shuts down the listener.
+func (cs *callbackServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackShutdownTimeout)
+	defer cancel()
+	if err := cs.server.Shutdown(ctx); err != nil {
+		cs.logger.Error(err, "stopping callback listener failed")
+	}
+}
+
+var _ = DescribeSanity("Callbacks", func(sc *TestContext) {
+	var r *Resources
+
+	BeforeEach(func() {
+		if sc.Config.CallbackListenAddress == "" {
+			Skip("Config.CallbackListenAddress not set")
+		}
+		if sc.callbacks == nil {
+			var err error
+			sc.callbacks, err = startCallbackServer(sc.Config.CallbackListenAddress, sc.Config.CallbackAllowedIPs, sc.Config.logger())
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		r = &Resources{
+			Context:          sc,
+			ControllerClient: csi.NewControllerClient(sc.ControllerConn),
+			NodeClient:       csi.NewNodeClient(sc.Conn),
+		}
+	})
+
+	AfterEach(func() {
+		if r != nil {
+			r.Cleanup()
+		}
+	})
+
+	It(SpecID("callback/publish", "should call back when publishing a volume"), func() {
+		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME) {
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME), "CreateVolume not supported")
+		}
+		volCap := TestVolumeCapability(sc)
+
+		By("creating a volume")
+		vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-callback")))
+		volID := vol.GetVolume().GetVolumeId()
+
+		var publishContext map[string]string
+		if isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME) {
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("controller publishing the volume")
+			conpubvol := r.MustControllerPublishVolume(context.Background(), MakeControllerPublishVolumeReq(sc, volID, nid.GetNodeId()))
+			publishContext = conpubvol.GetPublishContext()
+		}
+
+		var stagingPath string
+		if isNodeCapabilitySupported(r, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME) {
+			By("node staging the volume")
+			stagingPath = sc.StagingPath
+			_, err := r.NodeStageVolume(
+				context.Background(),
+				&csi.NodeStageVolumeRequest{
+					VolumeId:          volID,
+					VolumeCapability:  volCap,
+					StagingTargetPath: stagingPath,
+					VolumeContext:     vol.GetVolume().GetVolumeContext(),
+					PublishContext:    publishContext,
+					Secrets:           sc.Secrets.NodeStageVolumeSecret,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("publishing the volume")
+		_, err := r.NodePublishVolume(
+			context.Background(),
+			&csi.NodePublishVolumeRequest{
+				VolumeId:          volID,
+				TargetPath:        filepath.Join(sc.TargetPath, "target"),
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  volCap,
+				VolumeContext:     vol.GetVolume().GetVolumeContext(),
+				PublishContext:    publishContext,
+				Secrets:           sc.Secrets.NodePublishVolumeSecret,
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("waiting for the callback")
+		Eventually(func() []callback {
+			callbacks, _ := sc.callbacks.forVolume(volID)
+			return callbacks
+		}, sc.Config.CallbackTimeout, time.Second).ShouldNot(BeEmpty(), "no callback received for volume %s", volID)
+		_, errors := sc.callbacks.forVolume(volID)
+		Expect(errors).To(BeEmpty(), "invalid callbacks were received")
+	})
+})
@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallbackServerStop(t *testing.T) {
+	defer func(timeout time.Duration) {
+		callbackShutdownTimeout = timeout
+	}(callbackShutdownTimeout)
+	callbackShutdownTimeout = 100 * time.Millisecond
+
+	var out bytes.Buffer
+	cs, err := startCallbackServer("127.0.0.1:0", nil, NewWriterLogger(&out, 0))
+	if err != nil {
+		t.Fatalf("starting callback server: %v", err)
+	}
+	// A request which never completes makes Shutdown time out.
+	conn, err := net.Dial("tcp", cs.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("POST / HTTP/1.1\r\nHost: sanity\r\n")); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	// Wait for the server to start reading the request.
+	time.Sleep(100 * time.Millisecond)
+
+	cs.stop()
+	if !strings.Contains(out.String(), "stopping callback listener failed") {
+		t.Errorf("expected the Shutdown error to be logged, got: %q", out.String())
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Capabilities are identified by the service that provides them
+// ("Plugin", "Controller" or "Node") and their name in the CSI spec,
+// separated by a dot. Examples: "Plugin.CONTROLLER_SERVICE",
+// "Controller.CREATE_DELETE_VOLUME", "Node.STAGE_UNSTAGE_VOLUME".
+// Volume expansion types get a VOLUME_EXPANSION_ prefix, as in
+// "Plugin.VOLUME_EXPANSION_ONLINE".
+
+// PluginCapability returns the name of a plugin service capability
+// as used in TestConfig.RequiredCapabilities.
+func PluginCapability(t csi.PluginCapability_Service_Type) string {
+	return "Plugin." + t.String()
+}
+
+// VolumeExpansionCapability returns the name of a plugin volume
+// expansion capability as used in TestConfig.RequiredCapabilities.
+func VolumeExpansionCapability(t csi.PluginCapability_VolumeExpansion_Type) string {
+	return "Plugin.VOLUME_EXPANSION_" + t.String()
+}
+
+// ControllerCapability returns the name of a controller service
+// capability as used in TestConfig.RequiredCapabilities.
+func ControllerCapability(t csi.ControllerServiceCapability_RPC_Type) string {
+	return "Controller." + t.String()
+}
+
+// NodeCapability returns the name of a node service capability as
+// used in TestConfig.RequiredCapabilities.
+func NodeCapability(t csi.NodeServiceCapability_RPC_Type) string {
+	return "Node." + t.String()
+}
+
+// isRequiredCapability checks whether the capability is listed in
+// RequiredCapabilities.
+func (config *TestConfig) isRequiredCapability(capability string) bool {
+	for _, required := range config.RequiredCapabilities {
+		if required == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// skipUnsupported must be called instead of Skip when a test cannot
+// run because the driver does not have the given capability. In
+// strict mode, a missing capability which is required causes the
+// test to fail instead.
+func skipUnsupported(sc *TestContext, capability string, message string) {
+	sc.results.setMissingCapability(capability)
+	if sc.Config.StrictCapabilities && sc.Config.isRequiredCapability(capability) {
+		Fail(fmt.Sprintf("%s: required capability %s is missing", message, capability), 1)
+	}
+	Skip(message, 1)
+}
+
+// DescribeWithCapability is like DescribeSanity for blocks whose
+// tests all need the given capability, for example
+// ControllerCapability(csi.ControllerServiceCapability_RPC_LIST_VOLUMES).
+// The capability gets added to the text and the tests are skipped
+// (or fail, in strict mode) when the driver does not have it. This
+// is meant for custom tests of a specific driver.
+func DescribeWithCapability(text string, capability string, body func(*TestContext)) bool {
+	return DescribeSanity(fmt.Sprintf("%s [%s]", text, capability), func(sc *TestContext) {
+		BeforeEach(func() {
+			supported, err := sc.hasCapability(context.Background(), capability)
+			Expect(err).NotTo(HaveOccurred(), "checking capability %s", capability)
+			if !supported {
+				skipUnsupported(sc, capability, fmt.Sprintf("%s not supported", capability))
+			}
+		})
+
+		body(sc)
+	})
+}
+
+// hasCapability asks the driver whether it has the capability.
+func (sc *TestContext) hasCapability(ctx context.Context, capability string) (bool, error) {
+	parts := strings.SplitN(capability, ".", 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("invalid capability %q, must be <service>.<name>", capability)
+	}
+	service, name := parts[0], parts[1]
+
+	plugin, err := csi.NewIdentityClient(sc.Conn).GetPluginCapabilities(ctx, &csi.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		return false, err
+	}
+	providesControllerService := false
+	for _, cap := range plugin.GetCapabilities() {
+		if cap.GetService().GetType() == csi.PluginCapability_Service_CONTROLLER_SERVICE {
+			providesControllerService = true
+		}
+		if cap.GetService() != nil && PluginCapability(cap.GetService().GetType()) == capability ||
+			cap.GetVolumeExpansion() != nil && VolumeExpansionCapability(cap.GetVolumeExpansion().GetType()) == capability {
+			return true, nil
+		}
+	}
+
+	switch service {
+	case "Plugin":
+		return false, nil
+	case "Controller":
+		if !providesControllerService {
+			return false, nil
+		}
+		caps, err := csi.NewControllerClient(sc.ControllerConn).ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{})
+		if err != nil {
+			return false, err
+		}
+		for _, cap := range caps.GetCapabilities() {
+			if cap.GetRpc().GetType().String() == name {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "Node":
+		caps, err := csi.NewNodeClient(sc.Conn).NodeGetCapabilities(ctx, &csi.NodeGetCapabilitiesRequest{})
+		if err != nil {
+			return false, err
+		}
+		for _, cap := range caps.GetCapabilities() {
+			if cap.GetRpc().GetType().String() == name {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid capability %q, service must be Plugin, Controller or Node", capability)
+	}
+}
+
+var _ = DescribeSanity("Required Capabilities", func(sc *TestContext) {
+	BeforeEach(func() {
+		if !sc.Config.StrictCapabilities || len(sc.Config.RequiredCapabilities) == 0 {
+			Skip("no required capabilities in strict mode")
+		}
+	})
+
+	It(SpecID("capabilities/required", "should advertise all required capabilities"), func() {
+		var missing []string
+		for _, capability := range sc.Config.RequiredCapabilities {
+			supported, err := sc.hasCapability(context.Background(), capability)
+			Expect(err).NotTo(HaveOccurred(), "checking capability %s", capability)
+			if !supported {
+				missing = append(missing, capability)
+			}
+		}
+		Expect(missing).To(BeEmpty(), "required capabilities are not advertised by the driver")
+	})
+})
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// specModule is the Go module which provides the CSI spec.
+const specModule = "github.com/container-storage-interface/spec"
+
+// specVersion returns the version of the CSI spec module that the
+// binary was built with, "unknown" if that information is not
+// available.
+func specVersion() string {
+	return moduleVersion(specModule)
+}
+
+// areaStates summarizes the specs of each area: failed if any spec
+// failed, passed if at least one passed and skipped otherwise.
+func (r *Results) areaStates() map[string]SpecState {
+	states := map[string]SpecState{}
+	for _, spec := range r.Specs {
+		if spec.Area == "" {
+			continue
+		}
+		switch current := states[spec.Area]; {
+		case current == SpecFailed:
+		case spec.State == SpecFailed, spec.State == SpecPassed:
+			states[spec.Area] = spec.State
+		case current == "":
+			states[spec.Area] = SpecSkipped
+		}
+	}
+	return states
+}
+
+// Certificate returns a plain-text summary of the results which is
+// suitable for release notes. suiteVersion is the version of
+// csi-sanity or of the test binary which embeds the sanity package.
+func (r *Results) Certificate(suiteVersion string) string {
+	var b strings.Builder
+
+	verdict := "PASSED"
+	if !r.Succeeded {
+		verdict = "FAILED"
+	}
+	fmt.Fprintf(&b, "CSI Sanity Conformance: %s\n\n", verdict)
+	fmt.Fprintf(&b, "Driver:         %s\n", r.DriverName)
+	fmt.Fprintf(&b, "Driver version: %s\n", r.DriverVersion)
+	fmt.Fprintf(&b, "CSI spec:       %s\n", r.SpecVersion)
+	fmt.Fprintf(&b, "Suite version:  %s\n", suiteVersion)
+	fmt.Fprintf(&b, "Date:           %s\n", r.StartTime.UTC().Format("2006-01-02"))
+	fmt.Fprintf(&b, "Specs:          %d passed, %d failed, %d skipped\n",
+		r.Count(SpecPassed), r.Count(SpecFailed), r.Count(SpecSkipped))
+	if env := r.Environment; env.OS != "" {
+		fmt.Fprintf(&b, "Host:           %s/%s", env.OS, env.Arch)
+		for _, detail := range []string{env.Distribution, env.Kernel, env.Cgroup} {
+			if detail != "" {
+				fmt.Fprintf(&b, ", %s", detail)
+			}
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+
+	states := r.areaStates()
+	areas := make([]string, 0, len(states))
+	for area := range states {
+		areas = append(areas, area)
+	}
+	sort.Strings(areas)
+	if len(areas) > 0 {
+		fmt.Fprintf(&b, "\nAreas:\n")
+		for _, area := range areas {
+			fmt.Fprintf(&b, "  %-8s %s\n", states[area], area)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package channelz captures the gRPC channelz state of all client
+// connections in the process. It is separate from the sanity package
+// because importing it turns on channelz tracking for the whole
+// process, which only makes sense when the snapshots are wanted:
+//
+//	config.ConnectionDiagnostics = channelz.Dump
+package channelz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/channelz/service"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// target is the name of the in-process channelz connection. Its
+// channel is left out of the snapshots.
+const target = "passthrough:///sanity-channelz"
+
+type subchannel struct {
+	Subchannel json.RawMessage   `json:"subchannel"`
+	Sockets    []json.RawMessage `json:"sockets,omitempty"`
+}
+
+type channel struct {
+	Channel     json.RawMessage `json:"channel"`
+	Subchannels []subchannel    `json:"subchannels,omitempty"`
+}
+
+var (
+	setupOnce sync.Once
+	client    channelzpb.ChannelzClient
+	setupErr  error
+)
+
+// setup starts the channelz service on an in-memory listener and
+// connects to it, once per process.
+func setup() {
+	listener := newPipeListener()
+	server := grpc.NewServer()
+	service.RegisterChannelzServiceToServer(server)
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial(target, grpc.WithInsecure(), grpc.WithContextDialer(listener.dial))
+	if err != nil {
+		setupErr = fmt.Errorf("connecting to channelz: %v", err)
+		return
+	}
+	client = channelzpb.NewChannelzClient(conn)
+}
+
+// Dump returns the channels, subchannels and sockets of all client
+// connections as JSON. It can be used as
+// sanity.TestConfig.ConnectionDiagnostics.
+func Dump(ctx context.Context) (json.RawMessage, error) {
+	setupOnce.Do(setup)
+	if setupErr != nil {
+		return nil, setupErr
+	}
+
+	var channels []channel
+	startID := int64(0)
+	for {
+		top, err := client.GetTopChannels(ctx, &channelzpb.GetTopChannelsRequest{StartChannelId: startID})
+		if err != nil {
+			return nil, fmt.Errorf("getting channels: %v", err)
+		}
+		for _, ch := range top.GetChannel() {
+			startID = ch.GetRef().GetChannelId() + 1
+			if ch.GetData().GetTarget() == target {
+				continue
+			}
+			dumped, err := dumpChannel(ctx, ch)
+			if err != nil {
+				return nil, err
+			}
+			channels = append(channels, dumped)
+		}
+		if top.GetEnd() || len(top.GetChannel()) == 0 {
+			break
+		}
+	}
+	return json.Marshal(channels)
+}
+
+func dumpChannel(ctx context.Context, ch *channelzpb.Channel) (channel, error) {
+	result := channel{Channel: marshal(ch)}
+	for _, ref := range ch.GetSubchannelRef() {
+		sub, err := client.GetSubchannel(ctx, &channelzpb.GetSubchannelRequest{SubchannelId: ref.GetSubchannelId()})
+		if err != nil {
+			return result, fmt.Errorf("getting subchannel %d: %v", ref.GetSubchannelId(), err)
+		}
+		dumped := subchannel{Subchannel: marshal(sub.GetSubchannel())}
+		for _, socketRef := range sub.GetSubchannel().GetSocketRef() {
+			socket, err := client.GetSocket(ctx, &channelzpb.GetSocketRequest{SocketId: socketRef.GetSocketId()})
+			if err != nil {
+				return result, fmt.Errorf("getting socket %d: %v", socketRef.GetSocketId(), err)
+			}
+			dumped.Sockets = append(dumped.Sockets, marshal(socket.GetSocket()))
+		}
+		result.Subchannels = append(result.Subchannels, dumped)
+	}
+	return result, nil
+}
+
+func marshal(m proto.Message) json.RawMessage {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return data
+}
+
+// pipeListener hands out the server side of in-memory connections,
+// so that querying channelz needs no network listener.
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errors.New("listener closed")
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+func (l *pipeListener) dial(ctx context.Context, _ string) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.closed:
+		return nil, errors.New("listener closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "sanity-channelz" }
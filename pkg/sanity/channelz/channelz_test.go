@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package channelz
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestDump(t *testing.T) {
+	listener := newPipeListener()
+	defer listener.Close()
+	server := grpc.NewServer()
+	defer server.Stop()
+	go server.Serve(listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, "passthrough:///driver", grpc.WithInsecure(), grpc.WithBlock(), grpc.WithContextDialer(listener.dial))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		dump, err := Dump(ctx)
+		if err != nil {
+			t.Fatalf("Dump: %v", err)
+		}
+		var channels []channel
+		if err := json.Unmarshal(dump, &channels); err != nil {
+			t.Fatalf("invalid JSON %s: %v", dump, err)
+		}
+		text := string(dump)
+		if !strings.Contains(text, "passthrough:///driver") {
+			t.Errorf("driver channel missing in %s", text)
+		}
+		if strings.Contains(text, target) {
+			t.Errorf("channelz channel included in %s", text)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadConfig returns the defaults from NewTestConfig, modified by
+// the YAML or JSON object in the file. The keys are the names of the
+// TestConfig fields, compared case-insensitively, for example:
+//
+//	address: unix:///csi/csi.sock
+//	testVolumeSize: 1073741824
+//	rpcTimeout: 1m
+//	secretsFile: /config/secrets.yaml
+//
+// Durations are strings as accepted by time.ParseDuration. Keys of
+// nested structs like RetryPolicy are in lower case. Enums are given
+// by name, for example SINGLE_NODE_WRITER in testVolumeAccessModes,
+// Unavailable in the retryablecodes of retryPolicy, warn for
+// leakCheck and skip in unimplementedPolicies. Unknown keys and
+// names are errors, as are fields which cannot be expressed in a
+// file, like DialOptions, IDGen, SecretsTemplateFuncs or the
+// callbacks.
+func LoadConfig(path string) (TestConfig, error) {
+	config := NewTestConfig()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read file %q: %v", path, err)
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return config, fmt.Errorf("error unmarshaling yaml from %q: %v", path, err)
+	}
+
+	target := reflect.ValueOf(&config).Elem()
+	for key, value := range values {
+		field, ok := configField(target, key)
+		if !ok {
+			return config, fmt.Errorf("%q: unknown config field %q", path, key)
+		}
+		if err := checkConfigType(field.Type(), map[reflect.Type]bool{}); err != nil {
+			return config, fmt.Errorf("%q: config field %q cannot be set in a file: %v", path, key, err)
+		}
+		value, err := convertConfigEnums(field.Type(), value)
+		if err != nil {
+			return config, fmt.Errorf("%q: config field %q: %v", path, key, err)
+		}
+		// Converting back to YAML lets yaml.v2 handle durations,
+		// nested structs and maps like for any other file.
+		encoded, err := yaml.Marshal(value)
+		if err != nil {
+			return config, fmt.Errorf("%q: config field %q: %v", path, key, err)
+		}
+		if err := yaml.UnmarshalStrict(encoded, field.Addr().Interface()); err != nil {
+			return config, fmt.Errorf("%q: config field %q: %v", path, key, err)
+		}
+	}
+	return config, nil
+}
+
+// configField finds the exported field of the TestConfig with the
+// given name, ignoring case.
+func configField(config reflect.Value, name string) (reflect.Value, bool) {
+	t := config.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" && strings.EqualFold(t.Field(i).Name, name) {
+			return config.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// checkConfigType returns an error if the type contains functions,
+// interfaces or channels, which cannot be read from a file.
+func checkConfigType(t reflect.Type, checked map[reflect.Type]bool) error {
+	if checked[t] {
+		return nil
+	}
+	checked[t] = true
+	switch t.Kind() {
+	case reflect.Func, reflect.Interface, reflect.Chan, reflect.UnsafePointer:
+		return fmt.Errorf("%s is not supported", t)
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return checkConfigType(t.Elem(), checked)
+	case reflect.Map:
+		if err := checkConfigType(t.Key(), checked); err != nil {
+			return err
+		}
+		return checkConfigType(t.Elem(), checked)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			if err := checkConfigType(t.Field(i).Type, checked); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// configEnums parse the names of enum values for LoadConfig.
+// Numbers are not accepted because they are easy to get wrong.
+var configEnums = map[reflect.Type]func(name string) (interface{}, error){
+	reflect.TypeOf(csi.VolumeCapability_AccessMode_UNKNOWN): func(name string) (interface{}, error) {
+		mode, ok := csi.VolumeCapability_AccessMode_Mode_value[name]
+		if !ok || mode == int32(csi.VolumeCapability_AccessMode_UNKNOWN) {
+			return nil, fmt.Errorf("unknown access mode %q", name)
+		}
+		return mode, nil
+	},
+	reflect.TypeOf(codes.OK): func(name string) (interface{}, error) {
+		for code := codes.OK; code <= codes.Unauthenticated; code++ {
+			if code.String() == name {
+				return uint32(code), nil
+			}
+		}
+		return nil, fmt.Errorf("unknown gRPC status code %q", name)
+	},
+	reflect.TypeOf(LeakCheckDisabled): func(name string) (interface{}, error) {
+		switch mode := LeakCheckMode(name); mode {
+		case LeakCheckDisabled, LeakCheckWarn, LeakCheckFail:
+			return name, nil
+		}
+		return nil, fmt.Errorf("unknown leak check mode %q, must be warn or fail", name)
+	},
+	reflect.TypeOf(UnimplementedDefault): func(name string) (interface{}, error) {
+		switch policy := UnimplementedPolicy(name); policy {
+		case UnimplementedSkip, UnimplementedFail:
+			return name, nil
+		}
+		return nil, fmt.Errorf("unknown policy %q, must be skip or fail", name)
+	},
+}
+
+// convertConfigEnums replaces the names of enum values in a value
+// from the file with the values expected by yaml.v2 for the type.
+// Values which do not match the type are left alone for yaml.v2 to
+// reject.
+func convertConfigEnums(t reflect.Type, value interface{}) (interface{}, error) {
+	if parse, ok := configEnums[t]; ok {
+		name, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected the name of a %s, got %v", t.Name(), value)
+		}
+		return parse(name)
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return convertConfigEnums(t.Elem(), value)
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		converted := make([]interface{}, len(items))
+		for i, item := range items {
+			var err error
+			if converted[i], err = convertConfigEnums(t.Elem(), item); err != nil {
+				return nil, fmt.Errorf("item #%d: %v", i+1, err)
+			}
+		}
+		return converted, nil
+	case reflect.Map, reflect.Struct:
+		entries, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return value, nil
+		}
+		converted := map[interface{}]interface{}{}
+		for key, entry := range entries {
+			var entryType reflect.Type
+			if t.Kind() == reflect.Map {
+				entryType = t.Elem()
+			} else {
+				field, ok := structFieldForKey(t, key)
+				if !ok {
+					// Unknown keys get rejected by yaml.v2.
+					converted[key] = entry
+					continue
+				}
+				entryType = field.Type
+			}
+			var err error
+			if converted[key], err = convertConfigEnums(entryType, entry); err != nil {
+				return nil, fmt.Errorf("%v: %v", key, err)
+			}
+		}
+		return converted, nil
+	}
+	return value, nil
+}
+
+// structFieldForKey finds the field which yaml.v2 uses for a key,
+// either the name in the yaml tag or the lower case field name.
+func structFieldForKey(t reflect.Type, key interface{}) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+)
+
+func TestLoadConfig(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content string
+		check   func(config TestConfig) interface{}
+		value   interface{}
+		err     string
+	}{
+		"case-insensitive": {
+			content: "ADDRESS: unix:///csi/csi.sock",
+			check:   func(config TestConfig) interface{} { return config.Address },
+			value:   "unix:///csi/csi.sock",
+		},
+		"default kept": {
+			content: "address: /csi.sock",
+			check:   func(config TestConfig) interface{} { return config.IdempotentCount },
+			value:   10,
+		},
+		"duration": {
+			content: "rpcTimeout: 1m30s",
+			check:   func(config TestConfig) interface{} { return config.RPCTimeout },
+			value:   90 * time.Second,
+		},
+		"json": {
+			content: `{"testVolumeSize": 1073741824, "dryRun": true}`,
+			check:   func(config TestConfig) interface{} { return []interface{}{config.TestVolumeSize, config.DryRun} },
+			value:   []interface{}{int64(1073741824), true},
+		},
+		"access modes": {
+			content: "testVolumeAccessModes: [SINGLE_NODE_WRITER, MULTI_NODE_READER_ONLY]",
+			check:   func(config TestConfig) interface{} { return config.TestVolumeAccessModes },
+			value:   []csi.VolumeCapability_AccessMode_Mode{csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER, csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+		},
+		"access mode number": {
+			content: "testVolumeAccessModes: [1]",
+			err:     "expected the name",
+		},
+		"unknown access mode": {
+			content: "testVolumeAccessModes: [SINGLE_NODE_WRITE]",
+			err:     `unknown access mode "SINGLE_NODE_WRITE"`,
+		},
+		"access mode UNKNOWN": {
+			content: "testVolumeAccessModes: [UNKNOWN]",
+			err:     "unknown access mode",
+		},
+		"retry policy": {
+			content: "retryPolicy:\n  maxattempts: 3\n  initialbackoff: 1s\n  retryablecodes: [Unavailable, Aborted]",
+			check:   func(config TestConfig) interface{} { return config.RetryPolicy },
+			value:   RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, RetryableCodes: []codes.Code{codes.Unavailable, codes.Aborted}},
+		},
+		"unknown code": {
+			content: "retryPolicy:\n  retryablecodes: [Unavailble]",
+			err:     `unknown gRPC status code "Unavailble"`,
+		},
+		"unknown nested key": {
+			content: "retryPolicy:\n  attempts: 3",
+			err:     "field attempts not found",
+		},
+		"leak check": {
+			content: "leakCheck: fail",
+			check:   func(config TestConfig) interface{} { return config.LeakCheck },
+			value:   LeakCheckFail,
+		},
+		"unknown leak check": {
+			content: "leakCheck: error",
+			err:     `unknown leak check mode "error"`,
+		},
+		"unimplemented policies": {
+			content: "unimplementedPolicies:\n  NodeGetVolumeStats: skip\n  ListVolumes: fail",
+			check:   func(config TestConfig) interface{} { return config.UnimplementedPolicies },
+			value:   map[string]UnimplementedPolicy{"NodeGetVolumeStats": UnimplementedSkip, "ListVolumes": UnimplementedFail},
+		},
+		"unknown unimplemented policy": {
+			content: "unimplementedPolicies:\n  NodeGetVolumeStats: ignore",
+			err:     `NodeGetVolumeStats: unknown policy "ignore"`,
+		},
+		"secrets": {
+			content: "secrets:\n  CreateVolumeSecret:\n    key: value",
+			check:   func(config TestConfig) interface{} { return config.Secrets.CreateVolumeSecret },
+			value:   map[string]string{"key": "value"},
+		},
+		"string map": {
+			content: "testVolumeParameters:\n  type: thin",
+			check:   func(config TestConfig) interface{} { return config.TestVolumeParameters },
+			value:   map[string]string{"type": "thin"},
+		},
+		"unknown field": {
+			content: "endpoint: /csi.sock",
+			err:     `unknown config field "endpoint"`,
+		},
+		"wrong type": {
+			content: "testVolumeSize: big",
+			err:     `config field "testVolumeSize"`,
+		},
+		"function": {
+			content: "onFailure: foo",
+			err:     "cannot be set in a file",
+		},
+		"interface": {
+			content: "idGen: foo",
+			err:     "cannot be set in a file",
+		},
+		"interface slice": {
+			content: "dialOptions: []",
+			err:     "cannot be set in a file",
+		},
+		"func map": {
+			content: "secretsTemplateFuncs:\n  foo: bar",
+			err:     "cannot be set in a file",
+		},
+		"func valued map": {
+			content: "responseValidators: {}",
+			err:     "cannot be set in a file",
+		},
+		"nested interface": {
+			content: "testVolumeCapabilities: []",
+			err:     "cannot be set in a file",
+		},
+		"invalid yaml": {
+			content: "address: [",
+			err:     "error unmarshaling yaml",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(path)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual := tc.check(config); !reflect.DeepEqual(actual, tc.value) {
+				t.Errorf("expected %#v, got %#v", tc.value, actual)
+			}
+		})
+	}
+}
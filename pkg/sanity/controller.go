@@ -19,6 +19,7 @@ package sanity
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -26,6 +27,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/proto"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -39,12 +41,56 @@ const (
 	DefTestExpandIncrement int64 = 1 * 1024 * 1024 * 1024
 
 	MaxNameLength int = 128
+
+	// MaxIDLength is the maximum size in bytes of volume and node
+	// IDs allowed by the CSI spec.
+	MaxIDLength int = 128
 )
 
+// tooLongID returns an ID which exceeds MaxIDLength.
+func tooLongID() string {
+	return strings.Repeat("x", MaxIDLength+1)
+}
+
 func TestVolumeSize(sc *TestContext) int64 {
 	return sc.Config.TestVolumeSize
 }
 
+// TestVolumeCapability returns the capability that the tests use for
+// their volumes: a copy of the first entry of
+// TestConfig.TestVolumeCapabilities if set, otherwise the first of
+// TestConfig.TestVolumeAccessModes (SINGLE_NODE_WRITER by default)
+// with TestConfig.TestVolumeAccessType.
+func TestVolumeCapability(sc *TestContext) *csi.VolumeCapability {
+	if len(sc.Config.TestVolumeCapabilities) > 0 {
+		return proto.Clone(sc.Config.TestVolumeCapabilities[0]).(*csi.VolumeCapability)
+	}
+	mode := csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER
+	if len(sc.Config.TestVolumeAccessModes) > 0 {
+		mode = sc.Config.TestVolumeAccessModes[0]
+	}
+	return TestVolumeCapabilityWithAccessType(sc, mode)
+}
+
+// testVolumeCapabilities returns all configured capabilities, which
+// are requested when creating the volumes for the full flow.
+func testVolumeCapabilities(sc *TestContext) []*csi.VolumeCapability {
+	var caps []*csi.VolumeCapability
+	switch {
+	case len(sc.Config.TestVolumeCapabilities) > 0:
+		for _, c := range sc.Config.TestVolumeCapabilities {
+			caps = append(caps, proto.Clone(c).(*csi.VolumeCapability))
+		}
+	case len(sc.Config.TestVolumeAccessModes) > 0:
+		for _, mode := range sc.Config.TestVolumeAccessModes {
+			caps = append(caps, TestVolumeCapabilityWithAccessType(sc, mode))
+		}
+	default:
+		caps = append(caps, TestVolumeCapability(sc))
+	}
+	return caps
+}
+
 func TestVolumeCapabilityWithAccessType(sc *TestContext, m csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability {
 	vc := &csi.VolumeCapability{
 		AccessMode: &csi.VolumeCapability_AccessMode{Mode: m},
@@ -105,7 +151,7 @@ func isControllerCapabilitySupported(
 	return false
 }
 
-var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestContext) {
+var _ = describeParameterized("Controller Service [Controller Server]", func(sc *TestContext) {
 	var r *Resources
 
 	BeforeEach(func() {
@@ -121,7 +167,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 	})
 
 	Describe("ControllerGetCapabilities", func() {
-		It("should return appropriate capabilities", func() {
+		It(SpecID("controllergetcapabilities/capabilities", "should return appropriate capabilities"), func() {
 			caps, err := r.ControllerGetCapabilities(
 				context.Background(),
 				&csi.ControllerGetCapabilitiesRequest{})
@@ -158,11 +204,11 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 	Describe("GetCapacity", func() {
 		BeforeEach(func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_GET_CAPACITY) {
-				Skip("GetCapacity not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_GET_CAPACITY), "GetCapacity not supported")
 			}
 		})
 
-		It("should return capacity (no optional values added)", func() {
+		It(SpecID("getcapacity/capacity", "should return capacity (no optional values added)"), func() {
 			_, err := r.GetCapacity(
 				context.Background(),
 				&csi.GetCapacityRequest{})
@@ -171,15 +217,36 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			// Since capacity is int64 we will not be checking it
 			// The value of zero is a possible value.
 		})
+
+		It(SpecID("getcapacity/consistent", "should return consistent capacity in repeated calls"), func() {
+			const calls = 5
+			var min, max int64
+			for i := 0; i < calls; i++ {
+				rsp, err := r.GetCapacity(
+					context.Background(),
+					&csi.GetCapacityRequest{})
+				Expect(err).NotTo(HaveOccurred())
+				capacity := rsp.GetAvailableCapacity()
+				Expect(capacity).To(BeNumerically(">=", 0), "negative available capacity")
+				if i == 0 || capacity < min {
+					min = capacity
+				}
+				if i == 0 || capacity > max {
+					max = capacity
+				}
+			}
+			Expect(float64(max-min)).To(BeNumerically("<=", sc.Config.CapacityTolerance*float64(max)),
+				"available capacity varied between %d and %d bytes in %d calls, more than the tolerance of %.0f%%", min, max, calls, sc.Config.CapacityTolerance*100)
+		})
 	})
 	Describe("ListVolumes", func() {
 		BeforeEach(func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_LIST_VOLUMES) {
-				Skip("ListVolumes not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_LIST_VOLUMES), "ListVolumes not supported")
 			}
 		})
 
-		It("should return appropriate values (no optional values added)", func() {
+		It(SpecID("listvolumes/values", "should return appropriate values (no optional values added)"), func() {
 			vols, err := r.ListVolumes(
 				context.Background(),
 				&csi.ListVolumesRequest{})
@@ -191,7 +258,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			}
 		})
 
-		It("should fail when an invalid starting_token is passed", func() {
+		It(SpecID("listvolumes/invalid-starting-token", "should fail when an invalid starting_token is passed"), func() {
 			vols, err := r.ListVolumes(
 				context.Background(),
 				&csi.ListVolumesRequest{
@@ -206,7 +273,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.Aborted), "unexpected error: %s", serverError.Message())
 		})
 
-		It("check the presence of new volumes and absence of deleted ones in the volume list", func() {
+		It(SpecID("listvolumes/new-and-deleted", "check the presence of new volumes and absence of deleted ones in the volume list"), func() {
 			// List Volumes before creating new volume.
 			vols, err := r.ListVolumes(
 				context.Background(),
@@ -223,7 +290,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			req := &csi.CreateVolumeRequest{
 				Name: name,
 				VolumeCapabilities: []*csi.VolumeCapability{
-					TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					TestVolumeCapability(sc),
 				},
 				Secrets:    sc.Secrets.CreateVolumeSecret,
 				Parameters: sc.Config.TestVolumeParameters,
@@ -258,6 +325,44 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(len(vols.GetEntries())).To(Equal(totalVols))
 		})
 
+		It(SpecID("listvolumes/published-nodes", "should report the nodes on which a volume is published"), func() {
+			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES) {
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES), "ListVolumes published nodes not supported")
+			}
+			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME) {
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME), "ControllerPublishVolume not supported")
+			}
+
+			By("creating a volume")
+			vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-list-published-nodes")))
+			volID := vol.GetVolume().GetVolumeId()
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+			Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+			By("controller publishing the volume")
+			r.MustControllerPublishVolume(context.Background(), MakeControllerPublishVolumeReq(sc, volID, nid.GetNodeId()))
+
+			By("listing the volume")
+			entry := findVolumeEntry(r, volID)
+			Expect(entry).NotTo(BeNil(), "volume %s not listed", volID)
+			Expect(entry.GetStatus().GetPublishedNodeIds()).To(ContainElement(nid.GetNodeId()), "volume %s not reported as published on node %s", volID, nid.GetNodeId())
+
+			By("controller unpublishing the volume")
+			_, err = r.ControllerUnpublishVolume(context.Background(), MakeControllerUnpublishVolumeReq(sc, volID, nid.GetNodeId()))
+			Expect(err).NotTo(HaveOccurred())
+
+			By("listing the volume again")
+			entry = findVolumeEntry(r, volID)
+			Expect(entry).NotTo(BeNil(), "volume %s not listed", volID)
+			Expect(entry.GetStatus().GetPublishedNodeIds()).NotTo(ContainElement(nid.GetNodeId()), "volume %s still reported as published on node %s", volID, nid.GetNodeId())
+		})
+
 		// Disabling this below case as it is fragile and results are inconsistent
 		// when no of volumes are different. The test might fail on a driver
 		// which implements the pagination based on index just by altering
@@ -265,7 +370,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 		// Related discussion links:
 		//  https://github.com/intel/pmem-csi/pull/424#issuecomment-540499938
 		//  https://github.com/kubernetes-csi/csi-test/issues/223
-		XIt("pagination should detect volumes added between pages and accept tokens when the last volume from a page is deleted", func() {
+		XIt(SpecID("listvolumes/pagination", "pagination should detect volumes added between pages and accept tokens when the last volume from a page is deleted"), func() {
 			// minVolCount is the minimum number of volumes expected to exist,
 			// based on which paginated volume listing is performed.
 			minVolCount := 3
@@ -297,7 +402,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 					req := &csi.CreateVolumeRequest{
 						Name: name,
 						VolumeCapabilities: []*csi.VolumeCapability{
-							TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+							TestVolumeCapability(sc),
 						},
 						Secrets:    sc.Secrets.CreateVolumeSecret,
 						Parameters: sc.Config.TestVolumeParameters,
@@ -341,7 +446,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			req := &csi.CreateVolumeRequest{
 				Name: "new-addition",
 				VolumeCapabilities: []*csi.VolumeCapability{
-					TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					TestVolumeCapability(sc),
 				},
 				Secrets:    sc.Secrets.CreateVolumeSecret,
 				Parameters: sc.Config.TestVolumeParameters,
@@ -369,11 +474,11 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 	Describe("CreateVolume", func() {
 		BeforeEach(func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME) {
-				Skip("CreateVolume not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME), "CreateVolume not supported")
 			}
 		})
 
-		It("should fail when no name is provided", func() {
+		It(SpecID("createvolume/no-name", "should fail when no name is provided"), func() {
 			_, err := r.CreateVolume(
 				context.Background(),
 				&csi.CreateVolumeRequest{
@@ -388,7 +493,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no volume capabilities are provided", func() {
+		It(SpecID("createvolume/no-capabilities", "should fail when no volume capabilities are provided"), func() {
 			name := UniqueString("sanity-controller-create-no-volume-capabilities")
 			_, err := r.CreateVolume(
 				context.Background(),
@@ -406,7 +511,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 		})
 
 		// TODO: whether CreateVolume request with no capacity should fail or not depends on driver implementation
-		It("should return appropriate values SingleNodeWriter NoCapacity", func() {
+		It(SpecID("createvolume/single-node-writer-no-capacity", "should return appropriate values SingleNodeWriter NoCapacity"), func() {
 
 			By("creating a volume")
 			name := UniqueString("sanity-controller-create-single-no-capacity")
@@ -416,7 +521,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: TestVolumeSize(sc),
@@ -427,7 +532,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			)
 		})
 
-		It("should return appropriate values SingleNodeWriter WithCapacity 1Gi", func() {
+		It(SpecID("createvolume/single-node-writer-1gi", "should return appropriate values SingleNodeWriter WithCapacity 1Gi"), func() {
 
 			By("creating a volume")
 			name := UniqueString("sanity-controller-create-single-with-capacity")
@@ -437,7 +542,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: TestVolumeSize(sc),
@@ -454,10 +559,119 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(vol).NotTo(BeNil())
 			Expect(vol.GetVolume()).NotTo(BeNil())
 			Expect(vol.GetVolume().GetVolumeId()).NotTo(BeEmpty())
+			Expect(len(vol.GetVolume().GetVolumeId())).To(BeNumerically("<=", MaxIDLength), "volume ID %q is longer than %d bytes", vol.GetVolume().GetVolumeId(), MaxIDLength)
 			Expect(vol.GetVolume().GetCapacityBytes()).To(Or(BeNumerically(">=", TestVolumeSize(sc)), BeZero()))
 		})
 
-		It("should not fail when requesting to create a volume with already existing name and same capacity", func() {
+		It(SpecID("createvolume/parameters", "should fail with a helpful message when required parameters are missing"), func() {
+			if len(sc.Config.TestVolumeParameters) == 0 {
+				Skip("Config.TestVolumeParameters not set")
+			}
+
+			By("creating a volume with the configured parameters")
+			r.MustCreateVolume(
+				context.Background(),
+				&csi.CreateVolumeRequest{
+					Name: UniqueString("sanity-controller-create-with-parameters"),
+					VolumeCapabilities: []*csi.VolumeCapability{
+						TestVolumeCapability(sc),
+					},
+					CapacityRange: &csi.CapacityRange{
+						RequiredBytes: TestVolumeSize(sc),
+					},
+					Secrets:    sc.Secrets.CreateVolumeSecret,
+					Parameters: sc.Config.TestVolumeParameters,
+				},
+			)
+
+			By("creating a volume without parameters")
+			_, err := r.CreateVolume(
+				context.Background(),
+				&csi.CreateVolumeRequest{
+					Name: UniqueString("sanity-controller-create-without-parameters"),
+					VolumeCapabilities: []*csi.VolumeCapability{
+						TestVolumeCapability(sc),
+					},
+					CapacityRange: &csi.CapacityRange{
+						RequiredBytes: TestVolumeSize(sc),
+					},
+					Secrets:    sc.Secrets.CreateVolumeSecret,
+					Parameters: map[string]string{},
+				},
+			)
+			if err == nil {
+				sc.results.setVolumeParameters("optional")
+				return
+			}
+			sc.results.setVolumeParameters("required")
+
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
+			Expect(serverError.Message()).NotTo(BeEmpty(), "the error must explain which parameters are required")
+		})
+
+		expectInvalidCapacityRange := func(name string, capacityRange *csi.CapacityRange) {
+			By("creating a volume with an invalid capacity range")
+			_, err := r.CreateVolume(
+				context.Background(),
+				&csi.CreateVolumeRequest{
+					Name: UniqueString(name),
+					VolumeCapabilities: []*csi.VolumeCapability{
+						TestVolumeCapability(sc),
+					},
+					CapacityRange: capacityRange,
+					Secrets:       sc.Secrets.CreateVolumeSecret,
+					Parameters:    sc.Config.TestVolumeParameters,
+				},
+			)
+			ExpectWithOffset(1, err).To(HaveOccurred(), "volume with capacity range %v must not be created", capacityRange)
+
+			serverError, ok := status.FromError(err)
+			ExpectWithOffset(1, ok).To(BeTrue())
+			ExpectWithOffset(1, serverError.Code()).To(BeElementOf(codes.InvalidArgument, codes.OutOfRange), "unexpected error: %s", serverError.Message())
+		}
+
+		It(SpecID("createvolume/required-above-limit", "should fail when required bytes are larger than limit bytes"), func() {
+			expectInvalidCapacityRange("sanity-controller-create-required-above-limit", &csi.CapacityRange{
+				RequiredBytes: TestVolumeSize(sc),
+				LimitBytes:    TestVolumeSize(sc) / 2,
+			})
+		})
+
+		It(SpecID("createvolume/empty-capacity-range", "should fail when neither required bytes nor limit bytes are set and the driver has no default"), func() {
+			if !sc.Config.NoDefaultCapacity {
+				Skip("Config.NoDefaultCapacity not set")
+			}
+			expectInvalidCapacityRange("sanity-controller-create-empty-capacity-range", &csi.CapacityRange{})
+		})
+
+		It(SpecID("createvolume/tiny-limit", "should not create a volume larger than a tiny limit"), func() {
+			By("creating a volume with a limit of one byte")
+			limit := int64(1)
+			vol, err := r.CreateVolume(
+				context.Background(),
+				&csi.CreateVolumeRequest{
+					Name: UniqueString("sanity-controller-create-tiny-limit"),
+					VolumeCapabilities: []*csi.VolumeCapability{
+						TestVolumeCapability(sc),
+					},
+					CapacityRange: &csi.CapacityRange{LimitBytes: limit},
+					Secrets:       sc.Secrets.CreateVolumeSecret,
+					Parameters:    sc.Config.TestVolumeParameters,
+				},
+			)
+			if err == nil {
+				// Drivers without a minimum size may create it.
+				Expect(vol.GetVolume().GetCapacityBytes()).To(BeNumerically("<=", limit), "volume exceeds the limit")
+				Skip("driver created a volume with a limit of one byte")
+			}
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(BeElementOf(codes.InvalidArgument, codes.OutOfRange), "unexpected error: %s", serverError.Message())
+		})
+
+		It(SpecID("createvolume/same-name-same-capacity", "should not fail when requesting to create a volume with already existing name and same capacity"), func() {
 
 			By("creating a volume")
 			name := UniqueString("sanity-controller-create-twice")
@@ -468,7 +682,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: size,
@@ -485,7 +699,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: size,
@@ -498,7 +712,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(vol1.GetVolume().GetVolumeId()).To(Equal(vol2.GetVolume().GetVolumeId()))
 		})
 
-		It("should fail when requesting to create a volume with already existing name and different capacity", func() {
+		It(SpecID("createvolume/same-name-different-capacity", "should fail when requesting to create a volume with already existing name and different capacity"), func() {
 
 			By("creating a volume")
 			name := UniqueString("sanity-controller-create-twice-different")
@@ -509,7 +723,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: size1,
@@ -526,7 +740,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: size2,
@@ -542,13 +756,60 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.AlreadyExists), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should not fail when creating volume with maximum-length name", func() {
+		It(SpecID("createvolume/same-name-different-capabilities", "should fail when requesting to create a volume with already existing name and different capabilities"), func() {
+
+			By("creating a volume")
+			name := UniqueString("sanity-controller-create-twice-different-caps")
+			volCap := TestVolumeCapability(sc)
+
+			r.MustCreateVolume(
+				context.Background(),
+				&csi.CreateVolumeRequest{
+					Name:               name,
+					VolumeCapabilities: []*csi.VolumeCapability{volCap},
+					CapacityRange: &csi.CapacityRange{
+						RequiredBytes: TestVolumeSize(sc),
+					},
+					Secrets:    sc.Secrets.CreateVolumeSecret,
+					Parameters: sc.Config.TestVolumeParameters,
+				},
+			)
 
-			nameBytes := make([]byte, MaxNameLength)
-			for i := 0; i < MaxNameLength; i++ {
-				nameBytes[i] = 'a'
+			By("creating a volume with the same name and a different access type")
+			otherCap := &csi.VolumeCapability{
+				AccessMode: volCap.GetAccessMode(),
+				AccessType: &csi.VolumeCapability_Block{
+					Block: &csi.VolumeCapability_BlockVolume{},
+				},
 			}
-			name := string(nameBytes)
+			if volCap.GetBlock() != nil {
+				otherCap.AccessType = &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				}
+			}
+			_, err := r.CreateVolume(
+				context.Background(),
+				&csi.CreateVolumeRequest{
+					Name:               name,
+					VolumeCapabilities: []*csi.VolumeCapability{otherCap},
+					CapacityRange: &csi.CapacityRange{
+						RequiredBytes: TestVolumeSize(sc),
+					},
+					Secrets:    sc.Secrets.CreateVolumeSecret,
+					Parameters: sc.Config.TestVolumeParameters,
+				},
+			)
+			Expect(err).To(HaveOccurred())
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Equal(codes.AlreadyExists), "unexpected error: %s", serverError.Message())
+		})
+
+		It(SpecID("createvolume/max-length-name", "should not fail when creating volume with maximum-length name"), func() {
+
+			// The name is MaxNameLength bytes long once the prefix
+			// and run ID are added.
+			name := uniqueNameOfLength(sc.Config.maxVolumeNameLength(), "sanity-", "a")
 			By("creating a volume")
 			size := TestVolumeSize(sc)
 
@@ -557,7 +818,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: size,
@@ -569,9 +830,55 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(vol.GetVolume().GetCapacityBytes()).To(Or(BeNumerically(">=", size), BeZero()))
 		})
 
-		It("should create volume from an existing source snapshot", func() {
+		// The names are generated in the spec, when the run ID and
+		// the name prefix are known.
+		specialNames := []struct {
+			id, description string
+			name            func() string
+		}{
+			{"unicode-name", "unicode characters", func() string { return UniqueString("sanity-controller-ünïcödé-名前") }},
+			{"name-with-spaces", "spaces", func() string { return UniqueString("sanity controller with spaces") }},
+			{"name-with-slashes", "slashes", func() string { return UniqueString("sanity/controller/with/slashes") }},
+			{"max-length-unicode-name", "maximum length in multi-byte characters", func() string {
+				return uniqueNameOfLength(sc.Config.maxVolumeNameLength(), "sanity-", "ü")
+			}},
+		}
+		for _, special := range specialNames {
+			special := special
+			It(SpecID("createvolume/"+special.id, "should create or reject a volume with a name containing "+special.description), func() {
+				name := special.name()
+
+				By("creating a volume")
+				req := MakeCreateVolumeReq(sc, name)
+				vol, err := r.CreateVolume(context.Background(), req)
+				if err != nil {
+					serverError, ok := status.FromError(err)
+					Expect(ok).To(BeTrue())
+					Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
+					return
+				}
+				Expect(vol.GetVolume().GetVolumeId()).NotTo(BeEmpty())
+
+				By("creating the same volume again")
+				vol2, err := r.CreateVolume(context.Background(), req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(vol2.GetVolume().GetVolumeId()).To(Equal(vol.GetVolume().GetVolumeId()))
+
+				By("creating a volume with a similar plain name")
+				plain := strings.Map(func(r rune) rune {
+					if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+						return r
+					}
+					return '-'
+				}, name)
+				vol3 := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, plain))
+				Expect(vol3.GetVolume().GetVolumeId()).NotTo(Equal(vol.GetVolume().GetVolumeId()), "volumes %q and %q must not be the same", name, plain)
+			})
+		}
+
+		It(SpecID("createvolume/from-snapshot", "should create volume from an existing source snapshot"), func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT) {
-				Skip("Snapshot not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT), "Snapshot not supported")
 			}
 
 			By("creating a snapshot")
@@ -592,9 +899,9 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("should fail when the volume source snapshot is not found", func() {
+		It(SpecID("createvolume/from-missing-snapshot", "should fail when the volume source snapshot is not found"), func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT) {
-				Skip("Snapshot not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT), "Snapshot not supported")
 			}
 
 			By("creating a volume from source snapshot")
@@ -603,7 +910,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			volReq.VolumeContentSource = &csi.VolumeContentSource{
 				Type: &csi.VolumeContentSource_Snapshot{
 					Snapshot: &csi.VolumeContentSource_SnapshotSource{
-						SnapshotId: "non-existing-snapshot-id",
+						SnapshotId: nonExistentSnapshotID(sc),
 					},
 				},
 			}
@@ -614,9 +921,9 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.NotFound), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should create volume from an existing source volume", func() {
+		It(SpecID("createvolume/from-volume", "should create volume from an existing source volume"), func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CLONE_VOLUME) {
-				Skip("Volume Cloning not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CLONE_VOLUME), "Volume Cloning not supported")
 			}
 
 			By("creating a volume")
@@ -638,9 +945,9 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("should fail when the volume source volume is not found", func() {
+		It(SpecID("createvolume/from-missing-volume", "should fail when the volume source volume is not found"), func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CLONE_VOLUME) {
-				Skip("Volume Cloning not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CLONE_VOLUME), "Volume Cloning not supported")
 			}
 
 			By("creating a volume from source snapshot")
@@ -664,11 +971,11 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 	Describe("DeleteVolume", func() {
 		BeforeEach(func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME) {
-				Skip("DeleteVolume not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME), "DeleteVolume not supported")
 			}
 		})
 
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("deletevolume/no-volume-id", "should fail when no volume id is provided"), func() {
 
 			_, err := r.DeleteVolume(
 				context.Background(),
@@ -683,7 +990,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should succeed when an invalid volume id is used", func() {
+		It(SpecID("deletevolume/invalid-volume-id", "should succeed when an invalid volume id is used"), func() {
 
 			_, err := r.DeleteVolume(
 				context.Background(),
@@ -695,7 +1002,36 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("should return appropriate values (no optional values added)", func() {
+		It(SpecID("deletevolume/non-existent-volume", "should succeed when a valid volume id of a non-existent volume is used"), func() {
+
+			_, err := r.DeleteVolume(
+				context.Background(),
+				&csi.DeleteVolumeRequest{
+					VolumeId: sc.Config.IDGen.GenerateUniqueValidVolumeID(),
+					Secrets:  sc.Secrets.DeleteVolumeSecret,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred(), "deleting a volume which does not exist must succeed")
+		})
+
+		It(SpecID("deletevolume/too-long-volume-id", "should handle a volume id which exceeds the maximum length"), func() {
+
+			_, err := r.DeleteVolume(
+				context.Background(),
+				&csi.DeleteVolumeRequest{
+					VolumeId: tooLongID(),
+					Secrets:  sc.Secrets.DeleteVolumeSecret,
+				},
+			)
+			if err == nil {
+				return
+			}
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Or(Equal(codes.InvalidArgument), Equal(codes.NotFound)), "unexpected error: %s", serverError.Message())
+		})
+
+		It(SpecID("deletevolume/values", "should return appropriate values (no optional values added)"), func() {
 
 			// Create Volume First
 			By("creating a volume")
@@ -706,7 +1042,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: TestVolumeSize(sc),
@@ -728,10 +1064,46 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			)
 			Expect(err).NotTo(HaveOccurred())
 		})
+		It(SpecID("deletevolume/published-volume", "should fail when the volume is still controller published"), func() {
+			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME) {
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME), "ControllerPublishVolume not supported")
+			}
+
+			By("creating a volume")
+			vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-controller-delete-published")))
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+			Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+			By("controller publishing the volume")
+			r.MustControllerPublishVolume(
+				context.Background(),
+				MakeControllerPublishVolumeReq(sc, vol.GetVolume().GetVolumeId(), nid.GetNodeId()),
+			)
+
+			By("deleting the published volume")
+			_, err = r.DeleteVolume(
+				context.Background(),
+				&csi.DeleteVolumeRequest{
+					VolumeId: vol.GetVolume().GetVolumeId(),
+					Secrets:  sc.Secrets.DeleteVolumeSecret,
+				},
+			)
+			Expect(err).To(HaveOccurred(), "deleting a volume which is still published must fail")
+
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Equal(codes.FailedPrecondition), "unexpected error: %s", serverError.Message())
+		})
 	})
 
 	Describe("ValidateVolumeCapabilities", func() {
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("validatevolumecapabilities/no-volume-id", "should fail when no volume id is provided"), func() {
 
 			_, err := r.ValidateVolumeCapabilities(
 				context.Background(),
@@ -745,10 +1117,10 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no volume capabilities are provided", func() {
+		It(SpecID("validatevolumecapabilities/no-capabilities", "should fail when no volume capabilities are provided"), func() {
 
 			// Create Volume First
-			By("creating a single node writer volume")
+			By("creating a volume")
 			name := UniqueString("sanity-controller-validate-nocaps")
 
 			vol := r.MustCreateVolume(
@@ -756,7 +1128,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: TestVolumeSize(sc),
@@ -780,10 +1152,10 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should return appropriate values (no optional values added)", func() {
+		It(SpecID("validatevolumecapabilities/values", "should return appropriate values (no optional values added)"), func() {
 
 			// Create Volume First
-			By("creating a single node writer volume")
+			By("creating a volume")
 			name := UniqueString("sanity-controller-validate")
 
 			vol := r.MustCreateVolume(
@@ -791,7 +1163,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: TestVolumeSize(sc),
@@ -808,7 +1180,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.ValidateVolumeCapabilitiesRequest{
 					VolumeId: vol.GetVolume().GetVolumeId(),
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					Secrets: sc.Secrets.ControllerValidateVolumeCapabilitiesSecret,
 				})
@@ -822,14 +1194,67 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			}
 		})
 
-		It("should fail when the requested volume does not exist", func() {
+		expectUnsupportedCapability := func(name string, capability *csi.VolumeCapability) {
+			By("creating a volume")
+			vol := r.MustCreateVolume(
+				context.Background(),
+				&csi.CreateVolumeRequest{
+					Name: UniqueString(name),
+					VolumeCapabilities: []*csi.VolumeCapability{
+						TestVolumeCapability(sc),
+					},
+					CapacityRange: &csi.CapacityRange{
+						RequiredBytes: TestVolumeSize(sc),
+					},
+					Secrets:    sc.Secrets.CreateVolumeSecret,
+					Parameters: sc.Config.TestVolumeParameters,
+				},
+			)
+
+			By("validating unsupported volume capabilities")
+			valivolcap, err := r.ValidateVolumeCapabilities(
+				context.Background(),
+				&csi.ValidateVolumeCapabilitiesRequest{
+					VolumeId:           vol.GetVolume().GetVolumeId(),
+					VolumeCapabilities: []*csi.VolumeCapability{capability},
+					Secrets:            sc.Secrets.ControllerValidateVolumeCapabilitiesSecret,
+				})
+			if err != nil {
+				// Rejecting the request as invalid is also acceptable.
+				serverError, ok := status.FromError(err)
+				ExpectWithOffset(1, ok).To(BeTrue())
+				ExpectWithOffset(1, serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
+				return
+			}
+			ExpectWithOffset(1, valivolcap).NotTo(BeNil())
+			ExpectWithOffset(1, valivolcap.GetConfirmed()).To(BeNil(), "unsupported volume capabilities must not be confirmed")
+			ExpectWithOffset(1, valivolcap.GetMessage()).NotTo(BeEmpty(), "a message must explain why the volume capabilities are not confirmed")
+		}
+
+		It(SpecID("validatevolumecapabilities/unsupported-access-mode", "should not confirm an unsupported access mode"), func() {
+			capability := TestVolumeCapability(sc)
+			// Not a valid value in any version of the CSI spec.
+			capability.AccessMode.Mode = csi.VolumeCapability_AccessMode_Mode(1000)
+			expectUnsupportedCapability("sanity-controller-validate-access-mode", capability)
+		})
+
+		It(SpecID("validatevolumecapabilities/unsupported-fs-type", "should not confirm an unsupported filesystem type"), func() {
+			capability := TestVolumeCapability(sc)
+			if capability.GetMount() == nil {
+				Skip("Filesystem types are only tested with TestVolumeAccessType mount")
+			}
+			capability.GetMount().FsType = "sanity-unsupported-fs"
+			expectUnsupportedCapability("sanity-controller-validate-fs-type", capability)
+		})
+
+		It(SpecID("validatevolumecapabilities/missing-volume", "should fail when the requested volume does not exist"), func() {
 
 			_, err := r.ValidateVolumeCapabilities(
 				context.Background(),
 				&csi.ValidateVolumeCapabilitiesRequest{
 					VolumeId: sc.Config.IDGen.GenerateUniqueValidVolumeID(),
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					Secrets: sc.Secrets.ControllerValidateVolumeCapabilitiesSecret,
 				},
@@ -845,11 +1270,11 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 	Describe("ControllerPublishVolume", func() {
 		BeforeEach(func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME) {
-				Skip("ControllerPublishVolume not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME), "ControllerPublishVolume not supported")
 			}
 		})
 
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("controllerpublishvolume/no-volume-id", "should fail when no volume id is provided"), func() {
 
 			_, err := r.ControllerPublishVolume(
 				context.Background(),
@@ -864,7 +1289,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no node id is provided", func() {
+		It(SpecID("controllerpublishvolume/no-node-id", "should fail when no node id is provided"), func() {
 
 			_, err := r.ControllerPublishVolume(
 				context.Background(),
@@ -880,7 +1305,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no volume capability is provided", func() {
+		It(SpecID("controllerpublishvolume/no-capability", "should fail when no volume capability is provided"), func() {
 
 			_, err := r.ControllerPublishVolume(
 				context.Background(),
@@ -897,7 +1322,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when publishing more volumes than the node max attach limit", func() {
+		It(SpecID("controllerpublishvolume/max-attach-limit", "should fail when publishing more volumes than the node max attach limit"), func() {
 			if !sc.Config.TestNodeVolumeAttachLimit {
 				Skip("testnodevolumeattachlimit not enabled")
 			}
@@ -915,18 +1340,89 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 
 			nid := nodeInfo.GetNodeId()
 			Expect(nid).NotTo(BeEmpty())
+			nodeStageSupported := isNodeCapabilitySupported(r, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME)
+			volCap := TestVolumeCapability(sc)
+
+			// Each volume gets its own staging and target path. They
+			// are unpublished, unstaged and removed in reverse order
+			// when the test ends, even when it fails.
+			var undo []func()
+			defer func() {
+				for i := len(undo) - 1; i >= 0; i-- {
+					undo[i]()
+				}
+			}()
 
-			By("publishing volumes")
+			By(fmt.Sprintf("publishing %d volumes", nodeInfo.MaxVolumesPerNode))
 			for i := int64(0); i < nodeInfo.MaxVolumesPerNode; i++ {
 				name := UniqueString(fmt.Sprintf("sanity-max-attach-limit-vol-%d", i))
 				vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, name))
 				volID := vol.GetVolume().GetVolumeId()
-				r.MustControllerPublishVolume(
+				conpubvol := r.MustControllerPublishVolume(
 					context.Background(),
 					MakeControllerPublishVolumeReq(sc, volID, nid),
 				)
+
+				var stagingPath string
+				if nodeStageSupported {
+					// The staging paths are siblings of the
+					// configured one, not inside it.
+					stagingPath, err = createMountTargetLocation(fmt.Sprintf("%s-%d", filepath.Clean(sc.Config.StagingPath), i), sc.Config.CreateStagingPathCmd, sc.Config.CreateStagingDir, sc.Config.CreatePathCmdTimeout)
+					Expect(err).NotTo(HaveOccurred(), "failed to create staging directory %s", stagingPath)
+					path := stagingPath
+					undo = append(undo, func() {
+						removeMountTargetLocation(path, sc.Config.RemoveStagingPathCmd, sc.Config.RemoveStagingPath, sc.Config.RemovePathCmdTimeout)
+					})
+
+					_, err = r.NodeStageVolume(
+						context.Background(),
+						&csi.NodeStageVolumeRequest{
+							VolumeId:          volID,
+							VolumeCapability:  volCap,
+							StagingTargetPath: stagingPath,
+							VolumeContext:     vol.GetVolume().GetVolumeContext(),
+							PublishContext:    conpubvol.GetPublishContext(),
+							Secrets:           sc.Secrets.NodeStageVolumeSecret,
+						},
+					)
+					Expect(err).NotTo(HaveOccurred(), "staging volume %d of %d", i+1, nodeInfo.MaxVolumesPerNode)
+					undo = append(undo, func() {
+						r.NodeUnstageVolume(
+							context.Background(),
+							&csi.NodeUnstageVolumeRequest{
+								VolumeId:          volID,
+								StagingTargetPath: path,
+							},
+						)
+					})
+				}
+
+				targetPath := filepath.Join(sc.TargetPath, fmt.Sprintf("target-%d", i))
+				_, err = r.NodePublishVolume(
+					context.Background(),
+					&csi.NodePublishVolumeRequest{
+						VolumeId:          volID,
+						TargetPath:        targetPath,
+						StagingTargetPath: stagingPath,
+						VolumeCapability:  volCap,
+						VolumeContext:     vol.GetVolume().GetVolumeContext(),
+						PublishContext:    conpubvol.GetPublishContext(),
+						Secrets:           sc.Secrets.NodePublishVolumeSecret,
+					},
+				)
+				Expect(err).NotTo(HaveOccurred(), "publishing volume %d of %d", i+1, nodeInfo.MaxVolumesPerNode)
+				undo = append(undo, func() {
+					r.NodeUnpublishVolume(
+						context.Background(),
+						&csi.NodeUnpublishVolumeRequest{
+							VolumeId:   volID,
+							TargetPath: targetPath,
+						},
+					)
+				})
 			}
 
+			By("publishing one more volume")
 			extraVolName := UniqueString("sanity-max-attach-limit-vol+1")
 			vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, extraVolName))
 
@@ -934,10 +1430,14 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				context.Background(),
 				MakeControllerPublishVolumeReq(sc, vol.Volume.VolumeId, nid),
 			)
-			Expect(err).To(HaveOccurred())
+			Expect(err).To(HaveOccurred(), "publishing more than %d volumes must fail", nodeInfo.MaxVolumesPerNode)
+
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Equal(codes.ResourceExhausted), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when the volume does not exist", func() {
+		It(SpecID("controllerpublishvolume/missing-volume", "should fail when the volume does not exist"), func() {
 
 			By("calling controller publish on a non-existent volume")
 
@@ -946,7 +1446,36 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.ControllerPublishVolumeRequest{
 					VolumeId:         sc.Config.IDGen.GenerateUniqueValidVolumeID(),
 					NodeId:           sc.Config.IDGen.GenerateUniqueValidNodeID(),
-					VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					VolumeCapability: TestVolumeCapability(sc),
+					Readonly:         false,
+					Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
+				},
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(conpubvol).To(BeNil())
+
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Equal(codes.NotFound), "unexpected error: %s", serverError.Message())
+		})
+
+		It(SpecID("controllerpublishvolume/missing-volume-existing-node", "should fail when the volume does not exist on an existing node"), func() {
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+			Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+			By("calling controller publish on a non-existent volume")
+			conpubvol, err := r.ControllerPublishVolume(
+				context.Background(),
+				&csi.ControllerPublishVolumeRequest{
+					VolumeId:         sc.Config.IDGen.GenerateUniqueValidVolumeID(),
+					NodeId:           nid.GetNodeId(),
+					VolumeCapability: TestVolumeCapability(sc),
 					Readonly:         false,
 					Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
 				},
@@ -959,10 +1488,10 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.NotFound), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when the node does not exist", func() {
+		It(SpecID("controllerpublishvolume/missing-node", "should fail when the node does not exist"), func() {
 
 			// Create Volume First
-			By("creating a single node writer volume")
+			By("creating a volume")
 			name := UniqueString("sanity-controller-wrong-node")
 
 			vol := r.MustCreateVolume(
@@ -970,7 +1499,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					Secrets:    sc.Secrets.CreateVolumeSecret,
 					Parameters: sc.Config.TestVolumeParameters,
@@ -985,7 +1514,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.ControllerPublishVolumeRequest{
 					VolumeId:         vol.GetVolume().GetVolumeId(),
 					NodeId:           sc.Config.IDGen.GenerateUniqueValidNodeID(),
-					VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					VolumeCapability: TestVolumeCapability(sc),
 					Readonly:         false,
 					Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
 				},
@@ -998,13 +1527,13 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			Expect(serverError.Code()).To(Equal(codes.NotFound), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when the volume is already published but is incompatible", func() {
+		It(SpecID("controllerpublishvolume/incompatible", "should fail when the volume is already published but is incompatible"), func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_READONLY) {
-				Skip("ControllerPublishVolume.readonly field not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_READONLY), "ControllerPublishVolume.readonly field not supported")
 			}
 
 			// Create Volume First
-			By("creating a single node writer volume")
+			By("creating a volume")
 			name := UniqueString("sanity-controller-published-incompatible")
 
 			vol := r.MustCreateVolume(
@@ -1012,7 +1541,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+						TestVolumeCapability(sc),
 					},
 					Secrets:    sc.Secrets.CreateVolumeSecret,
 					Parameters: sc.Config.TestVolumeParameters,
@@ -1033,7 +1562,7 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 			pubReq := &csi.ControllerPublishVolumeRequest{
 				VolumeId:         vol.GetVolume().GetVolumeId(),
 				NodeId:           nid.GetNodeId(),
-				VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+				VolumeCapability: TestVolumeCapability(sc),
 				Readonly:         false,
 				Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
 			}
@@ -1056,15 +1585,15 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 	Describe("volume lifecycle", func() {
 		BeforeEach(func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME) {
-				Skip("Controller Publish, UnpublishVolume not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME), "Controller Publish, UnpublishVolume not supported")
 			}
 		})
 
-		It("should work", func() {
+		It(SpecID("controller/lifecycle", "should work"), func() {
 			VolumeLifecycle(r, sc, 1)
 		})
 
-		It("should be idempotent", func() {
+		It(SpecID("controller/lifecycle-idempotent", "should be idempotent"), func() {
 			VolumeLifecycle(r, sc, sc.Config.IdempotentCount)
 		})
 	})
@@ -1072,11 +1601,11 @@ var _ = DescribeSanity("Controller Service [Controller Server]", func(sc *TestCo
 	Describe("ControllerUnpublishVolume", func() {
 		BeforeEach(func() {
 			if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME) {
-				Skip("ControllerUnpublishVolume not supported")
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME), "ControllerUnpublishVolume not supported")
 			}
 		})
 
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("controllerunpublishvolume/no-volume-id", "should fail when no volume id is provided"), func() {
 
 			_, err := r.ControllerUnpublishVolume(
 				context.Background(),
@@ -1104,7 +1633,7 @@ var _ = DescribeSanity("ListSnapshots [Controller Server]", func(sc *TestContext
 		}
 
 		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS) {
-			Skip("ListSnapshots not supported")
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS), "ListSnapshots not supported")
 		}
 	})
 
@@ -1112,7 +1641,7 @@ var _ = DescribeSanity("ListSnapshots [Controller Server]", func(sc *TestContext
 		r.Cleanup()
 	})
 
-	It("should return appropriate values (no optional values added)", func() {
+	It(SpecID("listsnapshots/values", "should return appropriate values (no optional values added)"), func() {
 
 		req := &csi.ListSnapshotsRequest{}
 
@@ -1129,7 +1658,7 @@ var _ = DescribeSanity("ListSnapshots [Controller Server]", func(sc *TestContext
 		}
 	})
 
-	It("should return snapshots that match the specified snapshot id", func() {
+	It(SpecID("listsnapshots/by-snapshot-id", "should return snapshots that match the specified snapshot id"), func() {
 		// The test creates three snapshots: one that we intend to find by
 		// snapshot ID, and two unrelated ones that must not be returned by
 		// ListSnapshots.
@@ -1165,9 +1694,9 @@ var _ = DescribeSanity("ListSnapshots [Controller Server]", func(sc *TestContext
 		Expect(snapshots.GetEntries()[0].GetSnapshot().GetSnapshotId()).To(Equal(snapshotTarget.GetSnapshot().GetSnapshotId()))
 	})
 
-	It("should return empty when the specified snapshot id does not exist", func() {
+	It(SpecID("listsnapshots/missing-snapshot-id", "should return empty when the specified snapshot id does not exist"), func() {
 
-		req := &csi.ListSnapshotsRequest{SnapshotId: "none-exist-id"}
+		req := &csi.ListSnapshotsRequest{SnapshotId: nonExistentSnapshotID(sc)}
 
 		if sc.Secrets != nil {
 			req.Secrets = sc.Secrets.ListSnapshotsSecret
@@ -1179,7 +1708,7 @@ var _ = DescribeSanity("ListSnapshots [Controller Server]", func(sc *TestContext
 		Expect(snapshots.GetEntries()).To(BeEmpty())
 	})
 
-	It("should return snapshots that match the specified source volume id", func() {
+	It(SpecID("listsnapshots/by-source-volume-id", "should return snapshots that match the specified source volume id"), func() {
 
 		// The test creates three snapshots: one that we intend to find by
 		// source volume ID, and two unrelated ones that must not be returned by
@@ -1217,7 +1746,7 @@ var _ = DescribeSanity("ListSnapshots [Controller Server]", func(sc *TestContext
 		Expect(snapshot.GetSourceVolumeId()).To(Equal(snapshotTarget.GetSnapshot().GetSourceVolumeId()))
 	})
 
-	It("should return empty when the specified source volume id does not exist", func() {
+	It(SpecID("listsnapshots/missing-source-volume-id", "should return empty when the specified source volume id does not exist"), func() {
 
 		req := &csi.ListSnapshotsRequest{SourceVolumeId: sc.Config.IDGen.GenerateUniqueValidVolumeID()}
 
@@ -1231,7 +1760,48 @@ var _ = DescribeSanity("ListSnapshots [Controller Server]", func(sc *TestContext
 		Expect(snapshots.GetEntries()).To(BeEmpty())
 	})
 
-	It("check the presence of new snapshots in the snapshot list", func() {
+	It(SpecID("listsnapshots/by-snapshot-and-source-volume-id", "should return snapshots that match both the specified snapshot id and source volume id"), func() {
+
+		// The test creates two snapshots from different volumes and
+		// combines the ID of one with the source volume of the other.
+
+		By("creating target snapshot")
+		volReq := MakeCreateVolumeReq(sc, "listSnapshots-volume-combined-target")
+		snapshotTarget, _ := r.MustCreateSnapshotFromVolumeRequest(context.Background(), volReq, "listSnapshots-snapshot-combined-target")
+
+		By("creating unrelated snapshot")
+		volReq = MakeCreateVolumeReq(sc, "listSnapshots-volume-combined-unrelated")
+		snapshotUnrelated, _ := r.MustCreateSnapshotFromVolumeRequest(context.Background(), volReq, "listSnapshots-snapshot-combined-unrelated")
+
+		By("listing snapshots with matching filters")
+
+		req := &csi.ListSnapshotsRequest{
+			SnapshotId:     snapshotTarget.GetSnapshot().GetSnapshotId(),
+			SourceVolumeId: snapshotTarget.GetSnapshot().GetSourceVolumeId(),
+		}
+
+		if sc.Secrets != nil {
+			req.Secrets = sc.Secrets.ListSnapshotsSecret
+		}
+
+		snapshots, err := r.ListSnapshots(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).NotTo(BeNil())
+		Expect(snapshots.GetEntries()).To(HaveLen(1))
+		verifySnapshotInfo(snapshots.GetEntries()[0].GetSnapshot())
+		Expect(snapshots.GetEntries()[0].GetSnapshot().GetSnapshotId()).To(Equal(snapshotTarget.GetSnapshot().GetSnapshotId()))
+
+		By("listing snapshots with contradicting filters")
+
+		req.SourceVolumeId = snapshotUnrelated.GetSnapshot().GetSourceVolumeId()
+
+		snapshots, err = r.ListSnapshots(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).NotTo(BeNil())
+		Expect(snapshots.GetEntries()).To(BeEmpty(), "snapshot %s does not belong to volume %s", req.SnapshotId, req.SourceVolumeId)
+	})
+
+	It(SpecID("listsnapshots/new-snapshots", "check the presence of new snapshots in the snapshot list"), func() {
 		// List Snapshots before creating new snapshots.
 
 		req := &csi.ListSnapshotsRequest{}
@@ -1271,7 +1841,7 @@ var _ = DescribeSanity("ListSnapshots [Controller Server]", func(sc *TestContext
 		Expect(snapshots.GetEntries()).To(HaveLen(totalSnapshots))
 	})
 
-	It("should return next token when a limited number of entries are requested", func() {
+	It(SpecID("listsnapshots/next-token", "should return next token when a limited number of entries are requested"), func() {
 		// minSnapshotCount is the minimum number of snapshots expected to exist,
 		// based on which paginated snapshot listing is performed.
 		minSnapshotCount := 5
@@ -1355,7 +1925,7 @@ var _ = DescribeSanity("DeleteSnapshot [Controller Server]", func(sc *TestContex
 		}
 
 		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT) {
-			Skip("DeleteSnapshot not supported")
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT), "DeleteSnapshot not supported")
 		}
 	})
 
@@ -1363,7 +1933,7 @@ var _ = DescribeSanity("DeleteSnapshot [Controller Server]", func(sc *TestContex
 		r.Cleanup()
 	})
 
-	It("should fail when no snapshot id is provided", func() {
+	It(SpecID("deletesnapshot/no-snapshot-id", "should fail when no snapshot id is provided"), func() {
 
 		req := &csi.DeleteSnapshotRequest{}
 
@@ -1379,14 +1949,25 @@ var _ = DescribeSanity("DeleteSnapshot [Controller Server]", func(sc *TestContex
 		Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 	})
 
-	It("should succeed when an invalid snapshot id is used", func() {
+	It(SpecID("deletesnapshot/invalid-snapshot-id", "should succeed when an invalid snapshot id is used"), func() {
 
 		req := MakeDeleteSnapshotReq(sc, "reallyfakesnapshotid")
 		_, err := r.DeleteSnapshot(context.Background(), req)
 		Expect(err).NotTo(HaveOccurred())
 	})
 
-	It("should return appropriate values (no optional values added)", func() {
+	It(SpecID("deletesnapshot/non-existent-snapshot", "should succeed when a valid snapshot id of a non-existent snapshot is used"), func() {
+		idGen, ok := sc.Config.IDGen.(SnapshotIDGenerator)
+		if !ok {
+			Skip("Config.IDGen does not implement SnapshotIDGenerator")
+		}
+
+		req := MakeDeleteSnapshotReq(sc, idGen.GenerateUniqueValidSnapshotID())
+		_, err := r.DeleteSnapshot(context.Background(), req)
+		Expect(err).NotTo(HaveOccurred(), "deleting a snapshot which does not exist must succeed")
+	})
+
+	It(SpecID("deletesnapshot/values", "should return appropriate values (no optional values added)"), func() {
 
 		By("creating a volume")
 		volReq := MakeCreateVolumeReq(sc, "DeleteSnapshot-volume-1")
@@ -1411,7 +1992,7 @@ var _ = DescribeSanity("CreateSnapshot [Controller Server]", func(sc *TestContex
 		}
 
 		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT) {
-			Skip("CreateSnapshot not supported")
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT), "CreateSnapshot not supported")
 		}
 	})
 
@@ -1419,10 +2000,11 @@ var _ = DescribeSanity("CreateSnapshot [Controller Server]", func(sc *TestContex
 		r.Cleanup()
 	})
 
-	It("should fail when no name is provided", func() {
+	It(SpecID("createsnapshot/no-name", "should fail when no name is provided"), func() {
 
 		req := &csi.CreateSnapshotRequest{
 			SourceVolumeId: "testId",
+			Parameters:     sc.Config.TestSnapshotParameters,
 		}
 
 		if sc.Secrets != nil {
@@ -1436,10 +2018,11 @@ var _ = DescribeSanity("CreateSnapshot [Controller Server]", func(sc *TestContex
 		Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 	})
 
-	It("should fail when no source volume id is provided", func() {
+	It(SpecID("createsnapshot/no-source-volume-id", "should fail when no source volume id is provided"), func() {
 
 		req := &csi.CreateSnapshotRequest{
-			Name: "name",
+			Name:       "name",
+			Parameters: sc.Config.TestSnapshotParameters,
 		}
 
 		if sc.Secrets != nil {
@@ -1453,7 +2036,7 @@ var _ = DescribeSanity("CreateSnapshot [Controller Server]", func(sc *TestContex
 		Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 	})
 
-	It("should succeed when requesting to create a snapshot with already existing name and same source volume ID", func() {
+	It(SpecID("createsnapshot/same-name-same-source", "should succeed when requesting to create a snapshot with already existing name and same source volume ID"), func() {
 
 		By("creating a volume")
 		volReq := MakeCreateVolumeReq(sc, "CreateSnapshot-volume-1")
@@ -1461,13 +2044,17 @@ var _ = DescribeSanity("CreateSnapshot [Controller Server]", func(sc *TestContex
 
 		By("creating a snapshot")
 		snapReq1 := MakeCreateSnapshotReq(sc, "CreateSnapshot-snapshot-1", volume.GetVolume().GetVolumeId())
-		r.MustCreateSnapshot(context.Background(), snapReq1)
+		snap1 := r.MustCreateSnapshot(context.Background(), snapReq1)
 
 		By("creating a snapshot with the same name and source volume ID")
-		r.MustCreateSnapshot(context.Background(), snapReq1)
+		snap2 := r.MustCreateSnapshot(context.Background(), snapReq1)
+
+		By("verifying that the same snapshot was returned")
+		Expect(snap2.GetSnapshot().GetSnapshotId()).To(Equal(snap1.GetSnapshot().GetSnapshotId()))
+		Expect(snap2.GetSnapshot().GetSourceVolumeId()).To(Equal(volume.GetVolume().GetVolumeId()))
 	})
 
-	It("should fail when requesting to create a snapshot with already existing name and different source volume ID", func() {
+	It(SpecID("createsnapshot/same-name-different-source", "should fail when requesting to create a snapshot with already existing name and different source volume ID"), func() {
 
 		By("creating a snapshot")
 		volReq := MakeCreateVolumeReq(sc, "CreateSnapshot-volume-2")
@@ -1486,17 +2073,15 @@ var _ = DescribeSanity("CreateSnapshot [Controller Server]", func(sc *TestContex
 		Expect(serverError.Code()).To(Equal(codes.AlreadyExists), "unexpected error: %s", serverError.Message())
 	})
 
-	It("should succeed when creating snapshot with maximum-length name", func() {
+	It(SpecID("createsnapshot/max-length-name", "should succeed when creating snapshot with maximum-length name"), func() {
 
 		By("creating a volume")
 		volReq := MakeCreateVolumeReq(sc, "CreateSnapshot-volume-3")
 		volume := r.MustCreateVolume(context.Background(), volReq)
 
-		nameBytes := make([]byte, MaxNameLength)
-		for i := 0; i < MaxNameLength; i++ {
-			nameBytes[i] = 'a'
-		}
-		name := string(nameBytes)
+		// The name is MaxNameLength bytes long once the run ID is
+		// added.
+		name := uniqueNameOfLength(sc.Config.maxSnapshotNameLength(), "sanity-", "a")
 
 		By("creating a snapshot")
 		snapReq1 := MakeCreateSnapshotReq(sc, name, volume.GetVolume().GetVolumeId())
@@ -1517,7 +2102,7 @@ var _ = DescribeSanity("ExpandVolume [Controller Server]", func(sc *TestContext)
 		}
 
 		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_EXPAND_VOLUME) {
-			Skip("ControllerExpandVolume not supported")
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_EXPAND_VOLUME), "ControllerExpandVolume not supported")
 		}
 	})
 
@@ -1525,7 +2110,7 @@ var _ = DescribeSanity("ExpandVolume [Controller Server]", func(sc *TestContext)
 		r.Cleanup()
 	})
 
-	It("should fail if no volume id is given", func() {
+	It(SpecID("controllerexpandvolume/no-volume-id", "should fail if no volume id is given"), func() {
 		expReq := &csi.ControllerExpandVolumeRequest{
 			VolumeId: "",
 			CapacityRange: &csi.CapacityRange{
@@ -1542,7 +2127,7 @@ var _ = DescribeSanity("ExpandVolume [Controller Server]", func(sc *TestContext)
 		Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 	})
 
-	It("should fail if no capacity range is given", func() {
+	It(SpecID("controllerexpandvolume/no-capacity-range", "should fail if no capacity range is given"), func() {
 		expReq := &csi.ControllerExpandVolumeRequest{
 			VolumeId: "",
 			Secrets:  sc.Secrets.ControllerExpandVolumeSecret,
@@ -1556,7 +2141,7 @@ var _ = DescribeSanity("ExpandVolume [Controller Server]", func(sc *TestContext)
 		Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 	})
 
-	It("should work", func() {
+	It(SpecID("controllerexpandvolume/expand", "should work"), func() {
 
 		By("creating a new volume")
 		name := UniqueString("sanity-expand-volume")
@@ -1565,7 +2150,7 @@ var _ = DescribeSanity("ExpandVolume [Controller Server]", func(sc *TestContext)
 		req := &csi.CreateVolumeRequest{
 			Name: name,
 			VolumeCapabilities: []*csi.VolumeCapability{
-				TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+				TestVolumeCapability(sc),
 			},
 			Parameters: sc.Config.TestVolumeParameters,
 			Secrets:    sc.Secrets.CreateVolumeSecret,
@@ -1582,23 +2167,75 @@ var _ = DescribeSanity("ExpandVolume [Controller Server]", func(sc *TestContext)
 				RequiredBytes: TestVolumeExpandSize(sc),
 			},
 			Secrets:          sc.Secrets.ControllerExpandVolumeSecret,
-			VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+			VolumeCapability: TestVolumeCapability(sc),
 		}
 		rsp, err := r.ControllerExpandVolume(context.Background(), expReq)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(rsp).NotTo(BeNil())
 		Expect(rsp.GetCapacityBytes()).To(Equal(TestVolumeExpandSize(sc)))
 	})
+
+	It(SpecID("controllerexpandvolume/shrink", "should not shrink a volume"), func() {
+
+		By("creating a new volume")
+		vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-shrink-volume")))
+		size := vol.GetVolume().GetCapacityBytes()
+		if size == 0 {
+			size = TestVolumeSize(sc)
+		}
+
+		By("requesting a smaller size")
+		rsp, err := r.ControllerExpandVolume(
+			context.Background(),
+			&csi.ControllerExpandVolumeRequest{
+				VolumeId: vol.GetVolume().GetVolumeId(),
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: size / 2,
+				},
+				Secrets:          sc.Secrets.ControllerExpandVolumeSecret,
+				VolumeCapability: TestVolumeCapability(sc),
+			},
+		)
+		if err != nil {
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Or(Equal(codes.InvalidArgument), Equal(codes.OutOfRange)), "unexpected error: %s", serverError.Message())
+			return
+		}
+		Expect(rsp).NotTo(BeNil())
+		Expect(rsp.GetCapacityBytes()).To(BeNumerically(">=", size), "volume was shrunk")
+	})
 })
 
+// findVolumeEntry lists all volumes, following the pagination, and
+// returns the entry for the volume, nil if it is not listed.
+func findVolumeEntry(r *Resources, volumeID string) *csi.ListVolumesResponse_Entry {
+	token := ""
+	for {
+		vols, err := r.ListVolumes(
+			context.Background(),
+			&csi.ListVolumesRequest{
+				StartingToken: token,
+			})
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		for _, entry := range vols.GetEntries() {
+			if entry.GetVolume().GetVolumeId() == volumeID {
+				return entry
+			}
+		}
+		token = vols.GetNextToken()
+		if token == "" {
+			return nil
+		}
+	}
+}
+
 func MakeCreateVolumeReq(sc *TestContext, name string) *csi.CreateVolumeRequest {
 	size1 := TestVolumeSize(sc)
 
 	req := &csi.CreateVolumeRequest{
-		Name: name,
-		VolumeCapabilities: []*csi.VolumeCapability{
-			TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
-		},
+		Name:               name,
+		VolumeCapabilities: testVolumeCapabilities(sc),
 		CapacityRange: &csi.CapacityRange{
 			RequiredBytes: size1,
 			LimitBytes:    size1,
@@ -1656,7 +2293,7 @@ func MakeControllerPublishVolumeReq(sc *TestContext, volID, nodeID string) *csi.
 	return &csi.ControllerPublishVolumeRequest{
 		VolumeId:         volID,
 		NodeId:           nodeID,
-		VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+		VolumeCapability: TestVolumeCapability(sc),
 		Readonly:         false,
 		Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
 	}
@@ -1692,7 +2329,7 @@ func VolumeLifecycle(r *Resources, sc *TestContext, count int) {
 	}
 
 	// Create Volume First
-	By("creating a single node writer volume")
+	By("creating a volume")
 	name := UniqueString("sanity-controller-publish")
 
 	vol := r.MustCreateVolume(
@@ -1700,7 +2337,7 @@ func VolumeLifecycle(r *Resources, sc *TestContext, count int) {
 		&csi.CreateVolumeRequest{
 			Name: name,
 			VolumeCapabilities: []*csi.VolumeCapability{
-				TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+				TestVolumeCapability(sc),
 			},
 			Secrets:                   sc.Secrets.CreateVolumeSecret,
 			Parameters:                sc.Config.TestVolumeParameters,
@@ -1716,7 +2353,7 @@ func VolumeLifecycle(r *Resources, sc *TestContext, count int) {
 			&csi.ControllerPublishVolumeRequest{
 				VolumeId:         vol.GetVolume().GetVolumeId(),
 				NodeId:           ni.GetNodeId(),
-				VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+				VolumeCapability: TestVolumeCapability(sc),
 				Readonly:         false,
 				Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
 			},
@@ -0,0 +1,249 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// CoverageStatus describes how much of a part of the CSI spec was
+// validated.
+type CoverageStatus string
+
+const (
+	// CoverageExercised means that the suite called the RPC or
+	// that the driver reported the capability and the suite ran
+	// tests for it.
+	CoverageExercised CoverageStatus = "exercised"
+	// CoverageSkipped means that the driver supports it, but the
+	// run did not test it, for example because of
+	// TestConfig.FocusTests.
+	CoverageSkipped CoverageStatus = "skipped"
+	// CoverageNotApplicable means that the driver does not
+	// report the capability which the RPC depends on.
+	CoverageNotApplicable CoverageStatus = "not-applicable"
+)
+
+// RPCCoverage is the coverage of one CSI RPC.
+type RPCCoverage struct {
+	// Method is the full gRPC method name.
+	Method string
+	Status CoverageStatus
+	Calls  int
+	Errors int
+	// Capability is the capability that the RPC depends on, in
+	// the format of TestConfig.RequiredCapabilities, empty for
+	// RPCs that all drivers must implement.
+	Capability string
+}
+
+// CapabilityCoverage is the coverage of one CSI capability.
+type CapabilityCoverage struct {
+	// Name is in the format of TestConfig.RequiredCapabilities.
+	Name     string
+	Reported bool
+	Status   CoverageStatus
+	// SkippedSpecs is the number of specs which were skipped
+	// because the driver lacks the capability.
+	SkippedSpecs int
+}
+
+// Coverage lists every RPC and capability of the CSI spec that the
+// suite was built with and whether the run validated it.
+type Coverage struct {
+	RPCs         []RPCCoverage
+	Capabilities []CapabilityCoverage
+}
+
+// rpcCapabilities maps RPCs to the capability which they depend on.
+// The controller service as a whole depends on
+// Plugin.CONTROLLER_SERVICE.
+var rpcCapabilities = map[string]string{
+	"CreateVolume":              ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME),
+	"DeleteVolume":              ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME),
+	"ControllerPublishVolume":   ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME),
+	"ControllerUnpublishVolume": ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME),
+	"ListVolumes":               ControllerCapability(csi.ControllerServiceCapability_RPC_LIST_VOLUMES),
+	"GetCapacity":               ControllerCapability(csi.ControllerServiceCapability_RPC_GET_CAPACITY),
+	"CreateSnapshot":            ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT),
+	"DeleteSnapshot":            ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT),
+	"ListSnapshots":             ControllerCapability(csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS),
+	"ControllerExpandVolume":    ControllerCapability(csi.ControllerServiceCapability_RPC_EXPAND_VOLUME),
+	"ControllerGetVolume":       ControllerCapability(csi.ControllerServiceCapability_RPC_GET_VOLUME),
+	"NodeStageVolume":           NodeCapability(csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME),
+	"NodeUnstageVolume":         NodeCapability(csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME),
+	"NodeGetVolumeStats":        NodeCapability(csi.NodeServiceCapability_RPC_GET_VOLUME_STATS),
+	"NodeExpandVolume":          NodeCapability(csi.NodeServiceCapability_RPC_EXPAND_VOLUME),
+}
+
+// Coverage computes the coverage matrix of the run.
+func (r *Results) Coverage() *Coverage {
+	reported := map[string]bool{}
+	for _, name := range r.Capabilities.Plugin {
+		reported["Plugin."+name] = true
+	}
+	for _, name := range r.Capabilities.Controller {
+		reported["Controller."+name] = true
+	}
+	for _, name := range r.Capabilities.Node {
+		reported["Node."+name] = true
+	}
+	skipped := map[string]int{}
+	ran := false
+	for _, spec := range r.Specs {
+		if spec.MissingCapability != "" {
+			skipped[spec.MissingCapability]++
+		}
+		if spec.State == SpecPassed || spec.State == SpecFailed {
+			ran = true
+		}
+	}
+
+	coverage := &Coverage{}
+	for _, service := range []string{"csi.v1.Identity", "csi.v1.Controller", "csi.v1.Node"} {
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+		if err != nil {
+			continue
+		}
+		methods := desc.(protoreflect.ServiceDescriptor).Methods()
+		for i := 0; i < methods.Len(); i++ {
+			name := string(methods.Get(i).Name())
+			rpc := RPCCoverage{
+				Method:     fmt.Sprintf("/%s/%s", service, name),
+				Capability: rpcCapabilities[name],
+			}
+			if rpc.Capability == "" && service == "csi.v1.Controller" {
+				rpc.Capability = PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE)
+			}
+			stats := r.RPCs[rpc.Method]
+			rpc.Calls = stats.Calls
+			for _, count := range stats.Errors {
+				rpc.Errors += count
+			}
+			switch {
+			case rpc.Calls > 0:
+				rpc.Status = CoverageExercised
+			case rpc.Capability != "" && !reported[rpc.Capability]:
+				rpc.Status = CoverageNotApplicable
+			default:
+				rpc.Status = CoverageSkipped
+			}
+			coverage.RPCs = append(coverage.RPCs, rpc)
+		}
+	}
+
+	var names []string
+	for value, name := range csi.PluginCapability_Service_Type_name {
+		if value != int32(csi.PluginCapability_Service_UNKNOWN) {
+			names = append(names, "Plugin."+name)
+		}
+	}
+	for value, name := range csi.PluginCapability_VolumeExpansion_Type_name {
+		if value != int32(csi.PluginCapability_VolumeExpansion_UNKNOWN) {
+			names = append(names, "Plugin.VOLUME_EXPANSION_"+name)
+		}
+	}
+	for value, name := range csi.ControllerServiceCapability_RPC_Type_name {
+		if value != int32(csi.ControllerServiceCapability_RPC_UNKNOWN) {
+			names = append(names, "Controller."+name)
+		}
+	}
+	for value, name := range csi.NodeServiceCapability_RPC_Type_name {
+		if value != int32(csi.NodeServiceCapability_RPC_UNKNOWN) {
+			names = append(names, "Node."+name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		capability := CapabilityCoverage{
+			Name:         name,
+			Reported:     reported[name],
+			SkippedSpecs: skipped[name],
+		}
+		switch {
+		case !capability.Reported:
+			capability.Status = CoverageNotApplicable
+		case coverage.capabilityExercised(name, ran):
+			capability.Status = CoverageExercised
+		default:
+			capability.Status = CoverageSkipped
+		}
+		coverage.Capabilities = append(coverage.Capabilities, capability)
+	}
+	return coverage
+}
+
+// capabilityExercised checks whether an RPC which depends on the
+// reported capability was called. Capabilities which only modify
+// the behavior of other RPCs count as exercised if any spec ran.
+func (c *Coverage) capabilityExercised(name string, ran bool) bool {
+	gated := false
+	for _, rpc := range c.RPCs {
+		if rpc.Capability != name {
+			continue
+		}
+		gated = true
+		if rpc.Status == CoverageExercised {
+			return true
+		}
+	}
+	return !gated && ran
+}
+
+// Count returns the number of RPCs and capabilities with the status.
+func (c *Coverage) Count(status CoverageStatus) (rpcs, capabilities int) {
+	for _, rpc := range c.RPCs {
+		if rpc.Status == status {
+			rpcs++
+		}
+	}
+	for _, capability := range c.Capabilities {
+		if capability.Status == status {
+			capabilities++
+		}
+	}
+	return
+}
+
+// String returns the coverage as plain-text tables.
+func (c *Coverage) String() string {
+	var b strings.Builder
+	b.WriteString("CSI Spec Coverage\n\n")
+	for _, status := range []CoverageStatus{CoverageExercised, CoverageSkipped, CoverageNotApplicable} {
+		rpcs, capabilities := c.Count(status)
+		fmt.Fprintf(&b, "%-15s %3d RPCs, %3d capabilities\n", status+":", rpcs, capabilities)
+	}
+	b.WriteString("\nRPCs:\n")
+	for _, rpc := range c.RPCs {
+		fmt.Fprintf(&b, "  %-15s %-50s %4d calls, %4d errors\n", rpc.Status, rpc.Method, rpc.Calls, rpc.Errors)
+	}
+	b.WriteString("\nCapabilities:\n")
+	for _, capability := range c.Capabilities {
+		if capability.SkippedSpecs > 0 {
+			fmt.Fprintf(&b, "  %-15s %-50s %d specs skipped\n", capability.Status, capability.Name, capability.SkippedSpecs)
+		} else {
+			fmt.Fprintf(&b, "  %-15s %s\n", capability.Status, capability.Name)
+		}
+	}
+	return b.String()
+}
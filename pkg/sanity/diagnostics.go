@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"encoding/json"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// maxConnectionDiagnostics limits how many snapshots are taken
+// during one run. A driver which went away typically causes all
+// remaining specs to fail the same way.
+const maxConnectionDiagnostics = 10
+
+// ConnectionDiagnostic is a snapshot of the connection state taken
+// with TestConfig.ConnectionDiagnostics when a call failed with a
+// connection-level error. With channelz.Dump, the socket statistics
+// show whether the connection to the driver was broken or whether
+// calls were still pending because the driver hangs.
+type ConnectionDiagnostic struct {
+	// Spec is the name of the spec which was running.
+	Spec string
+	// Method is the full gRPC method name of the failed call.
+	Method string
+	// Code is the gRPC status code of the failed call.
+	Code string
+	Time time.Time
+	// Channelz contains the JSON returned by
+	// TestConfig.ConnectionDiagnostics, or the error which
+	// prevented taking the snapshot.
+	Channelz json.RawMessage
+}
+
+// isConnectionError returns true for errors which may be caused by
+// the connection instead of by the driver.
+func isConnectionError(code codes.Code) bool {
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// dryRunMethods are the RPCs which only read state and therefore
+// still get called in dry-run mode.
+var dryRunMethods = map[string]bool{
+	"GetPluginInfo":              true,
+	"GetPluginCapabilities":      true,
+	"Probe":                      true,
+	"ControllerGetCapabilities":  true,
+	"GetCapacity":                true,
+	"ListVolumes":                true,
+	"ListSnapshots":              true,
+	"ControllerGetVolume":        true,
+	"ValidateVolumeCapabilities": true,
+	"NodeGetCapabilities":        true,
+	"NodeGetInfo":                true,
+	"NodeGetVolumeStats":         true,
+}
+
+// dryRunSkipPrefix starts the skip message of specs which were
+// stopped before their first mutating call.
+const dryRunSkipPrefix = "dry run: would call "
+
+// dryRunInterceptor skips the running spec instead of making a call
+// which could modify the driver state when TestConfig.DryRun is set.
+// Like unimplementedInterceptor, it must be invoked on the goroutine
+// of the test.
+func (sc *TestContext) dryRunInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if name := path.Base(method); sc.Config.DryRun && !dryRunMethods[name] {
+		Skip(dryRunSkipPrefix + name)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// DryRunReport lists each spec with the decision made in dry-run
+// mode: "run" for specs which would run, "skip" with the missing
+// capability or the reason, and "fail" for specs which already
+// failed with the read-only calls.
+func (r *Results) DryRunReport() string {
+	var b strings.Builder
+	for _, spec := range r.Specs {
+		name := spec.Name
+		if spec.ID != "" {
+			name = fmt.Sprintf("%s [%s]", name, spec.ID)
+		}
+		switch {
+		case spec.State == SpecPassed:
+			fmt.Fprintf(&b, "run   %s\n", name)
+		case spec.State == SpecSkipped && strings.HasPrefix(spec.Failure, dryRunSkipPrefix):
+			fmt.Fprintf(&b, "run   %s\n", name)
+		case spec.State == SpecSkipped && spec.MissingCapability != "":
+			fmt.Fprintf(&b, "skip  %s (missing %s)\n", name, spec.MissingCapability)
+		case spec.State == SpecSkipped:
+			fmt.Fprintf(&b, "skip  %s (%s)\n", name, spec.Failure)
+		case spec.State == SpecFailed:
+			fmt.Fprintf(&b, "fail  %s (%s)\n", name, firstLineOf(spec.Failure))
+		}
+	}
+	return b.String()
+}
+
+func firstLineOf(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/prototext"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// dumpInterceptor writes each request and response in protobuf text
+// format to the GinkgoWriter when TestConfig.DumpMessages is set, so
+// that they appear between the steps of the spec in its output.
+func (sc *TestContext) dumpInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !sc.Config.DumpMessages {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	fmt.Fprintf(GinkgoWriter, "%s request:\n%s", method, formatMessage(req))
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		fmt.Fprintf(GinkgoWriter, "%s failed: %s: %s\n", method, status.Code(err), status.Convert(err).Message())
+	} else {
+		fmt.Fprintf(GinkgoWriter, "%s response:\n%s", method, formatMessage(reply))
+	}
+	return err
+}
+
+// formatMessage returns the redacted message in protobuf text format,
+// indented.
+func formatMessage(m interface{}) string {
+	msg := redacted(m)
+	if msg == nil {
+		return fmt.Sprintf("    %v\n", m)
+	}
+	text := strings.TrimSpace(prototext.MarshalOptions{Multiline: true}.Format(msg))
+	if text == "" {
+		return "    <empty>\n"
+	}
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ") + "\n"
+}
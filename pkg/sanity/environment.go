@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// suiteModule is the Go module which provides the sanity package.
+const suiteModule = "github.com/kubernetes-csi/csi-test/v4"
+
+// Environment describes the host on which the suite ran. Node tests
+// often depend on it, so it is included in the results. Fields which
+// could not be determined are empty.
+type Environment struct {
+	// OS and Arch are the values of GOOS and GOARCH.
+	OS   string
+	Arch string
+	// Distribution is PRETTY_NAME from /etc/os-release.
+	Distribution string
+	// Kernel is the kernel release, for example 5.15.0-46-generic.
+	Kernel string
+	// Cgroup is "v1" or "v2" on Linux.
+	Cgroup string
+	// MountVersion is the first line of "mount --version".
+	MountVersion string
+	// GoVersion is the Go version that the suite was built with.
+	GoVersion string
+	// SuiteVersion is TestConfig.SuiteVersion or, if that is not
+	// set, the version of the csi-test module.
+	SuiteVersion string
+}
+
+// collectEnvironment gathers information about the local host.
+func collectEnvironment(suiteVersion string) Environment {
+	env := Environment{
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Distribution: osRelease("/etc/os-release", "PRETTY_NAME"),
+		GoVersion:    runtime.Version(),
+		SuiteVersion: suiteVersion,
+	}
+	if env.SuiteVersion == "" {
+		env.SuiteVersion = moduleVersion(suiteModule)
+	}
+	if data, err := ioutil.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		env.Kernel = strings.TrimSpace(string(data))
+	} else {
+		env.Kernel = firstLine("uname", "-r")
+	}
+	if runtime.GOOS == "linux" {
+		env.Cgroup = "v1"
+		if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+			env.Cgroup = "v2"
+		}
+	}
+	env.MountVersion = firstLine("mount", "--version")
+	return env
+}
+
+// osRelease returns the unquoted value of the key in an os-release
+// file.
+func osRelease(path, key string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), key+"="); value != scanner.Text() {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// firstLine runs the command and returns the first line of its
+// output, an empty string if it fails.
+func firstLine(name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+}
+
+// moduleVersion returns the version of a module that the binary was
+// built with, "unknown" if that information is not available.
+func moduleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == path {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == path {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
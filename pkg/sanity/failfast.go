@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// checkFundamentals verifies once per TestContext that the driver
+// responds to the identity calls and reports its capabilities. The
+// outcome is cached, so a driver which cannot even do that is only
+// contacted once before all other tests get skipped.
+func (sc *TestContext) checkFundamentals() error {
+	if sc.fundamentalsChecked {
+		return sc.fundamentalsErr
+	}
+	sc.fundamentalsChecked = true
+	sc.fundamentalsErr = sc.callFundamentals()
+	return sc.fundamentalsErr
+}
+
+func (sc *TestContext) callFundamentals() error {
+	ctx := context.Background()
+	identity := csi.NewIdentityClient(sc.Conn)
+
+	if _, err := identity.Probe(ctx, &csi.ProbeRequest{}); err != nil && status.Code(err) != codes.FailedPrecondition {
+		return fmt.Errorf("Probe failed: %v", err)
+	}
+	info, err := identity.GetPluginInfo(ctx, &csi.GetPluginInfoRequest{})
+	if err != nil {
+		return fmt.Errorf("GetPluginInfo failed: %v", err)
+	}
+	if info.GetName() == "" {
+		return errors.New("GetPluginInfo returned no name")
+	}
+	caps, err := identity.GetPluginCapabilities(ctx, &csi.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		return fmt.Errorf("GetPluginCapabilities failed: %v", err)
+	}
+
+	for _, cap := range caps.GetCapabilities() {
+		if cap.GetService().GetType() == csi.PluginCapability_Service_CONTROLLER_SERVICE {
+			if _, err := csi.NewControllerClient(sc.ControllerConn).ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{}); err != nil {
+				return fmt.Errorf("ControllerGetCapabilities failed: %v", err)
+			}
+		}
+	}
+	if _, err := csi.NewNodeClient(sc.Conn).NodeGetCapabilities(ctx, &csi.NodeGetCapabilitiesRequest{}); err != nil {
+		return fmt.Errorf("NodeGetCapabilities failed: %v", err)
+	}
+	return nil
+}
+
+// skipIfFundamentalsFailed skips the current test in FailFast mode
+// if the fundamental checks failed.
+func (sc *TestContext) skipIfFundamentalsFailed() {
+	if !sc.Config.FailFast {
+		return
+	}
+	if err := sc.checkFundamentals(); err != nil {
+		Skip(fmt.Sprintf("skipped because the driver failed the fundamental checks (Config.FailFast): %v", err))
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// finishReporter runs the checks which need the whole suite, the
+// fundamental checks and the leak check, at the end of the suite.
+// It must come before the reporters which write report files, so
+// that those include the outcome of the checks.
+type finishReporter struct {
+	sc         *TestContext
+	t          GinkgoTestingT
+	interrupts *interruptReporter
+	// before are the resources which existed before the suite,
+	// nil without a leak check.
+	before *resourceList
+}
+
+func (fr *finishReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (fr *finishReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (fr *finishReporter) SpecWillRun(specSummary *types.SpecSummary) {}
+
+func (fr *finishReporter) SpecDidComplete(specSummary *types.SpecSummary) {}
+
+func (fr *finishReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (fr *finishReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	sc := fr.sc
+	sc.Finalize()
+	if sc.fundamentalsErr != nil {
+		sc.Config.logger().Error(sc.fundamentalsErr, "driver failed the fundamental checks, remaining tests were skipped")
+		fr.t.Fail()
+		sc.suiteChecksFailed = true
+	}
+	// The process exits right after an interrupted suite, which
+	// is no time to contact the driver again.
+	if fr.before != nil && !fr.interrupts.wasInterrupted() {
+		sc.checkLeaks(fr.t, fr.before)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+type fakeTestingT struct {
+	failed bool
+}
+
+func (t *fakeTestingT) Fail() {
+	t.failed = true
+}
+
+func TestFinishReporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	sc := NewTestContext(&TestConfig{
+		Logger: NewWriterLogger(ioutil.Discard, 0),
+	})
+	sc.fundamentalsErr = errors.New("GetPluginInfo failed")
+	ft := &fakeTestingT{}
+	interrupts := newInterruptReporter(sc)
+	defer interrupts.stop()
+
+	// The checks must be done before the report gets written.
+	(&finishReporter{sc: sc, t: ft, interrupts: interrupts}).SpecSuiteDidEnd(nil)
+	(&jsonReporter{sc: sc, path: path}).SpecSuiteDidEnd(nil)
+
+	if !ft.failed {
+		t.Error("failed fundamental checks must fail the test")
+	}
+	if sc.Results().Succeeded {
+		t.Error("failed fundamental checks must fail the results")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report JSONReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Succeeded {
+		t.Error("failed fundamental checks must fail the report")
+	}
+}
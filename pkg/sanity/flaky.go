@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	ginkgoconfig "github.com/onsi/ginkgo/config"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// Ginkgo only supports a global number of attempts per spec
+// (-ginkgo.flakeAttempts). For TestConfig.SpecRetries, Test raises
+// that number to the highest one needed and limitSpecRetries fails
+// attempts of other specs right away, with the failure of the
+// previous attempt. Such attempts do not set up or tear down anything
+// and are not counted in the results.
+
+// maxSpecAttempts returns the number of attempts needed for the spec
+// with the most retries, at least the current Ginkgo setting.
+func maxSpecAttempts(config *TestConfig, flakeAttempts int) int {
+	attempts := flakeAttempts
+	for _, retries := range config.SpecRetries {
+		if retries+1 > attempts {
+			attempts = retries + 1
+		}
+	}
+	return attempts
+}
+
+// allowedRetries returns how often the spec may be repeated after a
+// failure. Retries for an identifier without parameters apply to
+// the runs with all parameter sets.
+func (sc *TestContext) allowedRetries(id string) int {
+	if retries, ok := sc.Config.SpecRetries[id]; ok {
+		return retries
+	}
+	if retries, ok := sc.Config.SpecRetries[baseSpecID(id)]; ok {
+		return retries
+	}
+	flakeAttempts := sc.flakeAttempts
+	if flakeAttempts == 0 {
+		flakeAttempts = ginkgoconfig.GinkgoConfig.FlakeAttempts
+	}
+	if flakeAttempts <= 1 {
+		return 0
+	}
+	return flakeAttempts - 1
+}
+
+// limitSpecRetries must be called at the start of each attempt of a
+// spec. It fails attempts which exceed the retries allowed for the
+// spec and sets notRetried for them.
+func (sc *TestContext) limitSpecRetries() {
+	sc.notRetried = false
+	if len(sc.Config.SpecRetries) == 0 {
+		return
+	}
+	text := CurrentGinkgoTestDescription().FullTestText
+	if sc.specAttempts == nil {
+		sc.specAttempts = map[string]int{}
+	}
+	sc.specAttempts[text]++
+	retry := sc.specAttempts[text] - 1
+	if retry == 0 {
+		return
+	}
+
+	id, _ := parseSpecID(text)
+	if allowed := sc.allowedRetries(id); retry > allowed {
+		// Ginkgo only continues with the next spec after a
+		// failed attempt, so fail again with the original
+		// failure, which stays in the results.
+		sc.Config.logger().Info(2, "not retrying spec", "spec", text, "retries", allowed)
+		sc.notRetried = true
+		sc.results.ignoreAttempt()
+		Fail(sc.results.previousFailure(text))
+	}
+}
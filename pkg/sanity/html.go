@@ -0,0 +1,191 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"latency": func(d time.Duration) string {
+		return d.Round(time.Microsecond).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>CSI Sanity Report: {{.Results.DriverName}} {{.Results.DriverVersion}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.passed { color: #1a7f37; }
+.failed { color: #cf222e; font-weight: bold; }
+.skipped, .pending { color: #6e7781; }
+pre { white-space: pre-wrap; margin: 0; }
+</style>
+</head>
+<body>
+<h1>CSI Sanity Conformance: <span class="{{if .Results.Succeeded}}passed">PASSED{{else}}failed">FAILED{{end}}</span></h1>
+<table>
+<tr><th>Driver</th><td>{{.Results.DriverName}}</td></tr>
+<tr><th>Driver version</th><td>{{.Results.DriverVersion}}</td></tr>
+<tr><th>CSI spec</th><td>{{.Results.SpecVersion}}</td></tr>
+<tr><th>Suite version</th><td>{{.Results.Environment.SuiteVersion}}</td></tr>
+<tr><th>Date</th><td>{{.Results.StartTime.UTC.Format "2006-01-02 15:04:05 UTC"}}</td></tr>
+<tr><th>Duration</th><td>{{duration .Results.Duration}}</td></tr>
+<tr><th>Host</th><td>{{with .Results.Environment}}{{.OS}}/{{.Arch}} {{.Distribution}} {{.Kernel}}{{end}}</td></tr>
+<tr><th>Specs</th><td>{{.Passed}} passed, {{.Failed}} failed, {{.Skipped}} skipped</td></tr>
+</table>
+
+<h2>Areas</h2>
+<table>
+<tr><th>Area</th><th>State</th><th>Passed</th><th>Failed</th><th>Skipped</th><th>Duration</th></tr>
+{{range .Areas}}<tr><td><a href="#{{.Anchor}}">{{.Name}}</a></td><td class="{{.State}}">{{.State}}</td><td>{{.Passed}}</td><td>{{.Failed}}</td><td>{{.Skipped}}</td><td>{{duration .Duration}}</td></tr>
+{{end}}</table>
+
+{{range .Areas}}<h2 id="{{.Anchor}}">{{.Name}}</h2>
+<table>
+<tr><th>Spec</th><th>State</th><th>Duration</th><th>Details</th></tr>
+{{range .Specs}}<tr><td>{{.Name}}{{if .ID}}<br><small>{{.ID}}</small>{{end}}</td><td class="{{.State}}">{{.State}}</td><td>{{duration .Duration}}</td><td>{{if .Failure}}<details{{if eq .State "failed"}} open{{end}}><summary>{{if .MissingCapability}}missing {{.MissingCapability}}{{else if .LastErrorCode}}{{.LastErrorMethod}}: {{.LastErrorCode}}{{else}}message{{end}}</summary><pre>{{.Failure}}</pre></details>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Results.RPCs}}<h2>Latency per gRPC method</h2>
+<table>
+<tr><th>Method</th><th>Calls</th><th>Errors</th><th>p50</th><th>p95</th><th>max</th></tr>
+{{range .Methods}}<tr><td>{{.Method}}</td><td>{{.Stats.Calls}}</td><td>{{.Errors}}</td><td>{{latency .Stats.P50}}</td><td>{{latency .Stats.P95}}</td><td>{{latency .Stats.Max}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// htmlArea is one capability area in the HTML report.
+type htmlArea struct {
+	Name                    string
+	Anchor                  string
+	State                   SpecState
+	Passed, Failed, Skipped int
+	Duration                time.Duration
+	Specs                   []SpecResult
+}
+
+// htmlMethod is one row of the latency table in the HTML report.
+type htmlMethod struct {
+	Method string
+	Stats  RPCStats
+	Errors int
+}
+
+// HTMLReport returns a self-contained HTML page with the outcome per
+// area, the details of each spec and the latency of the gRPC methods.
+func (r *Results) HTMLReport() ([]byte, error) {
+	states := r.areaStates()
+	areas := map[string]*htmlArea{}
+	var names []string
+	for _, spec := range r.Specs {
+		area := areas[spec.Area]
+		if area == nil {
+			area = &htmlArea{Name: spec.Area, State: states[spec.Area]}
+			if area.Name == "" {
+				area.Name = "Other"
+			}
+			areas[spec.Area] = area
+			names = append(names, spec.Area)
+		}
+		switch spec.State {
+		case SpecPassed:
+			area.Passed++
+		case SpecFailed:
+			area.Failed++
+		default:
+			area.Skipped++
+		}
+		area.Duration += spec.Duration
+		area.Specs = append(area.Specs, spec)
+	}
+	sort.Strings(names)
+
+	data := struct {
+		Results                 *Results
+		Passed, Failed, Skipped int
+		Areas                   []*htmlArea
+		Methods                 []htmlMethod
+	}{
+		Results: r,
+		Passed:  r.Count(SpecPassed),
+		Failed:  r.Count(SpecFailed),
+		Skipped: r.Count(SpecSkipped),
+	}
+	for i, name := range names {
+		area := areas[name]
+		area.Anchor = fmt.Sprintf("area-%d", i+1)
+		data.Areas = append(data.Areas, area)
+	}
+	for method, stats := range r.RPCs {
+		row := htmlMethod{Method: method, Stats: stats}
+		for _, count := range stats.Errors {
+			row.Errors += count
+		}
+		data.Methods = append(data.Methods, row)
+	}
+	sort.Slice(data.Methods, func(i, j int) bool { return data.Methods[i].Method < data.Methods[j].Method })
+
+	var b bytes.Buffer
+	if err := htmlReportTemplate.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// htmlReporter writes TestConfig.HTMLReportFile at the end of the
+// suite.
+type htmlReporter struct {
+	sc   *TestContext
+	path string
+}
+
+func (hr *htmlReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (hr *htmlReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (hr *htmlReporter) SpecWillRun(specSummary *types.SpecSummary) {}
+
+func (hr *htmlReporter) SpecDidComplete(specSummary *types.SpecSummary) {}
+
+func (hr *htmlReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (hr *htmlReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	data, err := hr.sc.Results().HTMLReport()
+	if err == nil {
+		err = ioutil.WriteFile(hr.path, data, 0644)
+	}
+	if err != nil {
+		hr.sc.Config.logger().Error(err, "writing HTML report failed", "path", hr.path)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -30,7 +31,7 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-var _ = DescribeSanity("Identity Service", func(sc *TestContext) {
+var _ = describeFundamental("Identity Service", func(sc *TestContext) {
 	var (
 		c csi.IdentityClient
 	)
@@ -40,7 +41,7 @@ var _ = DescribeSanity("Identity Service", func(sc *TestContext) {
 	})
 
 	Describe("GetPluginCapabilities", func() {
-		It("should return appropriate capabilities", func() {
+		It(SpecID("getplugincapabilities/capabilities", "should return appropriate capabilities"), func() {
 			req := &csi.GetPluginCapabilitiesRequest{}
 			res, err := c.GetPluginCapabilities(context.Background(), req)
 			Expect(err).NotTo(HaveOccurred())
@@ -73,27 +74,47 @@ var _ = DescribeSanity("Identity Service", func(sc *TestContext) {
 	})
 
 	Describe("Probe", func() {
-		It("should return appropriate information", func() {
-			req := &csi.ProbeRequest{}
-			res, err := c.Probe(context.Background(), req)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(res).NotTo(BeNil())
-
-			By("verifying return status")
-			serverError, ok := status.FromError(err)
-			Expect(ok).To(BeTrue())
-			Expect(serverError.Code() == codes.FailedPrecondition ||
-				serverError.Code() == codes.OK).To(BeTrue(), "unexpected error: %s", serverError.Message())
+		It(SpecID("probe/information", "should return appropriate information"), func() {
+			// A driver which is still starting up may report that it
+			// is not ready yet, so try again with increasing delays
+			// until it is ready or the deadline is reached.
+			deadline := time.Now().Add(sc.Config.ProbeTimeout)
+			delay := 100 * time.Millisecond
+			for {
+				req := &csi.ProbeRequest{}
+				res, err := c.Probe(context.Background(), req)
+
+				By("verifying return status")
+				serverError, ok := status.FromError(err)
+				Expect(ok).To(BeTrue())
+				Expect(serverError.Code() == codes.FailedPrecondition ||
+					serverError.Code() == codes.OK).To(BeTrue(), "unexpected error: %s", serverError.Message())
+
+				ready := err == nil
+				if ready {
+					Expect(res).NotTo(BeNil())
+					// An unset ready field means that the driver is ready.
+					ready = res.GetReady() == nil || res.GetReady().GetValue()
+				}
+				if ready {
+					return
+				}
+				if time.Now().Add(delay).After(deadline) {
+					Fail(fmt.Sprintf("driver did not become ready within %s", sc.Config.ProbeTimeout))
+				}
 
-			if res.GetReady() != nil {
-				Expect(res.GetReady().GetValue() == true ||
-					res.GetReady().GetValue() == false).To(BeTrue())
+				By(fmt.Sprintf("waiting %s for the driver to become ready", delay))
+				time.Sleep(delay)
+				delay *= 2
+				if delay > 5*time.Second {
+					delay = 5 * time.Second
+				}
 			}
 		})
 	})
 
 	Describe("GetPluginInfo", func() {
-		It("should return appropriate information", func() {
+		It(SpecID("getplugininfo/information", "should return appropriate information"), func() {
 			req := &csi.GetPluginInfoRequest{}
 			res, err := c.GetPluginInfo(context.Background(), req)
 			Expect(err).NotTo(HaveOccurred())
@@ -106,5 +127,77 @@ var _ = DescribeSanity("Identity Service", func(sc *TestContext) {
 				MustCompile("^[a-zA-Z][A-Za-z0-9-\\.\\_]{0,61}[a-zA-Z]$").
 				MatchString(res.GetName())).To(BeTrue())
 		})
+
+		It(SpecID("getplugininfo/vendor-version", "should return the expected vendor version"), func() {
+			if sc.Config.VendorVersionPattern == "" {
+				Skip("Config.VendorVersionPattern not set")
+			}
+			pattern, err := regexp.Compile(sc.Config.VendorVersionPattern)
+			Expect(err).NotTo(HaveOccurred(), "invalid Config.VendorVersionPattern")
+
+			req := &csi.GetPluginInfoRequest{}
+			res, err := c.GetPluginInfo(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).NotTo(BeNil())
+
+			By("verifying vendor version")
+			Expect(res.GetVendorVersion()).To(MatchRegexp(pattern.String()), "vendor version does not match %s", pattern)
+		})
+
+		It(SpecID("getplugininfo/manifest-keys", "should return the expected manifest keys"), func() {
+			if len(sc.Config.ManifestKeys) == 0 {
+				Skip("Config.ManifestKeys not set")
+			}
+
+			req := &csi.GetPluginInfoRequest{}
+			res, err := c.GetPluginInfo(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).NotTo(BeNil())
+
+			By("verifying manifest")
+			for _, key := range sc.Config.ManifestKeys {
+				Expect(res.GetManifest()).To(HaveKey(key), "manifest key %q is missing", key)
+			}
+		})
+	})
+
+	Describe("Identity Only", func() {
+		BeforeEach(func() {
+			if !sc.Config.IdentityOnly {
+				Skip("Config.IdentityOnly not set")
+			}
+		})
+
+		It(SpecID("identityonly/no-capabilities", "should not report any plugin capabilities"), func() {
+			res, err := c.GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).NotTo(BeNil())
+			Expect(res.GetCapabilities()).To(BeEmpty(), "a driver which only implements the Identity Service must not report capabilities")
+		})
+
+		It(SpecID("identityonly/consistent-info", "should return the same name and vendor version each time"), func() {
+			first, err := c.GetPluginInfo(context.Background(), &csi.GetPluginInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first).NotTo(BeNil())
+			Expect(first.GetVendorVersion()).NotTo(BeEmpty(), "GetPluginInfo returned no vendor version")
+
+			for i := 0; i < sc.Config.IdempotentCount; i++ {
+				res, err := c.GetPluginInfo(context.Background(), &csi.GetPluginInfoRequest{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res.GetName()).To(Equal(first.GetName()), "name changed in call #%d", i+2)
+				Expect(res.GetVendorVersion()).To(Equal(first.GetVendorVersion()), "vendor version changed in call #%d", i+2)
+			}
+		})
+
+		It(SpecID("identityonly/consistent-probe", "should be ready in all Probe calls"), func() {
+			for i := 0; i < sc.Config.IdempotentCount; i++ {
+				res, err := c.Probe(context.Background(), &csi.ProbeRequest{})
+				Expect(err).NotTo(HaveOccurred(), "Probe call #%d failed", i+1)
+				Expect(res).NotTo(BeNil())
+				if res.GetReady() != nil {
+					Expect(res.GetReady().GetValue()).To(BeTrue(), "Probe call #%d reported that the driver is not ready", i+1)
+				}
+			}
+		})
 	})
 })
@@ -0,0 +1,222 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
+	"github.com/onsi/ginkgo/types"
+)
+
+// When Ginkgo receives SIGINT or SIGTERM, it stops scheduling specs,
+// reports the end of the suite and exits the process while the
+// current spec is still running. interruptReporter delays the end of
+// the suite until that spec is done or TestConfig.InterruptTimeout
+// has passed, then cleans up the resources which are still tracked
+// and hands the partial results to TestConfig.OnInterrupt.
+
+// activeResources is the set of Resources which currently track
+// something that needs to be cleaned up.
+type activeResources struct {
+	mutex     sync.Mutex
+	resources map[*Resources]bool
+}
+
+// track adds r to the active resources of the context.
+func (sc *TestContext) track(r *Resources) {
+	if sc == nil {
+		return
+	}
+	sc.active.mutex.Lock()
+	defer sc.active.mutex.Unlock()
+	if sc.active.resources == nil {
+		sc.active.resources = map[*Resources]bool{}
+	}
+	sc.active.resources[r] = true
+}
+
+// untrack removes r from the active resources of the context.
+func (sc *TestContext) untrack(r *Resources) {
+	if sc == nil {
+		return
+	}
+	sc.active.mutex.Lock()
+	defer sc.active.mutex.Unlock()
+	delete(sc.active.resources, r)
+}
+
+// cleanupActiveResources cleans up all resources which were not
+// cleaned up by their test.
+func (sc *TestContext) cleanupActiveResources() {
+	sc.active.mutex.Lock()
+	var resources []*Resources
+	for r := range sc.active.resources {
+		resources = append(resources, r)
+	}
+	sc.active.mutex.Unlock()
+
+	for _, r := range resources {
+		sc.cleanupOutsideSpec(r, "cleaning up after interrupt failed")
+	}
+}
+
+// cleanupOutsideSpec cleans up r and logs the message if that fails.
+func (sc *TestContext) cleanupOutsideSpec(r *Resources, msg string) {
+	// Cleanup reports errors through Gomega, which panics outside
+	// of a spec.
+	defer func() {
+		if err := recover(); err != nil {
+			sc.Config.logger().Error(nil, msg, "err", err)
+		}
+	}()
+	r.Cleanup()
+}
+
+// interruptReporter is a Ginkgo reporter which only acts at the end
+// of a suite that was interrupted by a signal. It must be the first
+// reporter, so that it runs before the others write their reports,
+// and its completion reporter must be the last one.
+type interruptReporter struct {
+	sc      *TestContext
+	signals chan os.Signal
+
+	mutex sync.Mutex
+	// running is closed when the current spec is done.
+	running chan struct{}
+	// stopped is set when the suite ended because of a signal.
+	stopped bool
+}
+
+// newInterruptReporter starts watching for SIGINT and SIGTERM. stop
+// must be called when the suite is done.
+func newInterruptReporter(sc *TestContext) *interruptReporter {
+	ir := &interruptReporter{
+		sc:      sc,
+		signals: make(chan os.Signal, 1),
+	}
+	signal.Notify(ir.signals, os.Interrupt, syscall.SIGTERM)
+	return ir
+}
+
+func (ir *interruptReporter) stop() {
+	signal.Stop(ir.signals)
+}
+
+// interrupted checks whether a signal was received. The signal stays
+// buffered in the channel until then.
+func (ir *interruptReporter) interrupted() bool {
+	select {
+	case <-ir.signals:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ir *interruptReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (ir *interruptReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (ir *interruptReporter) SpecWillRun(specSummary *types.SpecSummary) {
+	ir.mutex.Lock()
+	defer ir.mutex.Unlock()
+	ir.running = make(chan struct{})
+}
+
+func (ir *interruptReporter) SpecDidComplete(specSummary *types.SpecSummary) {}
+
+func (ir *interruptReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (ir *interruptReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	if !ir.interrupted() {
+		return
+	}
+	ir.mutex.Lock()
+	ir.stopped = true
+	ir.mutex.Unlock()
+	ir.waitForSpec()
+	ir.sc.Config.logger().Info(0, "interrupted, cleaning up remaining volumes, snapshots and mounts")
+	ir.sc.cleanupActiveResources()
+	if ir.sc.Config.OnInterrupt != nil {
+		results := ir.sc.Results()
+		results.Succeeded = false
+		ir.sc.Config.OnInterrupt(results)
+	}
+}
+
+// wasInterrupted checks whether the suite ended because of a signal.
+func (ir *interruptReporter) wasInterrupted() bool {
+	ir.mutex.Lock()
+	defer ir.mutex.Unlock()
+	return ir.stopped
+}
+
+// waitForSpec waits until the current spec, including its cleanup,
+// is done or TestConfig.InterruptTimeout has passed.
+func (ir *interruptReporter) waitForSpec() {
+	ir.mutex.Lock()
+	running := ir.running
+	ir.mutex.Unlock()
+	if running == nil || ir.sc.Config.InterruptTimeout <= 0 {
+		return
+	}
+	ir.sc.Config.logger().Info(0, "interrupted, waiting for the current test to finish", "timeout", ir.sc.Config.InterruptTimeout)
+	timer := time.NewTimer(ir.sc.Config.InterruptTimeout)
+	defer timer.Stop()
+	select {
+	case <-running:
+	case <-timer.C:
+		ir.sc.Config.logger().Warning("the current test did not finish in time, cleaning up while it is still running")
+	}
+}
+
+// completion returns the reporter which marks the end of a spec after
+// all other reporters have seen it.
+func (ir *interruptReporter) completion() reporters.Reporter {
+	return specCompletion{ir}
+}
+
+type specCompletion struct {
+	ir *interruptReporter
+}
+
+func (sc specCompletion) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (sc specCompletion) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (sc specCompletion) SpecWillRun(specSummary *types.SpecSummary) {}
+
+func (sc specCompletion) SpecDidComplete(specSummary *types.SpecSummary) {
+	sc.ir.mutex.Lock()
+	defer sc.ir.mutex.Unlock()
+	if sc.ir.running != nil {
+		close(sc.ir.running)
+		sc.ir.running = nil
+	}
+}
+
+func (sc specCompletion) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (sc specCompletion) SpecSuiteDidEnd(summary *types.SuiteSummary) {}
@@ -0,0 +1,106 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestInterruptReporter sends SIGINT to the test process, which
+// is not possible on Windows.
+func TestInterruptReporter(t *testing.T) {
+	for name, tc := range map[string]struct {
+		signal           bool
+		timeout          time.Duration
+		completed        bool
+		completeAt       time.Duration
+		interrupted      bool
+		minWait, maxWait time.Duration
+	}{
+		"no signal":         {maxWait: time.Second},
+		"waits for spec":    {signal: true, timeout: time.Minute, completeAt: 200 * time.Millisecond, interrupted: true, minWait: 200 * time.Millisecond, maxWait: 30 * time.Second},
+		"times out":         {signal: true, timeout: 100 * time.Millisecond, interrupted: true, minWait: 100 * time.Millisecond, maxWait: 30 * time.Second},
+		"does not wait":     {signal: true, interrupted: true, maxWait: time.Second},
+		"spec already done": {signal: true, timeout: time.Minute, completed: true, interrupted: true, maxWait: 30 * time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reported := make(chan *Results, 1)
+			sc := NewTestContext(&TestConfig{
+				Logger:           NewWriterLogger(ioutil.Discard, 0),
+				InterruptTimeout: tc.timeout,
+				OnInterrupt:      func(results *Results) { reported <- results },
+			})
+			ir := newInterruptReporter(sc)
+			defer ir.stop()
+			completion := ir.completion()
+
+			ir.SpecWillRun(nil)
+			if tc.completed {
+				completion.SpecDidComplete(nil)
+			}
+			if tc.signal {
+				if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+					t.Fatalf("sending SIGINT: %v", err)
+				}
+				deadline := time.Now().Add(10 * time.Second)
+				for len(ir.signals) == 0 {
+					if time.Now().After(deadline) {
+						t.Fatal("SIGINT not received")
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			if tc.completeAt > 0 {
+				go func() {
+					time.Sleep(tc.completeAt)
+					completion.SpecDidComplete(nil)
+				}()
+			}
+
+			start := time.Now()
+			ir.SpecSuiteDidEnd(nil)
+			waited := time.Since(start)
+
+			select {
+			case results := <-reported:
+				if !tc.interrupted {
+					t.Fatal("OnInterrupt called without a signal")
+				}
+				if results.Succeeded {
+					t.Error("interrupted results must not succeed")
+				}
+			default:
+				if tc.interrupted {
+					t.Fatal("OnInterrupt not called")
+				}
+			}
+			if ir.wasInterrupted() != tc.interrupted {
+				t.Errorf("expected interrupted %v, got %v", tc.interrupted, ir.wasInterrupted())
+			}
+			if waited < tc.minWait || waited > tc.maxWait {
+				t.Errorf("expected to wait between %s and %s, waited %s", tc.minWait, tc.maxWait, waited)
+			}
+		})
+	}
+}
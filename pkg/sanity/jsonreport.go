@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+// JSONReport is the content of TestConfig.JSONReportFile. It is a
+// stable, flat subset of Results for dashboards and conformance
+// tooling.
+type JSONReport struct {
+	StartTime       time.Time        `json:"startTime"`
+	DurationSeconds float64          `json:"durationSeconds"`
+	Succeeded       bool             `json:"succeeded"`
+	DriverName      string           `json:"driverName"`
+	DriverVersion   string           `json:"driverVersion"`
+	SpecVersion     string           `json:"specVersion"`
+	SuiteVersion    string           `json:"suiteVersion"`
+	Specs           []JSONReportSpec `json:"specs"`
+}
+
+// JSONReportSpec is the outcome of one spec in a JSONReport.
+type JSONReportSpec struct {
+	ID              string    `json:"id,omitempty"`
+	Name            string    `json:"name"`
+	Area            string    `json:"area"`
+	State           SpecState `json:"state"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Failure         string    `json:"failure,omitempty"`
+	// Method and Code are the gRPC method and status code of the
+	// last call which failed during the spec, if any. For failed
+	// specs this usually is the error which the driver returned
+	// instead of the expected result.
+	Method string `json:"method,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+// NewJSONReport converts results into a report.
+func NewJSONReport(results *Results) *JSONReport {
+	report := &JSONReport{
+		StartTime:       results.StartTime,
+		DurationSeconds: results.Duration.Seconds(),
+		Succeeded:       results.Succeeded,
+		DriverName:      results.DriverName,
+		DriverVersion:   results.DriverVersion,
+		SpecVersion:     results.SpecVersion,
+		SuiteVersion:    results.Environment.SuiteVersion,
+		Specs:           []JSONReportSpec{},
+	}
+	for _, spec := range results.Specs {
+		report.Specs = append(report.Specs, JSONReportSpec{
+			ID:              spec.ID,
+			Name:            spec.Name,
+			Area:            spec.Area,
+			State:           spec.State,
+			DurationSeconds: spec.Duration.Seconds(),
+			Failure:         spec.Failure,
+			Method:          spec.LastErrorMethod,
+			Code:            spec.LastErrorCode,
+		})
+	}
+	return report
+}
+
+// jsonReporter writes the JSON report at the end of the suite, also
+// when it got interrupted, like the JUnit reporter does.
+type jsonReporter struct {
+	sc   *TestContext
+	path string
+}
+
+func (jr *jsonReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (jr *jsonReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (jr *jsonReporter) SpecWillRun(specSummary *types.SpecSummary) {}
+
+func (jr *jsonReporter) SpecDidComplete(specSummary *types.SpecSummary) {}
+
+func (jr *jsonReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (jr *jsonReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	data, err := json.MarshalIndent(NewJSONReport(jr.sc.Results()), "", "  ")
+	if err != nil {
+		jr.sc.Config.logger().Error(err, "encoding JSON report failed")
+		return
+	}
+	if err := ioutil.WriteFile(jr.path, data, 0644); err != nil {
+		jr.sc.Config.logger().Error(err, "writing JSON report failed", "path", jr.path)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/onsi/ginkgo/reporters"
+	"github.com/onsi/ginkgo/types"
+)
+
+// junitReporter is the Ginkgo JUnit reporter plus properties which
+// identify the driver and the suite, because the Ginkgo reporter
+// cannot write properties itself.
+type junitReporter struct {
+	*reporters.JUnitReporter
+	sc   *TestContext
+	path string
+}
+
+func newJUnitReporter(sc *TestContext, path string) *junitReporter {
+	return &junitReporter{
+		JUnitReporter: reporters.NewJUnitReporter(path),
+		sc:            sc,
+		path:          path,
+	}
+}
+
+func (jr *junitReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	jr.JUnitReporter.SpecSuiteDidEnd(summary)
+
+	results := jr.sc.Results()
+	properties := [][2]string{
+		{"driver.name", results.DriverName},
+		{"driver.version", results.DriverVersion},
+		{"csi.spec.version", results.SpecVersion},
+		{"sanity.version", results.Environment.SuiteVersion},
+	}
+	if err := addJUnitProperties(jr.path, properties); err != nil {
+		jr.sc.Config.logger().Error(err, "adding properties to JUnit report failed", "path", jr.path)
+	}
+}
+
+// addJUnitProperties inserts a properties element at the start of
+// the testsuite element in the file, indented like the Ginkgo
+// reporter indents the test cases.
+func addJUnitProperties(path string, properties [][2]string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	start := bytes.Index(data, []byte("<testsuite"))
+	if start < 0 {
+		return fmt.Errorf("no testsuite element")
+	}
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return fmt.Errorf("incomplete testsuite element")
+	}
+	end += start + 1
+
+	var b bytes.Buffer
+	b.Write(data[:end])
+	b.WriteString("\n      <properties>\n")
+	for _, property := range properties {
+		b.WriteString(`          <property name="`)
+		xml.EscapeText(&b, []byte(property[0]))
+		b.WriteString(`" value="`)
+		xml.EscapeText(&b, []byte(property[1]))
+		b.WriteString("\"></property>\n")
+	}
+	b.WriteString("      </properties>")
+	b.Write(data[end:])
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// percentile returns the nearest-rank percentile of the sorted
+// durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// setLatencies fills in the percentiles from the individual call
+// durations.
+func (stats *RPCStats) setLatencies(durations []time.Duration) {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	stats.P50 = percentile(sorted, 50)
+	stats.P95 = percentile(sorted, 95)
+	if len(sorted) > 0 {
+		stats.Max = sorted[len(sorted)-1]
+	}
+}
+
+// LatencyReport returns a table with the number of calls and the
+// p50, p95 and maximum latency of each gRPC method, the slowest
+// methods by p95 first.
+func (r *Results) LatencyReport() string {
+	methods := make([]string, 0, len(r.RPCs))
+	for method := range r.RPCs {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		a, b := r.RPCs[methods[i]], r.RPCs[methods[j]]
+		if a.P95 != b.P95 {
+			return a.P95 > b.P95
+		}
+		return methods[i] < methods[j]
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-45s %6s %12s %12s %12s\n", "Method", "Calls", "p50", "p95", "max")
+	for _, method := range methods {
+		stats := r.RPCs[method]
+		fmt.Fprintf(&b, "%-45s %6d %12s %12s %12s\n", strings.TrimPrefix(method, "/csi.v1."), stats.Calls,
+			stats.P50.Round(time.Microsecond), stats.P95.Round(time.Microsecond), stats.Max.Round(time.Microsecond))
+	}
+	return b.String()
+}
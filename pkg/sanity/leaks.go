@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	. "github.com/onsi/ginkgo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/kubernetes-csi/csi-test/v4/utils"
+)
+
+// LeakCheckMode determines what happens when volumes or snapshots
+// created by the test suite still exist after it completed.
+type LeakCheckMode string
+
+const (
+	// LeakCheckDisabled skips the check.
+	LeakCheckDisabled LeakCheckMode = ""
+	// LeakCheckWarn logs leaked resources.
+	LeakCheckWarn LeakCheckMode = "warn"
+	// LeakCheckFail logs leaked resources and fails the test.
+	LeakCheckFail LeakCheckMode = "fail"
+)
+
+// resourceList contains the IDs of volumes and snapshots known to
+// the driver.
+type resourceList struct {
+	volumes   map[string]bool
+	snapshots map[string]bool
+}
+
+// listResources retrieves all volumes and snapshots through a
+// separate connection, so the calls are not recorded in the
+// results. Listing is skipped if the driver does not support it.
+func listResources(config *TestConfig) (*resourceList, error) {
+	address, dialOptions := config.ControllerAddress, config.controllerDialOptions()
+	if address == "" {
+		address, dialOptions = config.Address, config.DialOptions
+	}
+	conn, err := utils.Connect(address, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	secrets, err := config.LoadSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]string{}
+	for key, value := range config.Metadata {
+		entries[key] = value
+	}
+	if config.MetadataFile != "" {
+		data, err := ioutil.ReadFile(config.MetadataFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("error unmarshaling yaml from %q: %v", config.MetadataFile, err)
+		}
+	}
+
+	ctx := withMetadata(context.Background(), entries)
+	client := csi.NewControllerClient(conn)
+	list := &resourceList{
+		volumes:   map[string]bool{},
+		snapshots: map[string]bool{},
+	}
+	caps, err := client.ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		// No controller service, nothing to list.
+		return list, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ControllerGetCapabilities failed: %v", err)
+	}
+
+	for _, cap := range caps.GetCapabilities() {
+		switch cap.GetRpc().GetType() {
+		case csi.ControllerServiceCapability_RPC_LIST_VOLUMES:
+			token := ""
+			for {
+				rsp, err := client.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: token})
+				if err != nil {
+					return nil, fmt.Errorf("ListVolumes failed: %v", err)
+				}
+				for _, entry := range rsp.GetEntries() {
+					list.volumes[entry.GetVolume().GetVolumeId()] = true
+				}
+				token = rsp.GetNextToken()
+				if token == "" {
+					break
+				}
+			}
+		case csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS:
+			token := ""
+			for {
+				rsp, err := client.ListSnapshots(ctx, &csi.ListSnapshotsRequest{StartingToken: token, Secrets: secrets.ListSnapshotsSecret})
+				if err != nil {
+					return nil, fmt.Errorf("ListSnapshots failed: %v", err)
+				}
+				for _, entry := range rsp.GetEntries() {
+					list.snapshots[entry.GetSnapshot().GetSnapshotId()] = true
+				}
+				token = rsp.GetNextToken()
+				if token == "" {
+					break
+				}
+			}
+		}
+	}
+	return list, nil
+}
+
+// leakedIDs returns the sorted IDs which exist after the suite but
+// not before it and which belong to the suite, either because they
+// were returned by a create call or because they contain the unique
+// suffix of the names used by the suite.
+func leakedIDs(before, after, created map[string]bool) []string {
+	var leaked []string
+	for id := range after {
+		if before[id] {
+			continue
+		}
+		if created[id] || strings.Contains(id, uniqueSuffix) {
+			leaked = append(leaked, id)
+		}
+	}
+	sort.Strings(leaked)
+	return leaked
+}
+
+// checkLeaks compares the resources that existed before the suite
+// ran with those that exist now and records leaked resources for
+// Results. In LeakCheckFail mode, leaks cause the test to fail.
+func (sc *TestContext) checkLeaks(t GinkgoTestingT, before *resourceList) {
+	after, err := listResources(sc.Config)
+	if err != nil {
+		sc.Config.logger().Error(err, "checking for leaked resources failed")
+		t.Fail()
+		sc.suiteChecksFailed = true
+		return
+	}
+
+	createdVolumes, createdSnapshots := sc.results.created()
+	sc.leakedVolumes = leakedIDs(before.volumes, after.volumes, createdVolumes)
+	sc.leakedSnapshots = leakedIDs(before.snapshots, after.snapshots, createdSnapshots)
+	if len(sc.leakedVolumes) == 0 && len(sc.leakedSnapshots) == 0 {
+		return
+	}
+
+	msg := "resources were not cleaned up by the test suite"
+	if sc.Config.LeakCheck == LeakCheckFail {
+		sc.Config.logger().Error(nil, msg, "volumes", sc.leakedVolumes, "snapshots", sc.leakedSnapshots)
+		t.Fail()
+		sc.suiteChecksFailed = true
+	} else {
+		sc.Config.logger().Warning(msg, "volumes", sc.leakedVolumes, "snapshots", sc.leakedSnapshots)
+	}
+}
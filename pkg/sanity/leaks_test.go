@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLeakedIDs(t *testing.T) {
+	suffixed := "sanity-volume" + uniqueSuffix
+	for name, tc := range map[string]struct {
+		before, after, created map[string]bool
+		expected               []string
+	}{
+		"nothing": {},
+		"unchanged": {
+			before:  map[string]bool{"a": true},
+			after:   map[string]bool{"a": true},
+			created: map[string]bool{"a": true},
+		},
+		"deleted": {
+			before:  map[string]bool{"a": true},
+			created: map[string]bool{"b": true},
+		},
+		"created": {
+			after:    map[string]bool{"b": true, "a": true},
+			created:  map[string]bool{"a": true, "b": true},
+			expected: []string{"a", "b"},
+		},
+		"suffix": {
+			after:    map[string]bool{suffixed: true},
+			expected: []string{suffixed},
+		},
+		"foreign": {
+			after: map[string]bool{"someone-else": true},
+		},
+		"preexisting": {
+			before:  map[string]bool{suffixed: true},
+			after:   map[string]bool{suffixed: true},
+			created: map[string]bool{suffixed: true},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			leaked := leakedIDs(tc.before, tc.after, tc.created)
+			if !reflect.DeepEqual(leaked, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, leaked)
+			}
+		})
+	}
+}
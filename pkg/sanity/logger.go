@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Logger receives the log output of the sanity package. The
+// keysAndValues are alternating keys, which are strings, and
+// arbitrary values, like in klog.InfoS.
+type Logger interface {
+	// Info logs a message at the verbosity level. 0 is always
+	// shown, 3 reports retries and 4 the bookkeeping of created
+	// resources.
+	Info(level int, msg string, keysAndValues ...interface{})
+	// Warning logs a problem which does not fail the run.
+	Warning(msg string, keysAndValues ...interface{})
+	// Error logs a problem, err may be nil.
+	Error(err error, msg string, keysAndValues ...interface{})
+}
+
+// klogLogger is the default Logger. csi-sanity redirects klog into
+// the GinkgoWriter, so that log output is shown with the spec which
+// produced it.
+type klogLogger struct{}
+
+func (klogLogger) Info(level int, msg string, keysAndValues ...interface{}) {
+	klog.V(klog.Level(level)).InfoS(msg, keysAndValues...)
+}
+
+func (klogLogger) Warning(msg string, keysAndValues ...interface{}) {
+	klog.WarningDepth(1, formatLogLine(msg, keysAndValues))
+}
+
+func (klogLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	klog.ErrorS(err, msg, keysAndValues...)
+}
+
+// writerLogger writes one line per message.
+type writerLogger struct {
+	mutex     sync.Mutex
+	out       io.Writer
+	verbosity int
+}
+
+// NewWriterLogger returns a Logger which writes messages up to the
+// verbosity level as lines like
+//
+//	I1015 10:04:05.123456 retrying method="/csi.v1.Node/NodeGetInfo" attempt=1
+//
+// Use it with GinkgoWriter to show the log output only for
+// failed specs.
+func NewWriterLogger(out io.Writer, verbosity int) Logger {
+	return &writerLogger{out: out, verbosity: verbosity}
+}
+
+func (l *writerLogger) Info(level int, msg string, keysAndValues ...interface{}) {
+	if level <= l.verbosity {
+		l.write("I", msg, keysAndValues)
+	}
+}
+
+func (l *writerLogger) Warning(msg string, keysAndValues ...interface{}) {
+	l.write("W", msg, keysAndValues)
+}
+
+func (l *writerLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err != nil {
+		keysAndValues = append([]interface{}{"err", err}, keysAndValues...)
+	}
+	l.write("E", msg, keysAndValues)
+}
+
+func (l *writerLogger) write(severity, msg string, keysAndValues []interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fmt.Fprintf(l.out, "%s%s %s\n", severity, time.Now().Format("0102 15:04:05.000000"), formatLogLine(msg, keysAndValues))
+}
+
+// formatLogLine appends the key/value pairs to the message, quoting
+// strings and errors.
+func formatLogLine(msg string, keysAndValues []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		switch v := value.(type) {
+		case string:
+			value = fmt.Sprintf("%q", v)
+		case error:
+			value = fmt.Sprintf("%q", v.Error())
+		case fmt.Stringer:
+			value = fmt.Sprintf("%q", v.String())
+		}
+		fmt.Fprintf(&b, " %v=%v", keysAndValues[i], value)
+	}
+	return b.String()
+}
+
+// logger returns TestConfig.Logger or the default.
+func (config *TestConfig) logger() Logger {
+	if config == nil || config.Logger == nil {
+		return klogLogger{}
+	}
+	return config.Logger
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// withMetadata returns the context with the entries attached as
+// outgoing gRPC metadata.
+func withMetadata(ctx context.Context, entries map[string]string) context.Context {
+	if len(entries) == 0 {
+		return ctx
+	}
+	kv := make([]string, 0, 2*len(entries))
+	for key, value := range entries {
+		kv = append(kv, key, value)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// metadataInterceptor attaches TestConfig.Metadata to every call.
+func (sc *TestContext) metadataInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(withMetadata(ctx, sc.Config.Metadata), method, req, reply, cc, opts...)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// migrationInfo describes what Kubernetes CSI migration expects from
+// the CSI driver which replaces an in-tree volume plugin. The values
+// must be kept in sync with k8s.io/csi-translation-lib.
+type migrationInfo struct {
+	// driverName is the name that GetPluginInfo must return.
+	driverName string
+	// topologyKey is the key which the translation library uses for
+	// zones, empty if it does not translate topology.
+	topologyKey string
+	// fsType is the default file system type of the in-tree plugin,
+	// which gets passed to the driver for migrated volumes. Empty if
+	// the plugin does not use one.
+	fsType string
+}
+
+var migratedInTreePlugins = map[string]migrationInfo{
+	"kubernetes.io/aws-ebs": {
+		driverName:  "ebs.csi.aws.com",
+		topologyKey: "topology.ebs.csi.aws.com/zone",
+		fsType:      "ext4",
+	},
+	"kubernetes.io/gce-pd": {
+		driverName:  "pd.csi.storage.gke.io",
+		topologyKey: "topology.gke.io/zone",
+		fsType:      "ext4",
+	},
+	"kubernetes.io/azure-disk": {
+		driverName:  "disk.csi.azure.com",
+		topologyKey: "topology.disk.csi.azure.com/zone",
+		fsType:      "ext4",
+	},
+	"kubernetes.io/azure-file": {
+		driverName: "file.csi.azure.com",
+	},
+	"kubernetes.io/cinder": {
+		driverName:  "cinder.csi.openstack.org",
+		topologyKey: "topology.cinder.csi.openstack.org/zone",
+		fsType:      "ext4",
+	},
+	"kubernetes.io/vsphere-volume": {
+		driverName: "csi.vsphere.vmware.com",
+		fsType:     "ext4",
+	},
+	"kubernetes.io/portworx-volume": {
+		driverName: "pxd.portworx.com",
+		fsType:     "ext4",
+	},
+}
+
+// knownMigratedInTreePlugins returns the sorted names of all in-tree
+// plugins for which migration checks are available.
+func knownMigratedInTreePlugins() string {
+	var names []string
+	for name := range migratedInTreePlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+var _ = DescribeSanity("CSI Migration", func(sc *TestContext) {
+	var (
+		r    *Resources
+		info migrationInfo
+	)
+
+	BeforeEach(func() {
+		r = &Resources{
+			Context:          sc,
+			ControllerClient: csi.NewControllerClient(sc.ControllerConn),
+			NodeClient:       csi.NewNodeClient(sc.Conn),
+		}
+
+		if sc.Config.MigratedInTreePluginName == "" {
+			Skip("Config.MigratedInTreePluginName not set")
+		}
+		var ok bool
+		info, ok = migratedInTreePlugins[sc.Config.MigratedInTreePluginName]
+		if !ok {
+			Fail(fmt.Sprintf("unknown in-tree plugin %q in Config.MigratedInTreePluginName, must be one of: %s",
+				sc.Config.MigratedInTreePluginName, knownMigratedInTreePlugins()))
+		}
+	})
+
+	AfterEach(func() {
+		r.Cleanup()
+	})
+
+	It(SpecID("migration/driver-name", "should use the driver name expected for the in-tree plugin"), func() {
+		res, err := csi.NewIdentityClient(sc.Conn).GetPluginInfo(context.Background(), &csi.GetPluginInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).NotTo(BeNil())
+		Expect(res.GetName()).To(Equal(info.driverName), "driver name does not match the CSI migration of %s", sc.Config.MigratedInTreePluginName)
+	})
+
+	It(SpecID("migration/topology-key", "should use the topology key expected for the in-tree plugin"), func() {
+		if info.topologyKey == "" {
+			Skip(fmt.Sprintf("CSI migration of %s does not translate topology", sc.Config.MigratedInTreePluginName))
+		}
+
+		nid, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nid).NotTo(BeNil())
+		Expect(nid.GetAccessibleTopology()).NotTo(BeNil(), "node reports no accessible topology")
+		Expect(nid.GetAccessibleTopology().GetSegments()).To(HaveKey(info.topologyKey),
+			"accessible topology of the node lacks the key used by the CSI migration of %s", sc.Config.MigratedInTreePluginName)
+	})
+
+	It(SpecID("migration/fs-type", "should accept the default file system type of the in-tree plugin"), func() {
+		if info.fsType == "" {
+			Skip(fmt.Sprintf("%s does not use a file system type", sc.Config.MigratedInTreePluginName))
+		}
+		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME) {
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME), "CreateVolume not supported")
+		}
+
+		capability := TestVolumeCapability(sc)
+		if capability.GetMount() == nil {
+			Skip("the test volume capability is not for a mounted volume")
+		}
+		capability.GetMount().FsType = info.fsType
+
+		By(fmt.Sprintf("creating a volume with file system type %s", info.fsType))
+		req := MakeCreateVolumeReq(sc, UniqueString("sanity-migration-fstype"))
+		req.VolumeCapabilities = []*csi.VolumeCapability{capability}
+		r.MustCreateVolume(context.Background(), req)
+	})
+})
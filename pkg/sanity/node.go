@@ -19,11 +19,14 @@ package sanity
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/proto"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -87,14 +90,12 @@ func runControllerTest(sc *TestContext, r *Resources, controllerPublishSupported
 	}
 
 	// Create Volume First
-	By("creating a single node writer volume")
+	By("creating a volume")
 	vol := r.MustCreateVolume(
 		context.Background(),
 		&csi.CreateVolumeRequest{
-			Name: name,
-			VolumeCapabilities: []*csi.VolumeCapability{
-				TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
-			},
+			Name:               name,
+			VolumeCapabilities: testVolumeCapabilities(sc),
 			CapacityRange: &csi.CapacityRange{
 				RequiredBytes: TestVolumeSize(sc),
 			},
@@ -113,7 +114,7 @@ func runControllerTest(sc *TestContext, r *Resources, controllerPublishSupported
 			&csi.ControllerPublishVolumeRequest{
 				VolumeId:         vol.GetVolume().GetVolumeId(),
 				NodeId:           ni.GetNodeId(),
-				VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+				VolumeCapability: TestVolumeCapability(sc),
 				VolumeContext:    vol.GetVolume().GetVolumeContext(),
 				Readonly:         false,
 				Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
@@ -128,14 +129,7 @@ func runControllerTest(sc *TestContext, r *Resources, controllerPublishSupported
 			By("node staging volume")
 			nodestagevol, err := r.NodeStageVolume(
 				context.Background(),
-				&csi.NodeStageVolumeRequest{
-					VolumeId:          vol.GetVolume().GetVolumeId(),
-					VolumeCapability:  TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
-					StagingTargetPath: sc.StagingPath,
-					VolumeContext:     vol.GetVolume().GetVolumeContext(),
-					PublishContext:    conpubvol.GetPublishContext(),
-					Secrets:           sc.Secrets.NodeStageVolumeSecret,
-				},
+				MakeNodeStageVolumeReq(sc, vol.GetVolume(), conpubvol.GetPublishContext()),
 			)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(nodestagevol).NotTo(BeNil())
@@ -150,15 +144,7 @@ func runControllerTest(sc *TestContext, r *Resources, controllerPublishSupported
 		By("publishing the volume on a node")
 		nodepubvol, err := r.NodePublishVolume(
 			context.Background(),
-			&csi.NodePublishVolumeRequest{
-				VolumeId:          vol.GetVolume().GetVolumeId(),
-				TargetPath:        sc.TargetPath + "/target",
-				StagingTargetPath: stagingPath,
-				VolumeCapability:  TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
-				VolumeContext:     vol.GetVolume().GetVolumeContext(),
-				PublishContext:    conpubvol.GetPublishContext(),
-				Secrets:           sc.Secrets.NodePublishVolumeSecret,
-			},
+			MakeNodePublishVolumeReq(sc, vol.GetVolume(), conpubvol.GetPublishContext(), stagingPath, filepath.Join(sc.TargetPath, "target")),
 		)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(nodepubvol).NotTo(BeNil())
@@ -171,7 +157,7 @@ func runControllerTest(sc *TestContext, r *Resources, controllerPublishSupported
 			context.Background(),
 			&csi.NodeGetVolumeStatsRequest{
 				VolumeId:   vol.GetVolume().GetVolumeId(),
-				VolumePath: sc.TargetPath + "/target",
+				VolumePath: filepath.Join(sc.TargetPath, "target"),
 			},
 		)
 		Expect(err).ToNot(HaveOccurred())
@@ -179,7 +165,77 @@ func runControllerTest(sc *TestContext, r *Resources, controllerPublishSupported
 	}
 }
 
-var _ = DescribeSanity("Node Service", func(sc *TestContext) {
+// MakeNodeStageVolumeReq creates and returns a NodeStageVolumeRequest
+// which stages the volume at the staging path of the test.
+func MakeNodeStageVolumeReq(sc *TestContext, vol *csi.Volume, publishContext map[string]string) *csi.NodeStageVolumeRequest {
+	return &csi.NodeStageVolumeRequest{
+		VolumeId:          vol.GetVolumeId(),
+		VolumeCapability:  TestVolumeCapability(sc),
+		StagingTargetPath: sc.StagingPath,
+		VolumeContext:     vol.GetVolumeContext(),
+		PublishContext:    publishContext,
+		Secrets:           sc.Secrets.NodeStageVolumeSecret,
+	}
+}
+
+// MakeNodeUnstageVolumeReq creates and returns a NodeUnstageVolumeRequest
+// for the staging path of the test.
+func MakeNodeUnstageVolumeReq(sc *TestContext, volID string) *csi.NodeUnstageVolumeRequest {
+	return &csi.NodeUnstageVolumeRequest{
+		VolumeId:          volID,
+		StagingTargetPath: sc.StagingPath,
+	}
+}
+
+// MakeNodePublishVolumeReq creates and returns a NodePublishVolumeRequest.
+// stagingPath must be empty if the driver does not support staging.
+func MakeNodePublishVolumeReq(sc *TestContext, vol *csi.Volume, publishContext map[string]string, stagingPath, targetPath string) *csi.NodePublishVolumeRequest {
+	return &csi.NodePublishVolumeRequest{
+		VolumeId:          vol.GetVolumeId(),
+		TargetPath:        targetPath,
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  TestVolumeCapability(sc),
+		VolumeContext:     vol.GetVolumeContext(),
+		PublishContext:    publishContext,
+		Secrets:           sc.Secrets.NodePublishVolumeSecret,
+	}
+}
+
+// MakeNodeUnpublishVolumeReq creates and returns a NodeUnpublishVolumeRequest.
+func MakeNodeUnpublishVolumeReq(volID, targetPath string) *csi.NodeUnpublishVolumeRequest {
+	return &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   volID,
+		TargetPath: targetPath,
+	}
+}
+
+// maxMapSize is the general size limit for map fields in the CSI spec.
+const maxMapSize = 4 * 1024
+
+// largeVolumeContext returns a copy of the volume context, extended
+// with additional entries until the total size of keys and values is
+// close to maxMapSize. Each key and value stays below the 128 byte
+// limit for strings.
+func largeVolumeContext(volumeContext map[string]string) map[string]string {
+	result := map[string]string{}
+	size := 0
+	for key, value := range volumeContext {
+		result[key] = value
+		size += len(key) + len(value)
+	}
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("csi-sanity-large-context-%03d", i)
+		value := strings.Repeat(fmt.Sprintf("%03d-0123456789abcdef-", i), 5)
+		if size+len(key)+len(value) >= maxMapSize-64 {
+			break
+		}
+		result[key] = value
+		size += len(key) + len(value)
+	}
+	return result
+}
+
+var _ = describeParameterized("Node Service", func(sc *TestContext) {
 	var (
 		r *Resources
 
@@ -189,6 +245,10 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 		nodeVolumeStatsSupported     bool
 		nodeExpansionSupported       bool
 		controllerExpansionSupported bool
+		snapshotSupported            bool
+		onlineExpansionSupported     bool
+		offlineExpansionSupported    bool
+		multiWriterSupported         bool
 	)
 
 	createVolume := func(volumeName string) *csi.CreateVolumeResponse {
@@ -198,7 +258,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			&csi.CreateVolumeRequest{
 				Name: volumeName,
 				VolumeCapabilities: []*csi.VolumeCapability{
-					TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					TestVolumeCapability(sc),
 				},
 				CapacityRange: &csi.CapacityRange{
 					RequiredBytes: TestVolumeSize(sc),
@@ -219,7 +279,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 				&csi.ControllerPublishVolumeRequest{
 					VolumeId:         vol.GetVolume().GetVolumeId(),
 					NodeId:           nid.GetNodeId(),
-					VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					VolumeCapability: TestVolumeCapability(sc),
 					VolumeContext:    vol.GetVolume().GetVolumeContext(),
 					Readonly:         false,
 					Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
@@ -235,7 +295,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			By("node staging volume")
 			nodeStageRequest := &csi.NodeStageVolumeRequest{
 				VolumeId:          vol.GetVolume().GetVolumeId(),
-				VolumeCapability:  TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+				VolumeCapability:  TestVolumeCapability(sc),
 				StagingTargetPath: sc.StagingPath,
 				VolumeContext:     vol.GetVolume().GetVolumeContext(),
 				Secrets:           sc.Secrets.NodeStageVolumeSecret,
@@ -262,9 +322,9 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 		}
 		nodePublishRequest := &csi.NodePublishVolumeRequest{
 			VolumeId:          vol.GetVolume().GetVolumeId(),
-			TargetPath:        sc.TargetPath + "/target",
+			TargetPath:        filepath.Join(sc.TargetPath, "target"),
 			StagingTargetPath: stagingPath,
-			VolumeCapability:  TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+			VolumeCapability:  TestVolumeCapability(sc),
 			VolumeContext:     vol.GetVolume().GetVolumeContext(),
 			Secrets:           sc.Secrets.NodePublishVolumeSecret,
 		}
@@ -298,17 +358,30 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 				case csi.PluginCapability_Service_CONTROLLER_SERVICE:
 					providesControllerService = true
 				}
+			case *csi.PluginCapability_VolumeExpansion_:
+				switch cap.GetVolumeExpansion().GetType() {
+				case csi.PluginCapability_VolumeExpansion_ONLINE:
+					onlineExpansionSupported = true
+				case csi.PluginCapability_VolumeExpansion_OFFLINE:
+					offlineExpansionSupported = true
+				}
 			}
 		}
 		if providesControllerService {
 			controllerPublishSupported = isControllerCapabilitySupported(
 				cl,
 				csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME)
+			snapshotSupported = isControllerCapabilitySupported(
+				cl,
+				csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT)
 		}
 		nodeStageSupported = isNodeCapabilitySupported(n, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME)
 		nodeVolumeStatsSupported = isNodeCapabilitySupported(n, csi.NodeServiceCapability_RPC_GET_VOLUME_STATS)
 		nodeExpansionSupported = isNodeCapabilitySupported(n, csi.NodeServiceCapability_RPC_EXPAND_VOLUME)
 		controllerExpansionSupported = isControllerCapabilitySupported(cl, csi.ControllerServiceCapability_RPC_EXPAND_VOLUME)
+		multiWriterSupported = providesControllerService &&
+			isControllerCapabilitySupported(cl, csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER) &&
+			isNodeCapabilitySupported(n, csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER)
 		r = &Resources{
 			Context:          sc,
 			ControllerClient: cl,
@@ -321,7 +394,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 	})
 
 	Describe("NodeGetCapabilities", func() {
-		It("should return appropriate capabilities", func() {
+		It(SpecID("nodegetcapabilities/capabilities", "should return appropriate capabilities"), func() {
 			caps, err := r.NodeGetCapabilities(
 				context.Background(),
 				&csi.NodeGetCapabilitiesRequest{})
@@ -359,7 +432,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			accessibilityConstraintSupported = isPluginCapabilitySupported(i, csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS)
 		})
 
-		It("should return appropriate values", func() {
+		It(SpecID("nodegetinfo/values", "should return appropriate values"), func() {
 			ninfo, err := r.NodeGetInfo(
 				context.Background(),
 				&csi.NodeGetInfoRequest{})
@@ -367,6 +440,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(ninfo).NotTo(BeNil())
 			Expect(ninfo.GetNodeId()).NotTo(BeEmpty())
+			Expect(len(ninfo.GetNodeId())).To(BeNumerically("<=", MaxIDLength), "node ID %q is longer than %d bytes", ninfo.GetNodeId(), MaxIDLength)
 			Expect(ninfo.GetMaxVolumesPerNode()).NotTo(BeNumerically("<", 0))
 
 			if accessibilityConstraintSupported {
@@ -376,7 +450,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 	})
 
 	Describe("NodePublishVolume", func() {
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("nodepublishvolume/no-volume-id", "should fail when no volume id is provided"), func() {
 			_, err := r.NodePublishVolume(
 				context.Background(),
 				&csi.NodePublishVolumeRequest{
@@ -390,7 +464,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no target path is provided", func() {
+		It(SpecID("nodepublishvolume/no-target-path", "should fail when no target path is provided"), func() {
 			_, err := r.NodePublishVolume(
 				context.Background(),
 				&csi.NodePublishVolumeRequest{
@@ -405,13 +479,13 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no volume capability is provided", func() {
+		It(SpecID("nodepublishvolume/no-capability", "should fail when no volume capability is provided"), func() {
 			_, err := r.NodePublishVolume(
 				context.Background(),
 				&csi.NodePublishVolumeRequest{
 					VolumeId:         sc.Config.IDGen.GenerateUniqueValidVolumeID(),
 					VolumeCapability: nil,
-					TargetPath:       sc.TargetPath + "/target",
+					TargetPath:       filepath.Join(sc.TargetPath, "target"),
 					Secrets:          sc.Secrets.NodePublishVolumeSecret,
 				},
 			)
@@ -421,10 +495,38 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(ok).To(BeTrue())
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
+
+		It(SpecID("nodepublishvolume/large-volume-context", "should work with a large volume context"), func() {
+			if !providesControllerService {
+				skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+			}
+
+			name := UniqueString("sanity-node-large-volume-context")
+
+			vol := createVolume(name)
+			vol = proto.Clone(vol).(*csi.CreateVolumeResponse)
+			vol.Volume.VolumeContext = largeVolumeContext(vol.GetVolume().GetVolumeContext())
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+			Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+			conpubvol := controllerPublishVolume(name, vol, nid)
+
+			// NodeStageVolume
+			_ = nodeStageVolume(name, vol, conpubvol)
+
+			// NodePublishVolume
+			_ = nodePublishVolume(name, vol, conpubvol)
+		})
 	})
 
 	Describe("NodeUnpublishVolume", func() {
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("nodeunpublishvolume/no-volume-id", "should fail when no volume id is provided"), func() {
 
 			_, err := r.NodeUnpublishVolume(
 				context.Background(),
@@ -436,7 +538,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no target path is provided", func() {
+		It(SpecID("nodeunpublishvolume/no-target-path", "should fail when no target path is provided"), func() {
 
 			_, err := r.NodeUnpublishVolume(
 				context.Background(),
@@ -450,7 +552,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should remove target path", func() {
+		It(SpecID("nodeunpublishvolume/remove-target-path", "should remove target path"), func() {
 			// This test may break for consumers that are using
 			// custom target path functions if they have not yet
 			// implemented similar functionality to check if the
@@ -467,7 +569,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			name := UniqueString("sanity-node-unpublish-volume")
 			vol := createVolume(name)
 			volid := vol.GetVolume().GetVolumeId()
-			volpath := sc.TargetPath + "/target"
+			volpath := filepath.Join(sc.TargetPath, "target")
 
 			By("Getting a node id")
 			nid, err := r.NodeGetInfo(
@@ -516,18 +618,18 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 
 		BeforeEach(func() {
 			if !nodeStageSupported {
-				Skip("NodeStageVolume not supported")
+				skipUnsupported(sc, NodeCapability(csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME), "NodeStageVolume not supported")
 			}
 
 			device = "/dev/mock"
 		})
 
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("nodestagevolume/no-volume-id", "should fail when no volume id is provided"), func() {
 			_, err := r.NodeStageVolume(
 				context.Background(),
 				&csi.NodeStageVolumeRequest{
 					StagingTargetPath: sc.StagingPath,
-					VolumeCapability:  TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					VolumeCapability:  TestVolumeCapability(sc),
 					PublishContext: map[string]string{
 						"device": device,
 					},
@@ -541,12 +643,32 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no staging target path is provided", func() {
+		It(SpecID("nodestagevolume/too-long-volume-id", "should fail when the volume id exceeds the maximum length"), func() {
+			_, err := r.NodeStageVolume(
+				context.Background(),
+				&csi.NodeStageVolumeRequest{
+					VolumeId:          tooLongID(),
+					StagingTargetPath: sc.StagingPath,
+					VolumeCapability:  TestVolumeCapability(sc),
+					PublishContext: map[string]string{
+						"device": device,
+					},
+					Secrets: sc.Secrets.NodeStageVolumeSecret,
+				},
+			)
+			Expect(err).To(HaveOccurred())
+
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Or(Equal(codes.InvalidArgument), Equal(codes.NotFound)), "unexpected error: %s", serverError.Message())
+		})
+
+		It(SpecID("nodestagevolume/no-staging-target-path", "should fail when no staging target path is provided"), func() {
 			_, err := r.NodeStageVolume(
 				context.Background(),
 				&csi.NodeStageVolumeRequest{
 					VolumeId:         sc.Config.IDGen.GenerateUniqueValidVolumeID(),
-					VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					VolumeCapability: TestVolumeCapability(sc),
 					PublishContext: map[string]string{
 						"device": device,
 					},
@@ -560,10 +682,10 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no volume capability is provided", func() {
+		It(SpecID("nodestagevolume/no-capability", "should fail when no volume capability is provided"), func() {
 
 			// Create Volume First
-			By("creating a single node writer volume")
+			By("creating a volume")
 			name := UniqueString("sanity-node-stage-nocaps")
 
 			vol := r.MustCreateVolume(
@@ -571,14 +693,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 				&csi.CreateVolumeRequest{
 					Name: name,
 					VolumeCapabilities: []*csi.VolumeCapability{
-						{
-							AccessType: &csi.VolumeCapability_Mount{
-								Mount: &csi.VolumeCapability_MountVolume{},
-							},
-							AccessMode: &csi.VolumeCapability_AccessMode{
-								Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
-							},
-						},
+						TestVolumeCapability(sc),
 					},
 					Secrets:    sc.Secrets.CreateVolumeSecret,
 					Parameters: sc.Config.TestVolumeParameters,
@@ -602,16 +717,175 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(ok).To(BeTrue())
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
+
+		It(SpecID("nodestagevolume/conflicting-capability", "should fail when staging a staged volume again with an incompatible capability"), func() {
+			if !providesControllerService {
+				skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+			}
+
+			name := UniqueString("sanity-node-stage-conflicting-caps")
+			vol := createVolume(name)
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+
+			conpubvol := controllerPublishVolume(name, vol, nid)
+			_ = nodeStageVolume(name, vol, conpubvol)
+
+			By("node staging the volume again with a different access type")
+			volCap := TestVolumeCapability(sc)
+			otherCap := &csi.VolumeCapability{
+				AccessMode: volCap.GetAccessMode(),
+				AccessType: &csi.VolumeCapability_Block{
+					Block: &csi.VolumeCapability_BlockVolume{},
+				},
+			}
+			if volCap.GetBlock() != nil {
+				otherCap.AccessType = &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				}
+			}
+			_, err = r.NodeStageVolume(
+				context.Background(),
+				&csi.NodeStageVolumeRequest{
+					VolumeId:          vol.GetVolume().GetVolumeId(),
+					VolumeCapability:  otherCap,
+					StagingTargetPath: sc.StagingPath,
+					VolumeContext:     vol.GetVolume().GetVolumeContext(),
+					PublishContext:    conpubvol.GetPublishContext(),
+					Secrets:           sc.Secrets.NodeStageVolumeSecret,
+				},
+			)
+			Expect(err).To(HaveOccurred(), "staging a staged volume with an incompatible capability must fail")
+
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Or(Equal(codes.AlreadyExists), Equal(codes.InvalidArgument)), "unexpected error: %s", serverError.Message())
+		})
+
+		It(SpecID("nodestagevolume/second-staging-path", "should reject or support staging a staged volume at a second staging path"), func() {
+			if !providesControllerService {
+				skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+			}
+
+			name := UniqueString("sanity-node-stage-second-path")
+			vol := createVolume(name)
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+
+			conpubvol := controllerPublishVolume(name, vol, nid)
+			_ = nodeStageVolume(name, vol, conpubvol)
+
+			By("creating a second staging directory")
+			secondStagingPath, err := createMountTargetLocation(sc.Config.StagingPath+"-second", sc.Config.CreateStagingPathCmd, sc.Config.CreateStagingDir, sc.Config.CreatePathCmdTimeout)
+			Expect(err).NotTo(HaveOccurred(), "failed to create staging directory %s", secondStagingPath)
+			r.registerStagingDir(secondStagingPath)
+
+			By("node staging the volume at the second staging path")
+			var publishContext map[string]string
+			if conpubvol != nil {
+				publishContext = conpubvol.GetPublishContext()
+			}
+			_, err = r.NodeStageVolume(
+				context.Background(),
+				&csi.NodeStageVolumeRequest{
+					VolumeId:          vol.GetVolume().GetVolumeId(),
+					VolumeCapability:  TestVolumeCapability(sc),
+					StagingTargetPath: secondStagingPath,
+					VolumeContext:     vol.GetVolume().GetVolumeContext(),
+					PublishContext:    publishContext,
+					Secrets:           sc.Secrets.NodeStageVolumeSecret,
+				},
+			)
+			if err == nil {
+				sc.results.setMultiStage("supported")
+
+				By("node unstaging the volume from the second staging path")
+				_, err := r.NodeUnstageVolume(
+					context.Background(),
+					&csi.NodeUnstageVolumeRequest{
+						VolumeId:          vol.GetVolume().GetVolumeId(),
+						StagingTargetPath: secondStagingPath,
+					},
+				)
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+
+			sc.results.setMultiStage("rejected")
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Or(Equal(codes.FailedPrecondition), Equal(codes.AlreadyExists)), "unexpected error: %s", serverError.Message())
+		})
+
+		It(SpecID("nodestagevolume/requires-controller-publish", "should fail without ControllerPublishVolume if the driver requires it"), func() {
+			if !providesControllerService {
+				skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+			}
+			if !controllerPublishSupported {
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME), "ControllerPublishVolume not supported")
+			}
+
+			name := UniqueString("sanity-node-stage-unpublished")
+			vol := createVolume(name)
+
+			By("node staging the volume without controller publishing it")
+			_, err := r.NodeStageVolume(
+				context.Background(),
+				&csi.NodeStageVolumeRequest{
+					VolumeId:          vol.GetVolume().GetVolumeId(),
+					VolumeCapability:  TestVolumeCapability(sc),
+					StagingTargetPath: sc.StagingPath,
+					VolumeContext:     vol.GetVolume().GetVolumeContext(),
+					Secrets:           sc.Secrets.NodeStageVolumeSecret,
+				},
+			)
+			Expect(err).To(HaveOccurred(), "staging a volume which was not controller published must fail when the driver has the PUBLISH_UNPUBLISH_VOLUME capability")
+		})
+
+		It(SpecID("nodestagevolume/without-controller-publish", "should work without ControllerPublishVolume if the driver does not support it"), func() {
+			if !providesControllerService {
+				skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+			}
+			if controllerPublishSupported {
+				Skip("ControllerPublishVolume supported, it must be called before NodeStageVolume")
+			}
+
+			name := UniqueString("sanity-node-stage-without-publish")
+			vol := createVolume(name)
+
+			By("node staging the volume without publish context")
+			_ = nodeStageVolume(name, vol, nil)
+
+			By("node unstaging the volume")
+			_, err := r.NodeUnstageVolume(
+				context.Background(),
+				&csi.NodeUnstageVolumeRequest{
+					VolumeId:          vol.GetVolume().GetVolumeId(),
+					StagingTargetPath: sc.StagingPath,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
 	})
 
 	Describe("NodeUnstageVolume", func() {
 		BeforeEach(func() {
 			if !nodeStageSupported {
-				Skip("NodeUnstageVolume not supported")
+				skipUnsupported(sc, NodeCapability(csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME), "NodeUnstageVolume not supported")
 			}
 		})
 
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("nodeunstagevolume/no-volume-id", "should fail when no volume id is provided"), func() {
 
 			_, err := r.NodeUnstageVolume(
 				context.Background(),
@@ -625,7 +899,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no staging target path is provided", func() {
+		It(SpecID("nodeunstagevolume/no-staging-target-path", "should fail when no staging target path is provided"), func() {
 
 			_, err := r.NodeUnstageVolume(
 				context.Background(),
@@ -643,11 +917,11 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 	Describe("NodeGetVolumeStats", func() {
 		BeforeEach(func() {
 			if !nodeVolumeStatsSupported {
-				Skip("NodeGetVolume not supported")
+				skipUnsupported(sc, NodeCapability(csi.NodeServiceCapability_RPC_GET_VOLUME_STATS), "NodeGetVolume not supported")
 			}
 		})
 
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("nodegetvolumestats/no-volume-id", "should fail when no volume id is provided"), func() {
 			_, err := r.NodeGetVolumeStats(
 				context.Background(),
 				&csi.NodeGetVolumeStatsRequest{
@@ -661,7 +935,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no volume path is provided", func() {
+		It(SpecID("nodegetvolumestats/no-volume-path", "should fail when no volume path is provided"), func() {
 			_, err := r.NodeGetVolumeStats(
 				context.Background(),
 				&csi.NodeGetVolumeStatsRequest{
@@ -675,7 +949,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when volume is not found", func() {
+		It(SpecID("nodegetvolumestats/missing-volume", "should fail when volume is not found"), func() {
 			_, err := r.NodeGetVolumeStats(
 				context.Background(),
 				&csi.NodeGetVolumeStatsRequest{
@@ -690,7 +964,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.NotFound), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when volume does not exist on the specified path", func() {
+		It(SpecID("nodegetvolumestats/wrong-path", "should fail when volume does not exist on the specified path"), func() {
 			name := UniqueString("sanity-node-get-volume-stats")
 
 			vol := createVolume(name)
@@ -727,22 +1001,62 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.NotFound), "unexpected error: %s", serverError.Message())
 		})
 
+		It(SpecID("nodegetvolumestats/block-bytes-only", "should only return byte usage for a block volume"), func() {
+			if at := strings.TrimSpace(strings.ToLower(sc.Config.TestVolumeAccessType)); at != "block" {
+				Skip("Block volumes are only tested with TestVolumeAccessType block")
+			}
+
+			name := UniqueString("sanity-node-get-volume-stats-block")
+
+			vol := createVolume(name)
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+			Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+			conpubvol := controllerPublishVolume(name, vol, nid)
+
+			// NodeStageVolume
+			_ = nodeStageVolume(name, vol, conpubvol)
+
+			// NodePublishVolume
+			_ = nodePublishVolume(name, vol, conpubvol)
+
+			// NodeGetVolumeStats
+			By("Get node volume stats")
+			statsResp, err := r.NodeGetVolumeStats(
+				context.Background(),
+				&csi.NodeGetVolumeStatsRequest{
+					VolumeId:   vol.GetVolume().GetVolumeId(),
+					VolumePath: filepath.Join(sc.TargetPath, "target"),
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statsResp.GetUsage()).NotTo(BeEmpty())
+			for _, usage := range statsResp.GetUsage() {
+				Expect(usage.GetUnit()).To(Equal(csi.VolumeUsage_BYTES), "block volumes have no inodes")
+			}
+		})
 	})
 
 	Describe("NodeExpandVolume", func() {
 		BeforeEach(func() {
 			if !nodeExpansionSupported {
-				Skip("NodeExpandVolume not supported")
+				skipUnsupported(sc, NodeCapability(csi.NodeServiceCapability_RPC_EXPAND_VOLUME), "NodeExpandVolume not supported")
 			}
 
 		})
 
-		It("should fail when no volume id is provided", func() {
+		It(SpecID("nodeexpandvolume/no-volume-id", "should fail when no volume id is provided"), func() {
 			_, err := r.NodeExpandVolume(
 				context.Background(),
 				&csi.NodeExpandVolumeRequest{
 					VolumePath:       sc.TargetPath,
-					VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					VolumeCapability: TestVolumeCapability(sc),
 				},
 			)
 			Expect(err).To(HaveOccurred())
@@ -752,7 +1066,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when no volume path is provided", func() {
+		It(SpecID("nodeexpandvolume/no-volume-path", "should fail when no volume path is provided"), func() {
 			name := UniqueString("sanity-node-expand-volume-valid-id")
 
 			vol := createVolume(name)
@@ -761,7 +1075,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 				context.Background(),
 				&csi.NodeExpandVolumeRequest{
 					VolumeId:         vol.GetVolume().VolumeId,
-					VolumeCapability: TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+					VolumeCapability: TestVolumeCapability(sc),
 				},
 			)
 			Expect(err).To(HaveOccurred())
@@ -771,7 +1085,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.InvalidArgument), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should fail when volume is not found", func() {
+		It(SpecID("nodeexpandvolume/missing-volume", "should fail when volume is not found"), func() {
 			_, err := r.NodeExpandVolume(
 				context.Background(),
 				&csi.NodeExpandVolumeRequest{
@@ -786,7 +1100,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 			Expect(serverError.Code()).To(Equal(codes.NotFound), "unexpected error: %s", serverError.Message())
 		})
 
-		It("should work if node-expand is called after node-publish", func() {
+		It(SpecID("nodeexpandvolume/after-node-publish", "should work if node-expand is called after node-publish"), func() {
 			name := UniqueString("sanity-node-expand-volume")
 
 			// Created volumes are automatically cleaned up via cl.DeleteVolumes
@@ -828,7 +1142,7 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 				context.Background(),
 				&csi.NodeExpandVolumeRequest{
 					VolumeId:   vol.GetVolume().GetVolumeId(),
-					VolumePath: sc.TargetPath + "/target",
+					VolumePath: filepath.Join(sc.TargetPath, "target"),
 					CapacityRange: &csi.CapacityRange{
 						RequiredBytes: TestVolumeExpandSize(sc),
 					},
@@ -838,18 +1152,486 @@ var _ = DescribeSanity("Node Service", func(sc *TestContext) {
 		})
 	})
 
+	Describe("ControllerExpandVolume", func() {
+		BeforeEach(func() {
+			if !providesControllerService {
+				skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: ControllerExpandVolume not supported")
+			}
+			if !controllerExpansionSupported {
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_EXPAND_VOLUME), "ControllerExpandVolume not supported")
+			}
+		})
+
+		It(SpecID("controllerexpandvolume/online-published", "should expand a published volume if online expansion is supported"), func() {
+			if !onlineExpansionSupported {
+				Skip("Plugin does not declare online volume expansion")
+			}
+
+			name := UniqueString("sanity-node-online-expand-volume")
+			volumePath := filepath.Join(sc.TargetPath, "target")
+
+			vol := createVolume(name)
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+			Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+			conpubvol := controllerPublishVolume(name, vol, nid)
+
+			// NodeStageVolume
+			_ = nodeStageVolume(name, vol, conpubvol)
+
+			// NodePublishVolume
+			_ = nodePublishVolume(name, vol, conpubvol)
+
+			By("controller expanding the published volume")
+			rsp, err := r.ControllerExpandVolume(
+				context.Background(),
+				&csi.ControllerExpandVolumeRequest{
+					VolumeId: vol.GetVolume().GetVolumeId(),
+					CapacityRange: &csi.CapacityRange{
+						RequiredBytes: TestVolumeExpandSize(sc),
+					},
+					Secrets:          sc.Secrets.ControllerExpandVolumeSecret,
+					VolumeCapability: TestVolumeCapability(sc),
+				},
+			)
+			Expect(err).NotTo(HaveOccurred(), "published volume must be expandable when ONLINE expansion is declared")
+			Expect(rsp).NotTo(BeNil())
+			Expect(rsp.GetCapacityBytes()).To(BeNumerically(">=", TestVolumeExpandSize(sc)))
+
+			if !rsp.GetNodeExpansionRequired() {
+				return
+			}
+			Expect(nodeExpansionSupported).To(BeTrue(), "ControllerExpandVolume requires node expansion, but the node does not support NodeExpandVolume")
+
+			By("expanding the volume on a node")
+			nodeRsp, err := r.NodeExpandVolume(
+				context.Background(),
+				&csi.NodeExpandVolumeRequest{
+					VolumeId:   vol.GetVolume().GetVolumeId(),
+					VolumePath: volumePath,
+					CapacityRange: &csi.CapacityRange{
+						RequiredBytes: TestVolumeExpandSize(sc),
+					},
+					VolumeCapability: TestVolumeCapability(sc),
+				},
+			)
+			Expect(err).NotTo(HaveOccurred(), "while expanding volume on node")
+			Expect(nodeRsp).NotTo(BeNil())
+			Expect(nodeRsp.GetCapacityBytes()).To(Or(BeZero(), BeNumerically(">=", TestVolumeExpandSize(sc))))
+		})
+
+		It(SpecID("controllerexpandvolume/offline-only-published", "should fail for a published volume if only offline expansion is supported"), func() {
+			if !offlineExpansionSupported || onlineExpansionSupported {
+				Skip("Plugin does not declare offline-only volume expansion")
+			}
+
+			name := UniqueString("sanity-node-offline-expand-volume")
+
+			vol := createVolume(name)
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+			Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+			conpubvol := controllerPublishVolume(name, vol, nid)
+
+			// NodeStageVolume
+			_ = nodeStageVolume(name, vol, conpubvol)
+
+			// NodePublishVolume
+			_ = nodePublishVolume(name, vol, conpubvol)
+
+			By("controller expanding the published volume")
+			_, err = r.ControllerExpandVolume(
+				context.Background(),
+				&csi.ControllerExpandVolumeRequest{
+					VolumeId: vol.GetVolume().GetVolumeId(),
+					CapacityRange: &csi.CapacityRange{
+						RequiredBytes: TestVolumeExpandSize(sc),
+					},
+					Secrets:          sc.Secrets.ControllerExpandVolumeSecret,
+					VolumeCapability: TestVolumeCapability(sc),
+				},
+			)
+			Expect(err).To(HaveOccurred(), "published volume must not be expanded when only OFFLINE expansion is declared")
+
+			serverError, ok := status.FromError(err)
+			Expect(ok).To(BeTrue())
+			Expect(serverError.Code()).To(Equal(codes.FailedPrecondition), "unexpected error: %s", serverError.Message())
+		})
+	})
+
+	Describe("CreateSnapshot", func() {
+		BeforeEach(func() {
+			if !providesControllerService {
+				skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateSnapshot not supported")
+			}
+			if !snapshotSupported {
+				skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT), "CreateSnapshot not supported")
+			}
+		})
+
+		It(SpecID("createsnapshot/published-volume", "should create a snapshot of a published volume"), func() {
+			name := UniqueString("sanity-node-snapshot-volume")
+
+			vol := createVolume(name)
+
+			By("getting a node id")
+			nid, err := r.NodeGetInfo(
+				context.Background(),
+				&csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nid).NotTo(BeNil())
+			Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+			conpubvol := controllerPublishVolume(name, vol, nid)
+
+			// NodeStageVolume
+			_ = nodeStageVolume(name, vol, conpubvol)
+
+			// NodePublishVolume
+			_ = nodePublishVolume(name, vol, conpubvol)
+
+			volumePath := filepath.Join(sc.TargetPath, "target")
+			if sc.Config.QuiesceCmd != "" {
+				By("quiescing the volume")
+				err = runQuiesceCmd(sc.Config.QuiesceCmd, volumePath, sc.Config.QuiesceCmdTimeout)
+				Expect(err).NotTo(HaveOccurred())
+				defer func() {
+					By("unquiescing the volume")
+					err := runQuiesceCmd(sc.Config.UnquiesceCmd, volumePath, sc.Config.QuiesceCmdTimeout)
+					Expect(err).NotTo(HaveOccurred())
+				}()
+			}
+
+			By("creating a snapshot of the published volume")
+			snap := r.MustCreateSnapshot(context.Background(), MakeCreateSnapshotReq(sc, UniqueString("sanity-node-snapshot"), vol.GetVolume().GetVolumeId()))
+			verifySnapshotInfo(snap.GetSnapshot())
+		})
+	})
+
 	// CSI spec poses no specific requirements for the cluster/storage setups that a SP MUST support. To perform
 	// meaningful checks the following test assumes that topology-aware provisioning on a single node setup is supported
-	It("should work", func() {
+	It(SpecID("node/lifecycle", "should work"), func() {
 		if !providesControllerService {
-			Skip("Controller Service not provided: CreateVolume not supported")
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
 		}
 		By("runControllerTest")
 		runControllerTest(sc, r, controllerPublishSupported, nodeStageSupported, nodeVolumeStatsSupported, 1)
 	})
-	It("should be idempotent", func() {
+	It(SpecID("node/data-integrity", "should preserve data when republishing a volume"), func() {
+		if !providesControllerService {
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+		}
+		if !dataAccessConfigured(sc.Config) {
+			Skip("Config.WriteData/WriteDataCmd and Config.ReadData/ReadDataCmd not set")
+		}
+
+		name := UniqueString("sanity-node-data-integrity")
+		data := []byte(strings.Repeat(name+"\n", 100))
+		volumePath := filepath.Join(sc.TargetPath, "target")
+
+		vol := createVolume(name)
+
+		By("getting a node id")
+		nid, err := r.NodeGetInfo(
+			context.Background(),
+			&csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nid).NotTo(BeNil())
+		Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+		conpubvol := controllerPublishVolume(name, vol, nid)
+
+		// NodeStageVolume
+		_ = nodeStageVolume(name, vol, conpubvol)
+
+		// NodePublishVolume
+		_ = nodePublishVolume(name, vol, conpubvol)
+
+		By("writing data")
+		err = writeData(sc.Config, volumePath, data)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("unpublishing the volume")
+		_, err = r.NodeUnpublishVolume(
+			context.Background(),
+			&csi.NodeUnpublishVolumeRequest{
+				VolumeId:   vol.GetVolume().GetVolumeId(),
+				TargetPath: volumePath,
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		if nodeStageSupported {
+			By("unstaging the volume")
+			_, err = r.NodeUnstageVolume(
+				context.Background(),
+				&csi.NodeUnstageVolumeRequest{
+					VolumeId:          vol.GetVolume().GetVolumeId(),
+					StagingTargetPath: sc.StagingPath,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		// NodeStageVolume
+		_ = nodeStageVolume(name, vol, conpubvol)
+
+		// NodePublishVolume
+		_ = nodePublishVolume(name, vol, conpubvol)
+
+		By("reading data")
+		readBack, err := readData(sc.Config, volumePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readBack).To(Equal(data), "data read from the republished volume does not match the data that was written")
+	})
+	It(SpecID("node/multiple-target-paths", "should publish a staged volume to multiple target paths"), func() {
+		if !providesControllerService {
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+		}
+		if !nodeStageSupported {
+			skipUnsupported(sc, NodeCapability(csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME), "NodeStageVolume not supported")
+		}
+		if !multiWriterSupported {
+			skipUnsupported(sc, NodeCapability(csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER), "SINGLE_NODE_MULTI_WRITER not supported")
+		}
+
+		name := UniqueString("sanity-node-multiple-targets")
+		volCap := TestVolumeCapabilityWithAccessType(sc, csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER)
+		targetPaths := []string{filepath.Join(sc.TargetPath, "target"), filepath.Join(sc.TargetPath, "target2")}
+
+		By("creating a single node multi writer volume")
+		vol := r.MustCreateVolume(
+			context.Background(),
+			&csi.CreateVolumeRequest{
+				Name:               name,
+				VolumeCapabilities: []*csi.VolumeCapability{volCap},
+				CapacityRange: &csi.CapacityRange{
+					RequiredBytes: TestVolumeSize(sc),
+				},
+				Secrets:    sc.Secrets.CreateVolumeSecret,
+				Parameters: sc.Config.TestVolumeParameters,
+			},
+		)
+		volumeID := vol.GetVolume().GetVolumeId()
+
+		By("getting a node id")
+		nid, err := r.NodeGetInfo(
+			context.Background(),
+			&csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nid).NotTo(BeNil())
+		Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+		var publishContext map[string]string
+		if controllerPublishSupported {
+			By("controller publishing volume")
+			conpubvol := r.MustControllerPublishVolume(
+				context.Background(),
+				&csi.ControllerPublishVolumeRequest{
+					VolumeId:         volumeID,
+					NodeId:           nid.GetNodeId(),
+					VolumeCapability: volCap,
+					VolumeContext:    vol.GetVolume().GetVolumeContext(),
+					Secrets:          sc.Secrets.ControllerPublishVolumeSecret,
+				},
+			)
+			publishContext = conpubvol.GetPublishContext()
+		}
+
+		By("node staging volume")
+		_, err = r.NodeStageVolume(
+			context.Background(),
+			&csi.NodeStageVolumeRequest{
+				VolumeId:          volumeID,
+				VolumeCapability:  volCap,
+				StagingTargetPath: sc.StagingPath,
+				VolumeContext:     vol.GetVolume().GetVolumeContext(),
+				PublishContext:    publishContext,
+				Secrets:           sc.Secrets.NodeStageVolumeSecret,
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, targetPath := range targetPaths {
+			By("publishing the volume to " + targetPath)
+			_, err = r.NodePublishVolume(
+				context.Background(),
+				&csi.NodePublishVolumeRequest{
+					VolumeId:          volumeID,
+					TargetPath:        targetPath,
+					StagingTargetPath: sc.StagingPath,
+					VolumeCapability:  volCap,
+					VolumeContext:     vol.GetVolume().GetVolumeContext(),
+					PublishContext:    publishContext,
+					Secrets:           sc.Secrets.NodePublishVolumeSecret,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		for _, targetPath := range targetPaths {
+			By("unpublishing the volume from " + targetPath)
+			_, err = r.NodeUnpublishVolume(
+				context.Background(),
+				&csi.NodeUnpublishVolumeRequest{
+					VolumeId:   volumeID,
+					TargetPath: targetPath,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("unstaging the volume")
+		_, err = r.NodeUnstageVolume(
+			context.Background(),
+			&csi.NodeUnstageVolumeRequest{
+				VolumeId:          volumeID,
+				StagingTargetPath: sc.StagingPath,
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It(SpecID("node/unpublish-sibling", "should not touch a sibling target path when unpublishing a volume"), func() {
+		if !providesControllerService {
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+		}
+
+		name := UniqueString("sanity-node-unpublish-sibling")
+		siblingName := name + "-sibling"
+		volumePath := filepath.Join(sc.TargetPath, "target")
+		siblingPath := filepath.Join(sc.TargetPath, "target2")
+
+		vol := createVolume(name)
+		siblingVol := createVolume(siblingName)
+
+		By("getting a node id")
+		nid, err := r.NodeGetInfo(
+			context.Background(),
+			&csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nid).NotTo(BeNil())
+		Expect(nid.GetNodeId()).NotTo(BeEmpty())
+
+		// Each volume needs its own staging path.
+		siblingStagingPath := ""
+		if nodeStageSupported {
+			By("creating a second staging directory")
+			siblingStagingPath, err = createMountTargetLocation(sc.Config.StagingPath+"-sibling", sc.Config.CreateStagingPathCmd, sc.Config.CreateStagingDir, sc.Config.CreatePathCmdTimeout)
+			Expect(err).NotTo(HaveOccurred(), "failed to create staging directory %s", siblingStagingPath)
+			r.registerStagingDir(siblingStagingPath)
+		}
+
+		conpubvol := controllerPublishVolume(name, vol, nid)
+		_ = nodeStageVolume(name, vol, conpubvol)
+		_ = nodePublishVolume(name, vol, conpubvol)
+
+		By("publishing the sibling volume")
+		siblingID := siblingVol.GetVolume().GetVolumeId()
+		siblingCap := TestVolumeCapability(sc)
+		siblingConpubvol := controllerPublishVolume(siblingName, siblingVol, nid)
+		if nodeStageSupported {
+			_, err = r.NodeStageVolume(
+				context.Background(),
+				&csi.NodeStageVolumeRequest{
+					VolumeId:          siblingID,
+					VolumeCapability:  siblingCap,
+					StagingTargetPath: siblingStagingPath,
+					VolumeContext:     siblingVol.GetVolume().GetVolumeContext(),
+					PublishContext:    siblingConpubvol.GetPublishContext(),
+					Secrets:           sc.Secrets.NodeStageVolumeSecret,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		_, err = r.NodePublishVolume(
+			context.Background(),
+			&csi.NodePublishVolumeRequest{
+				VolumeId:          siblingID,
+				TargetPath:        siblingPath,
+				StagingTargetPath: siblingStagingPath,
+				VolumeCapability:  siblingCap,
+				VolumeContext:     siblingVol.GetVolume().GetVolumeContext(),
+				PublishContext:    siblingConpubvol.GetPublishContext(),
+				Secrets:           sc.Secrets.NodePublishVolumeSecret,
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		var data []byte
+		if dataAccessConfigured(sc.Config) {
+			By("writing data into the sibling volume")
+			data = []byte(strings.Repeat(siblingName+"\n", 100))
+			err = writeData(sc.Config, siblingPath, data)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("unpublishing the first volume")
+		_, err = r.NodeUnpublishVolume(
+			context.Background(),
+			&csi.NodeUnpublishVolumeRequest{
+				VolumeId:   vol.GetVolume().GetVolumeId(),
+				TargetPath: volumePath,
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("checking that the sibling target path still exists")
+		pa, err := CheckPath(siblingPath, sc.Config)
+		Expect(err).NotTo(HaveOccurred(), "checking path %q", siblingPath)
+		Expect(pa).NotTo(Equal(PathIsNotFound), "path %q of the sibling volume was removed by NodeUnpublishVolume for %q", siblingPath, volumePath)
+
+		if data != nil {
+			By("reading data from the sibling volume")
+			readBack, err := readData(sc.Config, siblingPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readBack).To(Equal(data), "data in the sibling volume changed when unpublishing %q", volumePath)
+		}
+
+		By("unpublishing the sibling volume")
+		_, err = r.NodeUnpublishVolume(
+			context.Background(),
+			&csi.NodeUnpublishVolumeRequest{
+				VolumeId:   siblingID,
+				TargetPath: siblingPath,
+			},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		if nodeStageSupported {
+			By("unstaging the volumes")
+			_, err = r.NodeUnstageVolume(
+				context.Background(),
+				&csi.NodeUnstageVolumeRequest{
+					VolumeId:          vol.GetVolume().GetVolumeId(),
+					StagingTargetPath: sc.StagingPath,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = r.NodeUnstageVolume(
+				context.Background(),
+				&csi.NodeUnstageVolumeRequest{
+					VolumeId:          siblingID,
+					StagingTargetPath: siblingStagingPath,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+	It(SpecID("node/lifecycle-idempotent", "should be idempotent"), func() {
 		if !providesControllerService {
-			Skip("Controller Service not provided: CreateVolume not supported")
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
 		}
 		if sc.Config.IdempotentCount <= 0 {
 			Skip("Config.IdempotentCount is zero or negative, skip tests")
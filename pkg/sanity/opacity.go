@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// similarIDs returns IDs which look like the given one but differ from
+// it. Variants which would be identical, like a case-flipped ID
+// without letters, are omitted.
+func similarIDs(id string) map[string]string {
+	variants := map[string]string{
+		"trailing whitespace": id + " ",
+		"leading whitespace":  " " + id,
+	}
+	flipped := strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, id)
+	if flipped != id {
+		variants["flipped case"] = flipped
+	}
+	return variants
+}
+
+// expectUnknownVolumeID checks that a call with a similar volume ID
+// failed with NotFound. Rejecting the ID as malformed with
+// InvalidArgument is also fine, accepting it is not.
+func expectUnknownVolumeID(err error, method, variant, id string) {
+	ExpectWithOffset(1, err).To(HaveOccurred(), "%s accepted volume ID %q (%s) instead of treating it as a different volume", method, id, variant)
+	serverError, ok := status.FromError(err)
+	ExpectWithOffset(1, ok).To(BeTrue())
+	ExpectWithOffset(1, serverError.Code()).To(Or(Equal(codes.NotFound), Equal(codes.InvalidArgument)),
+		"unexpected error for %s with volume ID %q (%s): %s", method, id, variant, serverError.Message())
+}
+
+var _ = DescribeSanity("Volume ID Opacity", func(sc *TestContext) {
+	var r *Resources
+
+	BeforeEach(func() {
+		r = &Resources{
+			Context:          sc,
+			ControllerClient: csi.NewControllerClient(sc.ControllerConn),
+			NodeClient:       csi.NewNodeClient(sc.Conn),
+		}
+
+		if !isPluginCapabilitySupported(csi.NewIdentityClient(sc.Conn), csi.PluginCapability_Service_CONTROLLER_SERVICE) {
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+		}
+		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME) {
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME), "CreateVolume not supported")
+		}
+	})
+
+	AfterEach(func() {
+		r.Cleanup()
+	})
+
+	It(SpecID("volumeid/opacity", "should not match volume IDs which only look similar"), func() {
+		By("creating a volume")
+		vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-volume-id-opacity")))
+		volID := vol.GetVolume().GetVolumeId()
+		volCap := TestVolumeCapability(sc)
+
+		var nodeID string
+		if isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME) {
+			nid, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			nodeID = nid.GetNodeId()
+		}
+		stageSupported := isNodeCapabilitySupported(r, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME)
+
+		for variant, id := range similarIDs(volID) {
+			By(fmt.Sprintf("validating volume capabilities with %s", variant))
+			_, err := r.ValidateVolumeCapabilities(
+				context.Background(),
+				&csi.ValidateVolumeCapabilitiesRequest{
+					VolumeId:           id,
+					VolumeCapabilities: []*csi.VolumeCapability{volCap},
+					Secrets:            sc.Secrets.ControllerValidateVolumeCapabilitiesSecret,
+				},
+			)
+			expectUnknownVolumeID(err, "ValidateVolumeCapabilities", variant, id)
+
+			if nodeID != "" {
+				By(fmt.Sprintf("controller publishing with %s", variant))
+				_, err := r.ControllerPublishVolume(context.Background(), MakeControllerPublishVolumeReq(sc, id, nodeID))
+				expectUnknownVolumeID(err, "ControllerPublishVolume", variant, id)
+			}
+
+			if stageSupported {
+				By(fmt.Sprintf("node staging with %s", variant))
+				_, err := r.NodeStageVolume(
+					context.Background(),
+					&csi.NodeStageVolumeRequest{
+						VolumeId:          id,
+						VolumeCapability:  volCap,
+						StagingTargetPath: sc.StagingPath,
+						VolumeContext:     vol.GetVolume().GetVolumeContext(),
+						Secrets:           sc.Secrets.NodeStageVolumeSecret,
+					},
+				)
+				expectUnknownVolumeID(err, "NodeStageVolume", variant, id)
+			}
+		}
+	})
+})
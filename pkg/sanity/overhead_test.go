@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/onsi/ginkgo"
+	"google.golang.org/grpc"
+)
+
+// overheadBudgetEnv is the environment variable with the maximum time
+// that the interceptors of the sanity package may add to a single
+// gRPC call, for example 20us. It should be much lower than the
+// latency of any real driver, so the RPC statistics reported in the
+// Results are not skewed by the harness. Wall-clock limits are
+// unreliable on shared machines, so the check only runs where the
+// variable is set on purpose, like in "make test-overhead", which is
+// part of "make test".
+const overheadBudgetEnv = "SANITY_OVERHEAD_BUDGET"
+
+// overheadConfigs are the configurations for which the overhead gets
+// measured. Only those marked as budgeted are always active, the
+// others serialize every message and are meant for debugging.
+var overheadConfigs = []struct {
+	name     string
+	budgeted bool
+	modify   func(b *testing.B, config *TestConfig)
+}{
+	{"default", true, func(b *testing.B, config *TestConfig) {}},
+	{"logging", true, func(b *testing.B, config *TestConfig) {
+		config.Logger = NewWriterLogger(ioutil.Discard, 10)
+	}},
+	{"recording", false, func(b *testing.B, config *TestConfig) {
+		config.RecordTrafficFile = filepath.Join(b.TempDir(), "traffic.jsonl")
+	}},
+	{"dumping", false, func(b *testing.B, config *TestConfig) {
+		config.DumpMessages = true
+	}},
+}
+
+// invokeWithInterceptors calls the interceptors like a
+// grpc.ClientConn would, with an invoker that returns immediately.
+func invokeWithInterceptors(interceptors []grpc.UnaryClientInterceptor, method string, req, reply interface{}) error {
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return interceptor(ctx, method, req, reply, cc, next, opts...)
+		}
+	}
+	return invoker(context.Background(), method, req, reply, nil)
+}
+
+// newOverheadContext returns a TestContext with the modified default
+// configuration. Dumped messages are discarded.
+func newOverheadContext(b *testing.B, modify func(b *testing.B, config *TestConfig)) *TestContext {
+	config := NewTestConfig()
+	config.MaxTotalProvisionedBytes = 1 << 62
+	modify(b, &config)
+	writer := ginkgo.GinkgoWriter
+	ginkgo.GinkgoWriter = ioutil.Discard
+	b.Cleanup(func() {
+		ginkgo.GinkgoWriter = writer
+	})
+	sc := NewTestContext(&config)
+	b.Cleanup(sc.recorder.close)
+	return sc
+}
+
+func benchmarkCreateVolume(b *testing.B, modify func(b *testing.B, config *TestConfig)) {
+	sc := newOverheadContext(b, modify)
+	interceptors := sc.interceptors()
+	req := &csi.CreateVolumeRequest{
+		Name:          "benchmark",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 1024},
+	}
+
+	// Most calls are repeated CreateVolume calls for existing
+	// volumes, like in the idempotency tests.
+	replies := make([]*csi.CreateVolumeResponse, 1000)
+	for i := range replies {
+		replies[i] = &csi.CreateVolumeResponse{
+			Volume: &csi.Volume{VolumeId: fmt.Sprintf("volume-%d", i), CapacityBytes: 1024},
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := invokeWithInterceptors(interceptors, "/csi.v1.Controller/CreateVolume", req, replies[i%len(replies)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkNodeGetInfo(b *testing.B, modify func(b *testing.B, config *TestConfig)) {
+	sc := newOverheadContext(b, modify)
+	interceptors := sc.interceptors()
+	req := &csi.NodeGetInfoRequest{}
+	reply := &csi.NodeGetInfoResponse{NodeId: "node"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := invokeWithInterceptors(interceptors, "/csi.v1.Node/NodeGetInfo", req, reply); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+var overheadBenchmarks = map[string]func(*testing.B, func(*testing.B, *TestConfig)){
+	"CreateVolume": benchmarkCreateVolume,
+	"NodeGetInfo":  benchmarkNodeGetInfo,
+}
+
+func BenchmarkInterceptorsCreateVolume(b *testing.B) {
+	for _, config := range overheadConfigs {
+		config := config
+		b.Run(config.name, func(b *testing.B) {
+			benchmarkCreateVolume(b, config.modify)
+		})
+	}
+}
+
+func BenchmarkInterceptorsNodeGetInfo(b *testing.B) {
+	for _, config := range overheadConfigs {
+		config := config
+		b.Run(config.name, func(b *testing.B) {
+			benchmarkNodeGetInfo(b, config.modify)
+		})
+	}
+}
+
+// TestInterceptorOverhead fails when the interceptors of the budgeted
+// configurations exceed the budget from SANITY_OVERHEAD_BUDGET, so
+// that new cross-cutting features do not silently slow down every
+// call. The other configurations are only reported.
+func TestInterceptorOverhead(t *testing.T) {
+	value := os.Getenv(overheadBudgetEnv)
+	if value == "" {
+		t.Skipf("%s not set", overheadBudgetEnv)
+	}
+	budget, err := time.ParseDuration(value)
+	if err != nil {
+		t.Fatalf("%s: %v", overheadBudgetEnv, err)
+	}
+
+	for name, benchmark := range overheadBenchmarks {
+		for _, config := range overheadConfigs {
+			config, benchmark := config, benchmark
+			result := testing.Benchmark(func(b *testing.B) {
+				benchmark(b, config.modify)
+			})
+			perCall := time.Duration(result.NsPerOp())
+			t.Logf("%s/%s: %s per call, %d allocs per call", name, config.name, perCall, result.AllocsPerOp())
+			if config.budgeted && perCall > budget {
+				t.Errorf("%s/%s: interceptors take %s per call, budget is %s", name, config.name, perCall, budget)
+			}
+		}
+	}
+}
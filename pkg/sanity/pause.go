@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// pausePollInterval is how often waitForResume checks for the
+// resume file.
+const pausePollInterval = time.Second
+
+// pauseBeforeArea is called before each test of the given area. When
+// Config.PauseBetweenAreas is set and the area differs from the one
+// of the previous test, it waits for the resume file, then drops the
+// connections and the cached fundamental checks so that the next
+// test talks to the (possibly upgraded) driver. Before the first
+// pause it creates a volume which is kept until Finalize, after each
+// pause it checks that the driver still has that volume.
+func (sc *TestContext) pauseBeforeArea(area string) {
+	previous := sc.currentArea
+	sc.currentArea = area
+	if sc.Config.PauseBetweenAreas == "" || previous == "" || previous == area {
+		return
+	}
+
+	if sc.paused == nil {
+		sc.keepVolumeForPause()
+	}
+
+	By(fmt.Sprintf("pausing after %q, create %s to continue with %q", previous, sc.Config.PauseBetweenAreas, area))
+	err := waitForResume(sc.Config.PauseBetweenAreas, sc.Config.PauseTimeout, sc.Config.logger())
+	Expect(err).NotTo(HaveOccurred(), "waiting for %s", sc.Config.PauseBetweenAreas)
+
+	sc.Close()
+	sc.fundamentalsChecked = false
+	sc.fundamentalsErr = nil
+
+	if sc.pausedVolume != nil {
+		sc.verifyPausedVolume()
+	}
+}
+
+// keepVolumeForPause creates the volume which is kept across the
+// pauses, if the driver can create volumes.
+func (sc *TestContext) keepVolumeForPause() {
+	sc.paused = &Resources{Context: sc}
+	if sc.Config.DryRun || sc.ControllerConn == nil {
+		return
+	}
+	supported, err := sc.hasCapability(context.Background(), ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME))
+	Expect(err).NotTo(HaveOccurred(), "checking whether volumes can be kept across the pause")
+	if !supported {
+		return
+	}
+
+	By("creating a volume which is kept across the pauses")
+	sc.paused.ControllerClient = csi.NewControllerClient(sc.ControllerConn)
+	sc.paused.NodeClient = csi.NewNodeClient(sc.Conn)
+	vol := sc.paused.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-pause")))
+	sc.pausedVolume = vol.GetVolume()
+}
+
+// verifyPausedVolume reconnects and checks that the driver still has
+// the volume created before the first pause.
+func (sc *TestContext) verifyPausedVolume() {
+	By(fmt.Sprintf("checking that volume %s still exists after the pause", sc.pausedVolume.GetVolumeId()))
+	err := sc.Connect(context.Background())
+	Expect(err).NotTo(HaveOccurred())
+	sc.paused.ControllerClient = csi.NewControllerClient(sc.ControllerConn)
+	sc.paused.NodeClient = csi.NewNodeClient(sc.Conn)
+
+	rsp, err := sc.paused.ValidateVolumeCapabilities(
+		context.Background(),
+		&csi.ValidateVolumeCapabilitiesRequest{
+			VolumeId:           sc.pausedVolume.GetVolumeId(),
+			VolumeContext:      sc.pausedVolume.GetVolumeContext(),
+			VolumeCapabilities: testVolumeCapabilities(sc),
+			Parameters:         sc.Config.TestVolumeParameters,
+			Secrets:            sc.Secrets.ControllerValidateVolumeCapabilitiesSecret,
+		},
+	)
+	Expect(err).NotTo(HaveOccurred(), "volume %s created before the pause", sc.pausedVolume.GetVolumeId())
+	Expect(rsp.GetConfirmed()).NotTo(BeNil(), "capabilities of volume %s created before the pause are no longer confirmed", sc.pausedVolume.GetVolumeId())
+}
+
+// waitForResume blocks until the file exists and then removes it, so
+// that the same file can be used for the next pause. It gives up
+// after the timeout unless that is zero.
+func waitForResume(path string, timeout time.Duration, logger Logger) error {
+	logger.Info(0, "paused, create the file to continue", "path", path, "timeout", timeout)
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := os.Stat(path)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			return fmt.Errorf("%s was not created within %s", path, timeout)
+		}
+		time.Sleep(pausePollInterval)
+	}
+	logger.Info(0, "resuming, found the file", "path", path)
+	return os.Remove(path)
+}
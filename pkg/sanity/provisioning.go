@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// provisioningTracker sums up the capacity of all volumes which were
+// created and not deleted yet. It fails the test instead of creating
+// more volumes when that would exceed TestConfig.MaxTotalProvisionedBytes.
+// All methods can be called concurrently.
+type provisioningTracker struct {
+	config *TestConfig
+
+	mutex   sync.Mutex
+	volumes map[string]int64
+	total   int64
+	peak    int64
+}
+
+func newProvisioningTracker(config *TestConfig) *provisioningTracker {
+	return &provisioningTracker{
+		config:  config,
+		volumes: map[string]int64{},
+	}
+}
+
+// requestedBytes returns the capacity requested for a new volume,
+// zero if the driver may choose it.
+func requestedBytes(req *csi.CreateVolumeRequest) int64 {
+	if required := req.GetCapacityRange().GetRequiredBytes(); required > 0 {
+		return required
+	}
+	return req.GetCapacityRange().GetLimitBytes()
+}
+
+// interceptor checks the limit before CreateVolume is sent to the
+// driver and updates the total for created and deleted volumes.
+func (pt *provisioningTracker) interceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if create, ok := req.(*csi.CreateVolumeRequest); ok {
+		if err := pt.checkLimit(requestedBytes(create)); err != nil {
+			// Returning an error would look like a driver
+			// error to tests which expect CreateVolume to fail.
+			Fail(err.Error())
+		}
+	}
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		return err
+	}
+
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	switch r := reply.(type) {
+	case *csi.CreateVolumeResponse:
+		id := r.GetVolume().GetVolumeId()
+		if _, ok := pt.volumes[id]; ok || id == "" {
+			// Repeated call for an existing volume.
+			break
+		}
+		size := r.GetVolume().GetCapacityBytes()
+		if requested := requestedBytes(req.(*csi.CreateVolumeRequest)); requested > size {
+			size = requested
+		}
+		pt.volumes[id] = size
+		pt.total += size
+		if pt.total > pt.peak {
+			pt.peak = pt.total
+		}
+	case *csi.DeleteVolumeResponse:
+		id := req.(*csi.DeleteVolumeRequest).GetVolumeId()
+		pt.total -= pt.volumes[id]
+		delete(pt.volumes, id)
+	}
+	return nil
+}
+
+func (pt *provisioningTracker) checkLimit(size int64) error {
+	max := pt.config.MaxTotalProvisionedBytes
+	if max <= 0 {
+		return nil
+	}
+
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	if pt.total+size > max {
+		return fmt.Errorf("creating a volume of %d bytes would exceed Config.MaxTotalProvisionedBytes (%d bytes already provisioned, limit %d bytes)",
+			size, pt.total, max)
+	}
+	return nil
+}
+
+// provisioned returns the current and the highest total.
+func (pt *provisioningTracker) provisioned() (current, peak int64) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	return pt.total, pt.peak
+}
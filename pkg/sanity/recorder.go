@@ -0,0 +1,209 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// redactedSecret replaces the values of secrets in recorded traffic.
+const redactedSecret = "***stripped***"
+
+// TrafficRecord is one line in TestConfig.RecordTrafficFile.
+type TrafficRecord struct {
+	Time time.Time `json:"time"`
+	// Spec is the name of the spec which made the call.
+	Spec     string          `json:"spec,omitempty"`
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// Code and Error are the gRPC status of a failed call.
+	Code            string  `json:"code,omitempty"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// trafficRecorder writes TrafficRecords to the file, which is
+// created by the first call, and keeps the most recent ones for
+// failure artifacts.
+type trafficRecorder struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	failed  bool
+	recent  []TrafficRecord
+}
+
+// recordInterceptor appends each call to TestConfig.RecordTrafficFile
+// and keeps the most recent calls for TestConfig.ArtifactsDir.
+func (sc *TestContext) recordInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if sc.Config.RecordTrafficFile == "" && sc.Config.ArtifactsDir == "" {
+		return err
+	}
+
+	record := TrafficRecord{
+		Time:            start,
+		Spec:            sc.results.runningSpec(),
+		Method:          method,
+		Request:         marshalRedacted(req),
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	if err != nil {
+		record.Code = status.Code(err).String()
+		record.Error = status.Convert(err).Message()
+	} else {
+		record.Response = marshalRedacted(reply)
+	}
+	if sc.Config.ArtifactsDir != "" {
+		sc.recorder.remember(record, sc.Config.ArtifactRPCs)
+	}
+	if sc.Config.RecordTrafficFile != "" {
+		sc.recorder.write(sc.Config.RecordTrafficFile, &record, sc.Config.logger())
+	}
+	return err
+}
+
+// remember keeps the record among the most recent ones.
+func (tr *trafficRecorder) remember(record TrafficRecord, limit int) {
+	if limit <= 0 {
+		limit = defaultArtifactRPCs
+	}
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.recent = append(tr.recent, record)
+	if len(tr.recent) > limit {
+		tr.recent = append([]TrafficRecord(nil), tr.recent[len(tr.recent)-limit:]...)
+	}
+}
+
+// recentRecords returns a copy of the most recent records.
+func (tr *trafficRecorder) recentRecords() []TrafficRecord {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return append([]TrafficRecord(nil), tr.recent...)
+}
+
+func (tr *trafficRecorder) write(path string, record *TrafficRecord, logger Logger) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if tr.failed {
+		return
+	}
+	if tr.file == nil {
+		file, err := os.Create(path)
+		if err != nil {
+			// Only reported once, the tests can still run.
+			logger.Error(err, "recording traffic failed")
+			tr.failed = true
+			return
+		}
+		tr.file = file
+		tr.encoder = json.NewEncoder(file)
+	}
+	if err := tr.encoder.Encode(record); err != nil {
+		logger.Error(err, "recording traffic failed")
+		tr.failed = true
+	}
+}
+
+func (tr *trafficRecorder) close() {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if tr.file != nil {
+		tr.file.Close()
+		tr.file = nil
+	}
+}
+
+// redacted returns a copy of the message with the values of all
+// fields that the CSI spec marks as secret replaced, nil if it is not
+// a protobuf message.
+func redacted(m interface{}) proto.Message {
+	// The CSI messages are generated with the old protobuf API.
+	v1, ok := m.(protov1.Message)
+	if !ok {
+		return nil
+	}
+	msg := proto.Clone(protov1.MessageV2(v1))
+	redactSecrets(msg.ProtoReflect())
+	return msg
+}
+
+// marshalRedacted returns the redacted message as JSON.
+func marshalRedacted(m interface{}) json.RawMessage {
+	msg := redacted(m)
+	if msg == nil {
+		return nil
+	}
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return data
+}
+
+func redactSecrets(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && proto.GetExtension(opts, csi.E_CsiSecret).(bool) {
+			switch {
+			case fd.IsMap():
+				var keys []protoreflect.MapKey
+				v.Map().Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+					keys = append(keys, key)
+					return true
+				})
+				for _, key := range keys {
+					v.Map().Set(key, protoreflect.ValueOfString(redactedSecret))
+				}
+			case fd.Kind() == protoreflect.StringKind && !fd.IsList():
+				m.Set(fd, protoreflect.ValueOfString(redactedSecret))
+			default:
+				m.Clear(fd)
+			}
+			return true
+		}
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			for i := 0; i < v.List().Len(); i++ {
+				redactSecrets(v.List().Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+				redactSecrets(value.Message())
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			redactSecrets(v.Message())
+		}
+		return true
+	})
+}
@@ -19,6 +19,7 @@ package sanity
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"sync"
 
 	"google.golang.org/grpc"
@@ -29,8 +30,6 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-
-	"k8s.io/klog/v2"
 )
 
 // resourceInfo represents a resource (i.e., a volume or a snapshot).
@@ -49,8 +48,25 @@ type volumeInfo struct {
 // snapshotInfo keeps track of the information needed to delete a snapshot.
 type snapshotInfo struct{}
 
+// stagedVolumeInfo keeps track of the information needed to unstage a volume.
+type stagedVolumeInfo struct {
+	StagingTargetPath string
+}
+
+// stagingDirInfo keeps track of an additional staging directory which has to
+// be removed once the volumes staged in it are unstaged.
+type stagingDirInfo struct{}
+
+// publishedVolumeInfo keeps track of the information needed to unpublish a
+// volume on a node.
+type publishedVolumeInfo struct {
+	TargetPath string
+}
+
 // Resources keeps track of resources, in particular volumes and snapshots, that
-// need to be freed when testing is done. It implements both ControllerClient
+// need to be freed when testing is done. Volumes that were staged or published
+// on a node are also tracked, so that they get unpublished and unstaged
+// before deletion, even when a test fails halfway through. It implements both ControllerClient
 // and NodeClient and should be used as the only interaction point to either
 // APIs. That way, Resources can ensure that resources are marked for cleanup as
 // necessary.
@@ -71,6 +87,14 @@ type Resources struct {
 	managedResourceInfos []resourceInfo
 }
 
+// logger returns the Logger of the context, the default without one.
+func (cl *Resources) logger() Logger {
+	if cl.Context == nil {
+		return klogLogger{}
+	}
+	return cl.Context.Config.logger()
+}
+
 // ControllerClient interface wrappers
 
 // CreateVolume proxies to a Controller service implementation and registers the
@@ -103,6 +127,48 @@ func (cl *Resources) DeleteSnapshot(ctx context.Context, in *csi.DeleteSnapshotR
 	return cl.deleteSnapshot(ctx, 2, in)
 }
 
+// NodeClient interface wrappers
+
+// NodeStageVolume proxies to a Node service implementation and registers the
+// staged volume for cleanup.
+func (cl *Resources) NodeStageVolume(ctx context.Context, in *csi.NodeStageVolumeRequest, _ ...grpc.CallOption) (*csi.NodeStageVolumeResponse, error) {
+	rsp, err := cl.NodeClient.NodeStageVolume(ctx, in)
+	if err == nil && in.VolumeId != "" && in.StagingTargetPath != "" {
+		cl.registerNodeResource(2, in.VolumeId, stagedVolumeInfo{StagingTargetPath: in.StagingTargetPath})
+	}
+	return rsp, err
+}
+
+// NodeUnstageVolume proxies to a Node service implementation and unregisters
+// the staged volume from cleanup.
+func (cl *Resources) NodeUnstageVolume(ctx context.Context, in *csi.NodeUnstageVolumeRequest, _ ...grpc.CallOption) (*csi.NodeUnstageVolumeResponse, error) {
+	rsp, err := cl.NodeClient.NodeUnstageVolume(ctx, in)
+	if err == nil && in.VolumeId != "" {
+		cl.unregisterNodeResource(in.VolumeId, stagedVolumeInfo{StagingTargetPath: in.StagingTargetPath})
+	}
+	return rsp, err
+}
+
+// NodePublishVolume proxies to a Node service implementation and registers
+// the published volume for cleanup.
+func (cl *Resources) NodePublishVolume(ctx context.Context, in *csi.NodePublishVolumeRequest, _ ...grpc.CallOption) (*csi.NodePublishVolumeResponse, error) {
+	rsp, err := cl.NodeClient.NodePublishVolume(ctx, in)
+	if err == nil && in.VolumeId != "" && in.TargetPath != "" {
+		cl.registerNodeResource(2, in.VolumeId, publishedVolumeInfo{TargetPath: in.TargetPath})
+	}
+	return rsp, err
+}
+
+// NodeUnpublishVolume proxies to a Node service implementation and
+// unregisters the published volume from cleanup.
+func (cl *Resources) NodeUnpublishVolume(ctx context.Context, in *csi.NodeUnpublishVolumeRequest, _ ...grpc.CallOption) (*csi.NodeUnpublishVolumeResponse, error) {
+	rsp, err := cl.NodeClient.NodeUnpublishVolume(ctx, in)
+	if err == nil && in.VolumeId != "" {
+		cl.unregisterNodeResource(in.VolumeId, publishedVolumeInfo{TargetPath: in.TargetPath})
+	}
+	return rsp, err
+}
+
 // MustCreateVolume is like CreateVolume but asserts that the volume was
 // successfully created.
 func (cl *Resources) MustCreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) *csi.CreateVolumeResponse {
@@ -119,7 +185,7 @@ func (cl *Resources) mustCreateVolumeWithOffset(ctx context.Context, offset int,
 }
 
 func (cl *Resources) createVolume(ctx context.Context, offset int, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
-	vol, err := cl.ControllerClient.CreateVolume(ctx, req)
+	vol, err := cl.ControllerClient.CreateVolume(ctx, cl.Context.Config.tagVolumeRequest(req))
 	if err == nil && vol != nil && vol.GetVolume().GetVolumeId() != "" {
 		cl.registerVolume(offset+1, vol.GetVolume().GetVolumeId(), volumeInfo{})
 	}
@@ -157,7 +223,8 @@ func (cl *Resources) registerVolume(offset int, id string, info volumeInfo) {
 	ExpectWithOffset(offset, info).NotTo(BeNil(), "volume info is nil")
 	cl.mutex.Lock()
 	defer cl.mutex.Unlock()
-	klog.V(4).Infof("registering volume ID %s", id)
+	cl.logger().Info(4, "registering volume", "volumeID", id)
+	cl.Context.track(cl)
 	cl.managedResourceInfos = append(cl.managedResourceInfos, resourceInfo{
 		id:   id,
 		data: info,
@@ -188,7 +255,7 @@ func (cl *Resources) MustCreateSnapshotFromVolumeRequest(ctx context.Context, re
 }
 
 func (cl *Resources) createSnapshot(ctx context.Context, offset int, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
-	snap, err := cl.ControllerClient.CreateSnapshot(ctx, req)
+	snap, err := cl.ControllerClient.CreateSnapshot(ctx, cl.Context.Config.tagSnapshotRequest(req))
 	if err == nil && snap.GetSnapshot().GetSnapshotId() != "" {
 		cl.registerSnapshot(offset+1, snap.Snapshot.SnapshotId)
 	}
@@ -211,13 +278,61 @@ func (cl *Resources) registerSnapshot(offset int, id string) {
 
 func (cl *Resources) registerSnapshotNoLock(offset int, id string) {
 	ExpectWithOffset(offset, id).NotTo(BeEmpty(), "ID for register snapshot is missing")
-	klog.V(4).Infof("registering snapshot ID %s", id)
+	cl.logger().Info(4, "registering snapshot", "snapshotID", id)
+	cl.Context.track(cl)
 	cl.managedResourceInfos = append(cl.managedResourceInfos, resourceInfo{
 		id:   id,
 		data: snapshotInfo{},
 	})
 }
 
+// registerNodeResource adds an entry for a staged or published volume unless
+// the same entry already exists, which happens when the call is repeated to
+// test idempotency.
+func (cl *Resources) registerNodeResource(offset int, id string, info interface{}) {
+	ExpectWithOffset(offset, id).NotTo(BeEmpty(), "volume ID is empty")
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	for _, resInfo := range cl.managedResourceInfos {
+		if resInfo.id == id && resInfo.data == info {
+			return
+		}
+	}
+	cl.logger().Info(4, "registering volume info", "type", fmt.Sprintf("%T", info), "volumeID", id)
+	cl.Context.track(cl)
+	cl.managedResourceInfos = append(cl.managedResourceInfos, resourceInfo{
+		id:   id,
+		data: info,
+	})
+}
+
+// registerStagingDir adds an entry for a staging directory created by a test
+// in addition to the configured one. Because cleanup happens in LIFO order,
+// the directory gets removed after the volumes staged in it were unstaged.
+func (cl *Resources) registerStagingDir(path string) {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	cl.logger().Info(4, "registering staging directory", "path", path)
+	cl.Context.track(cl)
+	cl.managedResourceInfos = append(cl.managedResourceInfos, resourceInfo{
+		id:   path,
+		data: stagingDirInfo{},
+	})
+}
+
+// unregisterNodeResource removes the entry for a staged or published volume.
+func (cl *Resources) unregisterNodeResource(id string, info interface{}) {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	for i, resInfo := range cl.managedResourceInfos {
+		if resInfo.id == id && resInfo.data == info {
+			cl.logger().Info(4, "unregistering volume info", "type", fmt.Sprintf("%T", info), "volumeID", id)
+			cl.managedResourceInfos = append(cl.managedResourceInfos[:i], cl.managedResourceInfos[i+1:]...)
+			return
+		}
+	}
+}
+
 func (cl *Resources) unregisterResource(offset int, id string) {
 	cl.mutex.Lock()
 	defer cl.mutex.Unlock()
@@ -226,10 +341,15 @@ func (cl *Resources) unregisterResource(offset int, id string) {
 
 func (cl *Resources) unregisterResourceNoLock(offset int, id string) {
 	ExpectWithOffset(offset, id).NotTo(BeEmpty(), "ID for unregister resource is missing")
-	// Find resource info with the given ID and remove it.
+	// Find resource info with the given ID and remove it. Entries for
+	// staged and published volumes are removed separately.
 	for i, resInfo := range cl.managedResourceInfos {
+		switch resInfo.data.(type) {
+		case stagedVolumeInfo, publishedVolumeInfo, stagingDirInfo:
+			continue
+		}
 		if resInfo.id == id {
-			klog.V(4).Infof("unregistering resource ID %s", id)
+			cl.logger().Info(4, "unregistering resource", "id", id)
 			cl.managedResourceInfos = append(cl.managedResourceInfos[:i], cl.managedResourceInfos[i+1:]...)
 			return
 		}
@@ -238,7 +358,7 @@ func (cl *Resources) unregisterResourceNoLock(offset int, id string) {
 
 // Cleanup calls unpublish methods as needed and deletes all managed resources.
 func (cl *Resources) Cleanup() {
-	klog.V(4).Info("cleaning up all registered resources")
+	cl.logger().Info(4, "cleaning up all registered resources")
 	cl.mutex.Lock()
 	defer cl.mutex.Unlock()
 	ctx := context.Background()
@@ -253,6 +373,12 @@ func (cl *Resources) Cleanup() {
 			errs = append(errs, cl.cleanupVolume(ctx, 2, id, resType)...)
 		case snapshotInfo:
 			errs = append(errs, cl.cleanupSnapshot(ctx, 2, id)...)
+		case stagedVolumeInfo:
+			errs = append(errs, cl.cleanupStagedVolume(ctx, id, resType)...)
+		case publishedVolumeInfo:
+			errs = append(errs, cl.cleanupPublishedVolume(ctx, id, resType)...)
+		case stagingDirInfo:
+			errs = append(errs, cl.cleanupStagingDir(id)...)
 		default:
 			Fail(fmt.Sprintf("unknown resource type: %T", resType), 1)
 		}
@@ -260,25 +386,26 @@ func (cl *Resources) Cleanup() {
 
 	ExpectWithOffset(2, errs).To(BeEmpty(), "resource cleanup failed")
 
-	klog.V(4).Info("clearing managed resources list")
+	cl.logger().Info(4, "clearing managed resources list")
 	cl.managedResourceInfos = []resourceInfo{}
+	cl.Context.untrack(cl)
 }
 
 func (cl *Resources) cleanupVolume(ctx context.Context, offset int, volumeID string, info volumeInfo) (errs []error) {
-	klog.V(4).Infof("deleting volume ID %s", volumeID)
+	cl.logger().Info(4, "deleting volume", "volumeID", volumeID)
 	if cl.NodeClient != nil {
-		if _, err := cl.NodeUnpublishVolume(
+		if _, err := cl.NodeClient.NodeUnpublishVolume(
 			ctx,
 			&csi.NodeUnpublishVolumeRequest{
 				VolumeId:   volumeID,
-				TargetPath: cl.Context.TargetPath + "/target",
+				TargetPath: filepath.Join(cl.Context.TargetPath, "target"),
 			},
 		); isRelevantError(err) {
 			errs = append(errs, fmt.Errorf("NodeUnpublishVolume for volume ID %s failed: %s", volumeID, err))
 		}
 
 		if isNodeCapabilitySupported(cl, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME) {
-			if _, err := cl.NodeUnstageVolume(
+			if _, err := cl.NodeClient.NodeUnstageVolume(
 				ctx,
 				&csi.NodeUnstageVolumeRequest{
 					VolumeId:          volumeID,
@@ -316,8 +443,45 @@ func (cl *Resources) cleanupVolume(ctx context.Context, offset int, volumeID str
 	return errs
 }
 
+func (cl *Resources) cleanupStagedVolume(ctx context.Context, volumeID string, info stagedVolumeInfo) []error {
+	cl.logger().Info(4, "unstaging volume", "volumeID", volumeID, "stagingTargetPath", info.StagingTargetPath)
+	if _, err := cl.NodeClient.NodeUnstageVolume(
+		ctx,
+		&csi.NodeUnstageVolumeRequest{
+			VolumeId:          volumeID,
+			StagingTargetPath: info.StagingTargetPath,
+		},
+	); isRelevantError(err) {
+		return []error{fmt.Errorf("NodeUnstageVolume for volume ID %s failed: %s", volumeID, err)}
+	}
+	return nil
+}
+
+func (cl *Resources) cleanupPublishedVolume(ctx context.Context, volumeID string, info publishedVolumeInfo) []error {
+	cl.logger().Info(4, "unpublishing volume", "volumeID", volumeID, "targetPath", info.TargetPath)
+	if _, err := cl.NodeClient.NodeUnpublishVolume(
+		ctx,
+		&csi.NodeUnpublishVolumeRequest{
+			VolumeId:   volumeID,
+			TargetPath: info.TargetPath,
+		},
+	); isRelevantError(err) {
+		return []error{fmt.Errorf("NodeUnpublishVolume for volume ID %s failed: %s", volumeID, err)}
+	}
+	return nil
+}
+
+func (cl *Resources) cleanupStagingDir(path string) []error {
+	cl.logger().Info(4, "removing staging directory", "path", path)
+	config := cl.Context.Config
+	if err := removeMountTargetLocation(path, config.RemoveStagingPathCmd, config.RemoveStagingPath, config.RemovePathCmdTimeout); err != nil {
+		return []error{fmt.Errorf("removing staging directory %s failed: %s", path, err)}
+	}
+	return nil
+}
+
 func (cl *Resources) cleanupSnapshot(ctx context.Context, offset int, snapshotID string) []error {
-	klog.Infof("deleting snapshot ID %s", snapshotID)
+	cl.logger().Info(0, "deleting snapshot", "snapshotID", snapshotID)
 	if _, err := cl.ControllerClient.DeleteSnapshot(
 		ctx,
 		&csi.DeleteSnapshotRequest{
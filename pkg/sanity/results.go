@@ -0,0 +1,547 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SpecState is the outcome of a single spec.
+type SpecState string
+
+const (
+	SpecPassed  SpecState = "passed"
+	SpecFailed  SpecState = "failed"
+	SpecSkipped SpecState = "skipped"
+	SpecPending SpecState = "pending"
+)
+
+// SpecResult describes the outcome of one spec.
+type SpecResult struct {
+	// ID is the stable identifier assigned with SpecID, empty
+	// if the spec has none.
+	ID string
+	// Name is the full text of the spec, without the name of
+	// the Ginkgo suite and the identifier.
+	Name string
+	// Area is the text of the DescribeSanity block which contains
+	// the spec, for example "Node Service".
+	Area string
+	// Groups are the groups assigned to the ID in
+	// TestConfig.SpecGroupsFile.
+	Groups   []string
+	State    SpecState
+	Duration time.Duration
+	// Failure is the failure message for failed specs and the
+	// reason for skipped specs, if there is one.
+	Failure string
+	// Attempts is the number of times the spec ran, more than one
+	// if it was retried after a failure.
+	Attempts int
+	// MissingCapability is the capability which the driver lacks
+	// if the spec was skipped because of that.
+	MissingCapability string
+	// LastErrorMethod and LastErrorCode are the gRPC method and
+	// status code of the last call which failed while the spec
+	// ran, empty if none did. Negative tests fail calls on
+	// purpose, so this is not necessarily a problem.
+	LastErrorMethod string
+	LastErrorCode   string
+}
+
+// CapabilityMatrix lists the capabilities reported by the driver,
+// using the names from the CSI spec (e.g. CREATE_DELETE_VOLUME).
+type CapabilityMatrix struct {
+	Plugin     []string
+	Controller []string
+	Node       []string
+
+	// VolumeParameters is "required" if CreateVolume fails without
+	// the configured TestVolumeParameters, "optional" if it
+	// succeeds, and empty if that was not tested.
+	VolumeParameters string
+
+	// MultiStage is "supported" if a staged volume could be staged
+	// again at a different staging path, "rejected" if that
+	// failed, and empty if that was not tested.
+	MultiStage string
+}
+
+// RPCStats contains statistics for one gRPC method.
+type RPCStats struct {
+	// Calls is the total number of calls of the method.
+	Calls int
+	// Errors counts calls by gRPC status code, excluding OK.
+	Errors map[string]int
+	// TotalDuration is the sum of all call durations.
+	TotalDuration time.Duration
+	// P50, P95 and Max are the median, 95th percentile and
+	// highest latency of the calls.
+	P50 time.Duration
+	P95 time.Duration
+	Max time.Duration
+}
+
+// SocketInfo describes a unix domain socket of the driver.
+type SocketInfo struct {
+	Path string
+	// Mode contains the permission bits in octal notation.
+	Mode string
+}
+
+// Results summarizes a sanity test run. It is returned by Test and
+// can be retrieved from a TestContext with Results.
+type Results struct {
+	StartTime time.Time
+	Duration  time.Duration
+	// Succeeded is true if no spec failed.
+	Succeeded bool
+	// DryRun is true if the results come from a run with
+	// TestConfig.DryRun.
+	DryRun bool
+	// RandomSeed is the seed which reproduces the run, see
+	// TestConfig.RandomSeed.
+	RandomSeed int64
+
+	// DriverName and DriverVersion are the name and vendor version
+	// returned by GetPluginInfo. SpecVersion is the version of the
+	// CSI spec that the suite was built with.
+	DriverName    string
+	DriverVersion string
+	SpecVersion   string
+
+	// Environment describes the host on which the suite ran.
+	Environment Environment
+
+	Specs        []SpecResult
+	Capabilities CapabilityMatrix
+	// RPCs is keyed by the full gRPC method name
+	// (e.g. /csi.v1.Controller/CreateVolume).
+	RPCs map[string]RPCStats
+
+	// ProvisionedBytes is the capacity of all volumes which were
+	// created and not deleted, PeakProvisionedBytes the highest
+	// value it had during the run.
+	ProvisionedBytes     int64
+	PeakProvisionedBytes int64
+
+	// LeakedVolumes and LeakedSnapshots contain the IDs of
+	// resources which were created by the suite and still existed
+	// after it completed. Only set when TestConfig.LeakCheck is
+	// enabled.
+	LeakedVolumes   []string
+	LeakedSnapshots []string
+
+	// Sockets lists the unix domain sockets of Address and
+	// ControllerAddress.
+	Sockets []SocketInfo
+
+	// ConnectionDiagnostics contains the snapshots taken with
+	// TestConfig.ConnectionDiagnostics when calls failed with
+	// Unavailable or DeadlineExceeded.
+	ConnectionDiagnostics []ConnectionDiagnostic
+}
+
+// Count returns the number of specs with the given state.
+func (r *Results) Count(state SpecState) int {
+	count := 0
+	for _, spec := range r.Specs {
+		if spec.State == state {
+			count++
+		}
+	}
+	return count
+}
+
+// resultsCollector gathers Results while the tests are running. It
+// implements the Ginkgo Reporter interface for spec outcomes and
+// provides a gRPC interceptor for RPC statistics and capabilities.
+// All methods can be called concurrently.
+type resultsCollector struct {
+	mutex        sync.Mutex
+	startTime    time.Time
+	duration     time.Duration
+	specs        []SpecResult
+	capabilities map[string]map[string]bool
+	rpcs         map[string]*RPCStats
+	latencies    map[string][]time.Duration
+
+	volumeParameters string
+	multiStage       string
+	sockets          []SocketInfo
+	driverName       string
+	driverVersion    string
+
+	// specIndex maps the full text of a spec, without the suite
+	// name, to its entry in specs, so that retries replace the
+	// previous attempt.
+	specIndex map[string]int
+
+	// currentSpec is the name of the running spec.
+	currentSpec string
+	// diagnose is TestConfig.ConnectionDiagnostics.
+	diagnose    func(ctx context.Context) (json.RawMessage, error)
+	diagnostics []ConnectionDiagnostic
+
+	// missingCapability is the capability which caused the
+	// running spec to be skipped.
+	missingCapability string
+
+	// lastErrorMethod and lastErrorCode describe the last failed
+	// call of the running spec.
+	lastErrorMethod string
+	lastErrorCode   string
+
+	// ignored is set when the running attempt is not recorded
+	// because it only repeats the failure of the previous one.
+	ignored bool
+
+	// IDs of all volumes and snapshots that were created.
+	createdVolumes   map[string]bool
+	createdSnapshots map[string]bool
+}
+
+func newResultsCollector(diagnose func(ctx context.Context) (json.RawMessage, error)) *resultsCollector {
+	return &resultsCollector{
+		diagnose:         diagnose,
+		capabilities:     map[string]map[string]bool{},
+		rpcs:             map[string]*RPCStats{},
+		latencies:        map[string][]time.Duration{},
+		specIndex:        map[string]int{},
+		createdVolumes:   map[string]bool{},
+		createdSnapshots: map[string]bool{},
+	}
+}
+
+func (rc *resultsCollector) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.startTime = time.Now()
+}
+
+func (rc *resultsCollector) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+// specName returns the full text of the spec without the suite name,
+// the spec ID and the groups.
+func specName(specSummary *types.SpecSummary) string {
+	var name string
+	if len(specSummary.ComponentTexts) > 1 {
+		_, name = parseSpecID(strings.Join(specSummary.ComponentTexts[1:], " "))
+		_, name = parseSpecGroups(name)
+	}
+	return name
+}
+
+func (rc *resultsCollector) SpecWillRun(specSummary *types.SpecSummary) {
+	name := specName(specSummary)
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.currentSpec = name
+	rc.missingCapability = ""
+	rc.lastErrorMethod = ""
+	rc.lastErrorCode = ""
+	rc.ignored = false
+}
+
+// ignoreAttempt keeps the result of the running spec from replacing
+// the result of its previous attempt.
+func (rc *resultsCollector) ignoreAttempt() {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.ignored = true
+}
+
+// runningSpec returns the name of the running spec.
+func (rc *resultsCollector) runningSpec() string {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	return rc.currentSpec
+}
+
+// setMissingCapability records why the running spec gets skipped.
+func (rc *resultsCollector) setMissingCapability(capability string) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.missingCapability = capability
+}
+
+func (rc *resultsCollector) SpecDidComplete(specSummary *types.SpecSummary) {
+	result := SpecResult{
+		Duration: specSummary.RunTime,
+	}
+	if len(specSummary.ComponentTexts) > 1 {
+		result.ID, result.Name = parseSpecID(strings.Join(specSummary.ComponentTexts[1:], " "))
+		result.Groups, result.Name = parseSpecGroups(result.Name)
+		result.Area = specSummary.ComponentTexts[1]
+	}
+	switch {
+	case specSummary.HasFailureState():
+		result.State = SpecFailed
+		result.Failure = specSummary.Failure.Message
+	case specSummary.Skipped():
+		result.State = SpecSkipped
+		result.Failure = specSummary.Failure.Message
+	case specSummary.Pending():
+		result.State = SpecPending
+	default:
+		result.State = SpecPassed
+	}
+
+	var text string
+	if len(specSummary.ComponentTexts) > 1 {
+		text = strings.Join(specSummary.ComponentTexts[1:], " ")
+	}
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if rc.ignored {
+		return
+	}
+	if result.State == SpecSkipped {
+		result.MissingCapability = rc.missingCapability
+	}
+	result.LastErrorMethod = rc.lastErrorMethod
+	result.LastErrorCode = rc.lastErrorCode
+	if index, ok := rc.specIndex[text]; ok && text != "" {
+		result.Attempts = rc.specs[index].Attempts + 1
+		rc.specs[index] = result
+		return
+	}
+	result.Attempts = 1
+	if text != "" {
+		rc.specIndex[text] = len(rc.specs)
+	}
+	rc.specs = append(rc.specs, result)
+}
+
+// previousFailure returns the failure message of the last attempt of
+// the spec with the given full text, as in GinkgoTestDescription.
+func (rc *resultsCollector) previousFailure(text string) string {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if index, ok := rc.specIndex[text]; ok {
+		return rc.specs[index].Failure
+	}
+	return ""
+}
+
+func (rc *resultsCollector) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (rc *resultsCollector) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.duration = time.Since(rc.startTime)
+}
+
+// interceptor records statistics for each call, the capabilities
+// returned by the driver and the IDs of created resources.
+func (rc *resultsCollector) interceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	duration := time.Since(start)
+
+	if code := status.Code(err); rc.diagnose != nil && isConnectionError(code) {
+		rc.captureConnectionDiagnostic(method, code)
+	}
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	stats := rc.rpcs[method]
+	if stats == nil {
+		stats = &RPCStats{Errors: map[string]int{}}
+		rc.rpcs[method] = stats
+	}
+	stats.Calls++
+	stats.TotalDuration += duration
+	rc.latencies[method] = append(rc.latencies[method], duration)
+	if err != nil {
+		stats.Errors[status.Code(err).String()]++
+		rc.lastErrorMethod = method
+		rc.lastErrorCode = status.Code(err).String()
+		return err
+	}
+
+	switch r := reply.(type) {
+	case *csi.GetPluginInfoResponse:
+		rc.driverName = r.GetName()
+		rc.driverVersion = r.GetVendorVersion()
+	case *csi.GetPluginCapabilitiesResponse:
+		for _, cap := range r.GetCapabilities() {
+			switch {
+			case cap.GetService() != nil:
+				rc.addCapability("plugin", cap.GetService().GetType().String())
+			case cap.GetVolumeExpansion() != nil:
+				rc.addCapability("plugin", "VOLUME_EXPANSION_"+cap.GetVolumeExpansion().GetType().String())
+			}
+		}
+	case *csi.ControllerGetCapabilitiesResponse:
+		for _, cap := range r.GetCapabilities() {
+			rc.addCapability("controller", cap.GetRpc().GetType().String())
+		}
+	case *csi.NodeGetCapabilitiesResponse:
+		for _, cap := range r.GetCapabilities() {
+			rc.addCapability("node", cap.GetRpc().GetType().String())
+		}
+	case *csi.CreateVolumeResponse:
+		rc.createdVolumes[r.GetVolume().GetVolumeId()] = true
+	case *csi.CreateSnapshotResponse:
+		rc.createdSnapshots[r.GetSnapshot().GetSnapshotId()] = true
+	}
+	return nil
+}
+
+// captureConnectionDiagnostic records the connection state after a
+// failed call, up to maxConnectionDiagnostics times.
+func (rc *resultsCollector) captureConnectionDiagnostic(method string, code codes.Code) {
+	rc.mutex.Lock()
+	if len(rc.diagnostics) >= maxConnectionDiagnostics {
+		rc.mutex.Unlock()
+		return
+	}
+	diagnostic := ConnectionDiagnostic{
+		Spec:   rc.currentSpec,
+		Method: method,
+		Code:   code.String(),
+		Time:   time.Now(),
+	}
+	rc.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dump, err := rc.diagnose(ctx)
+	if err != nil {
+		dump, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	diagnostic.Channelz = dump
+
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.diagnostics = append(rc.diagnostics, diagnostic)
+}
+
+func (rc *resultsCollector) addCapability(service, name string) {
+	if rc.capabilities[service] == nil {
+		rc.capabilities[service] = map[string]bool{}
+	}
+	rc.capabilities[service][name] = true
+}
+
+// results returns a copy of the data collected so far.
+func (rc *resultsCollector) results() *Results {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	results := &Results{
+		StartTime: rc.startTime,
+		Duration:  rc.duration,
+		Succeeded: true,
+
+		DriverName:    rc.driverName,
+		DriverVersion: rc.driverVersion,
+		SpecVersion:   specVersion(),
+
+		Specs: append([]SpecResult(nil), rc.specs...),
+		Capabilities: CapabilityMatrix{
+			Plugin:     sortedKeys(rc.capabilities["plugin"]),
+			Controller: sortedKeys(rc.capabilities["controller"]),
+			Node:       sortedKeys(rc.capabilities["node"]),
+
+			VolumeParameters: rc.volumeParameters,
+			MultiStage:       rc.multiStage,
+		},
+		RPCs:    map[string]RPCStats{},
+		Sockets: append([]SocketInfo(nil), rc.sockets...),
+
+		ConnectionDiagnostics: append([]ConnectionDiagnostic(nil), rc.diagnostics...),
+	}
+	for _, spec := range results.Specs {
+		if spec.State == SpecFailed {
+			results.Succeeded = false
+		}
+	}
+	for method, stats := range rc.rpcs {
+		copied := *stats
+		copied.Errors = map[string]int{}
+		for code, count := range stats.Errors {
+			copied.Errors[code] = count
+		}
+		copied.setLatencies(rc.latencies[method])
+		results.RPCs[method] = copied
+	}
+	return results
+}
+
+// setVolumeParameters records whether volume parameters are
+// required, see CapabilityMatrix.VolumeParameters.
+func (rc *resultsCollector) setVolumeParameters(requirement string) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.volumeParameters = requirement
+}
+
+// setMultiStage records whether a volume can be staged at two paths,
+// see CapabilityMatrix.MultiStage.
+func (rc *resultsCollector) setMultiStage(behavior string) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.multiStage = behavior
+}
+
+// addSocket records information about a socket of the driver.
+func (rc *resultsCollector) addSocket(socket SocketInfo) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.sockets = append(rc.sockets, socket)
+}
+
+// created returns copies of the sets of created volume and snapshot
+// IDs.
+func (rc *resultsCollector) created() (volumes, snapshots map[string]bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	volumes = map[string]bool{}
+	for id := range rc.createdVolumes {
+		volumes[id] = true
+	}
+	snapshots = map[string]bool{}
+	for id := range rc.createdSnapshots {
+		snapshots[id] = true
+	}
+	return volumes, snapshots
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
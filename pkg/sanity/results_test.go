@@ -0,0 +1,257 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseSpecID(t *testing.T) {
+	for name, tc := range map[string]struct {
+		text, id, remaining string
+	}{
+		"none":     {"should work", "", "should work"},
+		"suffix":   {"should work [id:node/publish]", "node/publish", "should work"},
+		"middle":   {"Node Service should work [id:node/publish] [group:smoke]", "node/publish", "Node Service should work [group:smoke]"},
+		"first":    {"a [id:x/y] b [id:x/z]", "x/y", "a b [id:x/z]"},
+		"brackets": {"a [id:] b", "", "a [id:] b"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			id, remaining := parseSpecID(tc.text)
+			if id != tc.id || remaining != tc.remaining {
+				t.Errorf("expected %q and %q, got %q and %q", tc.id, tc.remaining, id, remaining)
+			}
+		})
+	}
+}
+
+func TestParseSpecGroups(t *testing.T) {
+	for name, tc := range map[string]struct {
+		text      string
+		groups    []string
+		remaining string
+	}{
+		"none":     {"should work", nil, "should work"},
+		"one":      {"should work [group:smoke]", []string{"smoke"}, "should work"},
+		"multiple": {"should work [group:smoke] [group:slow]", []string{"smoke", "slow"}, "should work"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			groups, remaining := parseSpecGroups(tc.text)
+			if !reflect.DeepEqual(groups, tc.groups) || remaining != tc.remaining {
+				t.Errorf("expected %q and %q, got %q and %q", tc.groups, tc.remaining, groups, remaining)
+			}
+		})
+	}
+}
+
+func TestSpecIDParameters(t *testing.T) {
+	defer func(groups map[string][]string, suffix string) {
+		specGroups, specIDSuffix = groups, suffix
+	}(specGroups, specIDSuffix)
+	specGroups = map[string][]string{
+		"createvolume/no-name":        {"smoke"},
+		"createvolume/no-name@type=b": {"thick"},
+	}
+
+	ids := map[string]bool{}
+	for name, tc := range map[string]struct {
+		parameters map[string]string
+		id         string
+		groups     []string
+	}{
+		"none":     {nil, "createvolume/no-name", []string{"smoke"}},
+		"default":  {map[string]string{}, "createvolume/no-name@default", []string{"smoke"}},
+		"thin":     {map[string]string{"type": "a"}, "createvolume/no-name@type=a", []string{"smoke"}},
+		"thick":    {map[string]string{"type": "b"}, "createvolume/no-name@type=b", []string{"smoke", "thick"}},
+		"multiple": {map[string]string{"type": "a", "replicas": "2"}, "createvolume/no-name@replicas=2,type=a", []string{"smoke"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			specIDSuffix = ""
+			if tc.parameters != nil {
+				specIDSuffix = "@" + parameterLabel(tc.parameters)
+			}
+			id, remaining := parseSpecID(SpecID("createvolume/no-name", "should fail"))
+			groups, remaining := parseSpecGroups(remaining)
+			if id != tc.id || remaining != "should fail" || !reflect.DeepEqual(groups, tc.groups) {
+				t.Errorf("expected %q with groups %q, got %q with groups %q and text %q", tc.id, tc.groups, id, groups, remaining)
+			}
+			if baseSpecID(id) != "createvolume/no-name" {
+				t.Errorf("wrong base ID %q", baseSpecID(id))
+			}
+		})
+		ids[tc.id] = true
+	}
+	if len(ids) != 5 {
+		t.Errorf("spec IDs are not unique: %v", ids)
+	}
+}
+
+func TestAllowedRetries(t *testing.T) {
+	sc := &TestContext{
+		Config: &TestConfig{
+			SpecRetries: map[string]int{
+				"createvolume/no-name":        2,
+				"createvolume/no-name@type=b": 5,
+			},
+		},
+		flakeAttempts: 1,
+	}
+	for id, expected := range map[string]int{
+		"createvolume/no-name":        2,
+		"createvolume/no-name@type=a": 2,
+		"createvolume/no-name@type=b": 5,
+		"createvolume/other":          0,
+		"createvolume/other@type=a":   0,
+	} {
+		if actual := sc.allowedRetries(id); actual != expected {
+			t.Errorf("%s: expected %d retries, got %d", id, expected, actual)
+		}
+	}
+}
+
+// summary returns a SpecSummary like Ginkgo would for a spec in
+// the sanity suite.
+func summary(state types.SpecState, message string, texts ...string) *types.SpecSummary {
+	return &types.SpecSummary{
+		ComponentTexts: append([]string{"CSI Driver Test Suite"}, texts...),
+		State:          state,
+		RunTime:        time.Second,
+		Failure:        types.SpecFailure{Message: message},
+	}
+}
+
+func TestResultsCollectorSpecs(t *testing.T) {
+	rc := newResultsCollector(nil)
+	rc.SpecSuiteWillBegin(config.GinkgoConfigType{}, &types.SuiteSummary{})
+
+	passed := summary(types.SpecStatePassed, "", "Node Service", "should work [id:node/work] [group:smoke]")
+	rc.SpecWillRun(passed)
+	rc.SpecDidComplete(passed)
+
+	// A retried spec replaces the previous attempt.
+	failed := summary(types.SpecStateFailed, "boom", "Node Service", "should retry [id:node/retry]")
+	rc.SpecWillRun(failed)
+	rc.SpecDidComplete(failed)
+	if message := rc.previousFailure("Node Service should retry [id:node/retry]"); message != "boom" {
+		t.Errorf("expected previous failure %q, got %q", "boom", message)
+	}
+	retried := summary(types.SpecStatePassed, "", "Node Service", "should retry [id:node/retry]")
+	rc.SpecWillRun(retried)
+	rc.SpecDidComplete(retried)
+
+	skipped := summary(types.SpecStateSkipped, "not supported", "Controller Service", "should snapshot")
+	rc.SpecWillRun(skipped)
+	rc.setMissingCapability("CREATE_DELETE_SNAPSHOT")
+	rc.SpecDidComplete(skipped)
+	rc.SpecSuiteDidEnd(&types.SuiteSummary{})
+
+	results := rc.results()
+	expected := []SpecResult{
+		{ID: "node/work", Name: "Node Service should work", Area: "Node Service", Groups: []string{"smoke"}, State: SpecPassed, Duration: time.Second, Attempts: 1},
+		{ID: "node/retry", Name: "Node Service should retry", Area: "Node Service", State: SpecPassed, Duration: time.Second, Attempts: 2},
+		{Name: "Controller Service should snapshot", Area: "Controller Service", State: SpecSkipped, Duration: time.Second, Failure: "not supported", Attempts: 1, MissingCapability: "CREATE_DELETE_SNAPSHOT"},
+	}
+	if !reflect.DeepEqual(results.Specs, expected) {
+		t.Errorf("expected specs:\n%+v\ngot:\n%+v", expected, results.Specs)
+	}
+	if !results.Succeeded {
+		t.Error("expected success after the retry passed")
+	}
+	if results.Count(SpecPassed) != 2 || results.Count(SpecSkipped) != 1 {
+		t.Errorf("unexpected counts: %d passed, %d skipped", results.Count(SpecPassed), results.Count(SpecSkipped))
+	}
+}
+
+func TestResultsCollectorIgnoredAttempt(t *testing.T) {
+	rc := newResultsCollector(nil)
+	rc.SpecSuiteWillBegin(config.GinkgoConfigType{}, &types.SuiteSummary{})
+
+	failed := summary(types.SpecStateFailed, "boom", "Node Service", "should fail [id:node/fail]")
+	rc.SpecWillRun(failed)
+	rc.SpecDidComplete(failed)
+
+	// An attempt which exceeds the allowed retries does not
+	// replace the first failure.
+	notRetried := summary(types.SpecStateFailed, "boom", "Node Service", "should fail [id:node/fail]")
+	notRetried.RunTime = 0
+	rc.SpecWillRun(notRetried)
+	rc.ignoreAttempt()
+	rc.SpecDidComplete(notRetried)
+	rc.SpecSuiteDidEnd(&types.SuiteSummary{})
+
+	expected := []SpecResult{
+		{ID: "node/fail", Name: "Node Service should fail", Area: "Node Service", State: SpecFailed, Duration: time.Second, Failure: "boom", Attempts: 1},
+	}
+	if specs := rc.results().Specs; !reflect.DeepEqual(specs, expected) {
+		t.Errorf("expected specs:\n%+v\ngot:\n%+v", expected, specs)
+	}
+}
+
+func TestResultsCollectorInterceptor(t *testing.T) {
+	rc := newResultsCollector(nil)
+	call := func(method string, reply interface{}, err error) {
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return err
+		}
+		if result := rc.interceptor(context.Background(), method, nil, reply, nil, invoker); result != err {
+			t.Errorf("%s: expected error %v, got %v", method, err, result)
+		}
+	}
+
+	call("/csi.v1.Identity/GetPluginInfo", &csi.GetPluginInfoResponse{Name: "mock", VendorVersion: "1.0"}, nil)
+	call("/csi.v1.Controller/ControllerGetCapabilities", &csi.ControllerGetCapabilitiesResponse{
+		Capabilities: []*csi.ControllerServiceCapability{{
+			Type: &csi.ControllerServiceCapability_Rpc{
+				Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME},
+			},
+		}},
+	}, nil)
+	call("/csi.v1.Controller/CreateVolume", &csi.CreateVolumeResponse{Volume: &csi.Volume{VolumeId: "vol-1"}}, nil)
+	call("/csi.v1.Controller/CreateVolume", &csi.CreateVolumeResponse{}, status.Error(codes.AlreadyExists, "exists"))
+	call("/csi.v1.Controller/CreateSnapshot", &csi.CreateSnapshotResponse{Snapshot: &csi.Snapshot{SnapshotId: "snap-1"}}, nil)
+	call("/csi.v1.Node/NodeGetInfo", &csi.NodeGetInfoResponse{}, errors.New("not a status"))
+
+	results := rc.results()
+	if results.DriverName != "mock" || results.DriverVersion != "1.0" {
+		t.Errorf("unexpected driver %q %q", results.DriverName, results.DriverVersion)
+	}
+	if !reflect.DeepEqual(results.Capabilities.Controller, []string{"CREATE_DELETE_VOLUME"}) {
+		t.Errorf("unexpected controller capabilities %v", results.Capabilities.Controller)
+	}
+	createVolume := results.RPCs["/csi.v1.Controller/CreateVolume"]
+	if createVolume.Calls != 2 || !reflect.DeepEqual(createVolume.Errors, map[string]int{"AlreadyExists": 1}) {
+		t.Errorf("unexpected CreateVolume stats %+v", createVolume)
+	}
+	if errs := results.RPCs["/csi.v1.Node/NodeGetInfo"].Errors; !reflect.DeepEqual(errs, map[string]int{"Unknown": 1}) {
+		t.Errorf("unexpected NodeGetInfo errors %v", errs)
+	}
+	volumes, snapshots := rc.created()
+	if !reflect.DeepEqual(volumes, map[string]bool{"vol-1": true}) || !reflect.DeepEqual(snapshots, map[string]bool{"snap-1": true}) {
+		t.Errorf("unexpected created resources %v %v", volumes, snapshots)
+	}
+}
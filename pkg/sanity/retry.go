@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy determines how calls which failed with a transient
+// error are repeated. The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per call,
+	// including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, 100ms
+	// if zero. Each further delay is Multiplier times longer, up
+	// to MaxBackoff, which defaults to 10s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Multiplier defaults to 2.
+	Multiplier float64
+	// RetryableCodes are the gRPC status codes for which a call is
+	// repeated. The default is Unavailable.
+	RetryableCodes []codes.Code
+}
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+)
+
+// retryable returns true if a call which failed with the code may be
+// repeated.
+func (p RetryPolicy) retryable(code codes.Code) bool {
+	if len(p.RetryableCodes) == 0 {
+		return code == codes.Unavailable
+	}
+	for _, retryable := range p.RetryableCodes {
+		if code == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// initialBackoff returns the delay before the first retry.
+func (p RetryPolicy) initialBackoff() time.Duration {
+	backoff := p.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+	if maxBackoff := p.maxBackoff(); backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+func (p RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return defaultMaxBackoff
+	}
+	return p.MaxBackoff
+}
+
+// nextBackoff returns the delay after the given one.
+func (p RetryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	next := float64(backoff) * multiplier
+	if maxBackoff := p.maxBackoff(); next > float64(maxBackoff) {
+		return maxBackoff
+	}
+	return time.Duration(next)
+}
+
+// retryInterceptor applies TestConfig.RetryPolicy.
+func (sc *TestContext) retryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	policy := sc.Config.RetryPolicy
+	backoff := policy.initialBackoff()
+	var canceled <-chan struct{}
+	if sc.Config.Context != nil {
+		canceled = sc.Config.Context.Done()
+	}
+	for attempt := 1; ; attempt++ {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		code := status.Code(err)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(code) {
+			return err
+		}
+
+		sc.Config.logger().Info(3, "retrying failed call", "method", method, "code", code, "attempt", attempt, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-canceled:
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = policy.nextBackoff(backoff)
+	}
+}
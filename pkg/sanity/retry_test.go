@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy   RetryPolicy
+		expected []time.Duration
+	}{
+		"defaults": {
+			RetryPolicy{},
+			[]time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 1600 * time.Millisecond, 3200 * time.Millisecond, 6400 * time.Millisecond, 10 * time.Second, 10 * time.Second},
+		},
+		"initial": {
+			RetryPolicy{InitialBackoff: time.Second},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second},
+		},
+		"multiplier": {
+			RetryPolicy{InitialBackoff: time.Second, Multiplier: 1.5, MaxBackoff: 3 * time.Second},
+			[]time.Duration{time.Second, 1500 * time.Millisecond, 2250 * time.Millisecond, 3 * time.Second},
+		},
+		"constant": {
+			RetryPolicy{InitialBackoff: 50 * time.Millisecond, Multiplier: 1},
+			[]time.Duration{50 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond},
+		},
+		"initial above max": {
+			RetryPolicy{InitialBackoff: time.Minute, MaxBackoff: time.Second},
+			[]time.Duration{time.Second, time.Second},
+		},
+		"no overflow": {
+			RetryPolicy{InitialBackoff: time.Hour, Multiplier: 1e12, MaxBackoff: 2 * time.Hour},
+			[]time.Duration{time.Hour, 2 * time.Hour, 2 * time.Hour},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var actual []time.Duration
+			backoff := tc.policy.initialBackoff()
+			for range tc.expected {
+				actual = append(actual, backoff)
+				backoff = tc.policy.nextBackoff(backoff)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy   RetryPolicy
+		code     codes.Code
+		expected int
+	}{
+		"disabled":      {RetryPolicy{}, codes.Unavailable, 1},
+		"retried":       {RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}, codes.Unavailable, 3},
+		"not retryable": {RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}, codes.Internal, 1},
+		"custom codes":  {RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, RetryableCodes: []codes.Code{codes.Aborted}}, codes.Aborted, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sc := &TestContext{Config: &TestConfig{RetryPolicy: tc.policy}}
+			attempts := 0
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				attempts++
+				return status.Error(tc.code, "failed")
+			}
+			err := sc.retryInterceptor(context.Background(), "/csi.v1.Controller/CreateVolume", nil, nil, nil, invoker)
+			if status.Code(err) != tc.code {
+				t.Errorf("expected %s, got %v", tc.code, err)
+			}
+			if attempts != tc.expected {
+				t.Errorf("expected %d attempts, got %d", tc.expected, attempts)
+			}
+		})
+	}
+}
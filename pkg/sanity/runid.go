@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tagName adds TestVolumeNamePrefix and RunID to the name of a
+// volume or snapshot. Empty names are left alone for the tests which
+// check that they get rejected.
+func (config *TestConfig) tagName(name string, prefix string) string {
+	if name == "" {
+		return name
+	}
+	if config.RunID != "" {
+		name = name + "-" + config.RunID
+	}
+	return prefix + name
+}
+
+// maxNameLength returns the length of the longest name which
+// tagName with the given prefix turns into a name of MaxNameLength
+// bytes.
+func (config *TestConfig) maxNameLength(prefix string) int {
+	length := MaxNameLength - len(prefix)
+	if config.RunID != "" {
+		length -= len(config.RunID) + 1
+	}
+	return length
+}
+
+// maxVolumeNameLength is maxNameLength for volumes.
+func (config *TestConfig) maxVolumeNameLength() int {
+	return config.maxNameLength(config.TestVolumeNamePrefix)
+}
+
+// maxSnapshotNameLength is maxNameLength for snapshots.
+func (config *TestConfig) maxSnapshotNameLength() int {
+	return config.maxNameLength("")
+}
+
+// tagParameters returns the parameters with the RunIDParameter added,
+// or the original ones if there is nothing to add. An empty, non-nil
+// map is left alone for the test which checks that parameters are
+// optional.
+func (config *TestConfig) tagParameters(parameters map[string]string) map[string]string {
+	if config.RunID == "" || config.RunIDParameter == "" || parameters != nil && len(parameters) == 0 {
+		return parameters
+	}
+	tagged := map[string]string{config.RunIDParameter: config.RunID}
+	for key, value := range parameters {
+		tagged[key] = value
+	}
+	return tagged
+}
+
+// tagVolumeRequest returns a copy of the request with the name and
+// parameters tagged. The request may get reused by the test, so it
+// must not be modified.
+func (config *TestConfig) tagVolumeRequest(req *csi.CreateVolumeRequest) *csi.CreateVolumeRequest {
+	if config.TestVolumeNamePrefix == "" && config.RunID == "" {
+		return req
+	}
+	parameters := req.Parameters
+	req = proto.Clone(req).(*csi.CreateVolumeRequest)
+	req.Name = config.tagName(req.Name, config.TestVolumeNamePrefix)
+	req.Parameters = config.tagParameters(parameters)
+	return req
+}
+
+// tagSnapshotRequest is the same as tagVolumeRequest for snapshots.
+func (config *TestConfig) tagSnapshotRequest(req *csi.CreateSnapshotRequest) *csi.CreateSnapshotRequest {
+	if config.RunID == "" {
+		return req
+	}
+	parameters := req.Parameters
+	req = proto.Clone(req).(*csi.CreateSnapshotRequest)
+	req.Name = config.tagName(req.Name, "")
+	req.Parameters = config.tagParameters(parameters)
+	return req
+}
+
+// CleanupRun deletes the volumes and snapshots which were left behind
+// by an aborted run with the given TestConfig.RunID. CSI does not
+// return names when listing, so only resources whose ID contains the
+// run ID, or volumes with the run ID as a value in their volume
+// context (see TestConfig.RunIDParameter), can be found. The driver
+// must support ListVolumes and/or ListSnapshots. The IDs of the
+// deleted resources are returned, also when some deletions failed.
+func CleanupRun(conn *grpc.ClientConn, runID string, secrets *CSISecrets) ([]string, error) {
+	if runID == "" {
+		return nil, fmt.Errorf("run ID must not be empty")
+	}
+	if secrets == nil {
+		secrets = &CSISecrets{}
+	}
+	ctx := context.Background()
+	client := csi.NewControllerClient(conn)
+	caps, err := client.ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ControllerGetCapabilities failed: %v", err)
+	}
+	supported := map[csi.ControllerServiceCapability_RPC_Type]bool{}
+	for _, cap := range caps.GetCapabilities() {
+		supported[cap.GetRpc().GetType()] = true
+	}
+
+	var deleted, failed []string
+	// Snapshots first, because volumes with snapshots might not be
+	// deletable.
+	if supported[csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS] {
+		token := ""
+		for {
+			rsp, err := client.ListSnapshots(ctx, &csi.ListSnapshotsRequest{StartingToken: token, Secrets: secrets.ListSnapshotsSecret})
+			if err != nil {
+				return deleted, fmt.Errorf("ListSnapshots failed: %v", err)
+			}
+			for _, entry := range rsp.GetEntries() {
+				id := entry.GetSnapshot().GetSnapshotId()
+				if !strings.Contains(id, runID) {
+					continue
+				}
+				if _, err := client.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: id, Secrets: secrets.DeleteSnapshotSecret}); err != nil {
+					failed = append(failed, fmt.Sprintf("snapshot %s: %v", id, err))
+					continue
+				}
+				deleted = append(deleted, id)
+			}
+			token = rsp.GetNextToken()
+			if token == "" {
+				break
+			}
+		}
+	}
+	if supported[csi.ControllerServiceCapability_RPC_LIST_VOLUMES] {
+		var ids []string
+		token := ""
+		for {
+			rsp, err := client.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: token})
+			if err != nil {
+				return deleted, fmt.Errorf("ListVolumes failed: %v", err)
+			}
+			for _, entry := range rsp.GetEntries() {
+				if volumeOfRun(entry.GetVolume(), runID) {
+					ids = append(ids, entry.GetVolume().GetVolumeId())
+				}
+			}
+			token = rsp.GetNextToken()
+			if token == "" {
+				break
+			}
+		}
+		// Deleting while listing could invalidate the token.
+		for _, id := range ids {
+			if _, err := client.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: id, Secrets: secrets.DeleteVolumeSecret}); err != nil {
+				failed = append(failed, fmt.Sprintf("volume %s: %v", id, err))
+				continue
+			}
+			deleted = append(deleted, id)
+		}
+	}
+	if len(failed) > 0 {
+		return deleted, fmt.Errorf("deleting failed for %s", strings.Join(failed, ", "))
+	}
+	return deleted, nil
+}
+
+// volumeOfRun checks whether the volume was created in the run.
+func volumeOfRun(vol *csi.Volume, runID string) bool {
+	if strings.Contains(vol.GetVolumeId(), runID) {
+		return true
+	}
+	for _, value := range vol.GetVolumeContext() {
+		if value == runID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+func TestTaggedNameLength(t *testing.T) {
+	for name, config := range map[string]TestConfig{
+		"none":          {},
+		"prefix":        {TestVolumeNamePrefix: "e2e-"},
+		"run ID":        {RunID: "run-1234"},
+		"both":          {TestVolumeNamePrefix: "cluster-a-", RunID: "20221014-153000"},
+		"long run ID":   {RunID: strings.Repeat("r", 64)},
+		"unicode affix": {TestVolumeNamePrefix: "ü-", RunID: "名前"},
+	} {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			for _, filler := range []string{"a", "ü"} {
+				volumeName := uniqueNameOfLength(config.maxVolumeNameLength(), "sanity-", filler)
+				volume := config.tagVolumeRequest(&csi.CreateVolumeRequest{Name: volumeName})
+				if len(volume.Name) != MaxNameLength {
+					t.Errorf("volume name with %q has %d bytes instead of %d: %q", filler, len(volume.Name), MaxNameLength, volume.Name)
+				}
+
+				snapshotName := uniqueNameOfLength(config.maxSnapshotNameLength(), "sanity-", filler)
+				snapshot := config.tagSnapshotRequest(&csi.CreateSnapshotRequest{Name: snapshotName})
+				if len(snapshot.Name) != MaxNameLength {
+					t.Errorf("snapshot name with %q has %d bytes instead of %d: %q", filler, len(snapshot.Name), MaxNameLength, snapshot.Name)
+				}
+				if config.RunID != "" && !strings.Contains(snapshot.Name, config.RunID) {
+					t.Errorf("run ID missing in snapshot name %q", snapshot.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestTaggedParameters(t *testing.T) {
+	config := TestConfig{RunID: "run-1234", RunIDParameter: "sanity/run"}
+	for name, tc := range map[string]struct {
+		parameters map[string]string
+		expected   map[string]string
+	}{
+		"nil":   {nil, map[string]string{"sanity/run": "run-1234"}},
+		"set":   {map[string]string{"type": "fast"}, map[string]string{"sanity/run": "run-1234", "type": "fast"}},
+		"empty": {map[string]string{}, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			check := func(kind string, actual map[string]string) {
+				// An empty map may become nil when cloning the request.
+				if len(actual) != len(tc.expected) || len(actual) > 0 && !reflect.DeepEqual(actual, tc.expected) {
+					t.Errorf("expected %s parameters %v, got %v", kind, tc.expected, actual)
+				}
+			}
+			check("volume", config.tagVolumeRequest(&csi.CreateVolumeRequest{Name: "volume", Parameters: tc.parameters}).Parameters)
+			check("snapshot", config.tagSnapshotRequest(&csi.CreateSnapshotRequest{Name: "snapshot", Parameters: tc.parameters}).Parameters)
+		})
+	}
+}
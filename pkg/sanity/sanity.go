@@ -17,22 +17,28 @@ limitations under the License.
 package sanity
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/kubernetes-csi/csi-test/v4/utils"
 	yaml "gopkg.in/yaml.v2"
 
 	"google.golang.org/grpc"
 
 	. "github.com/onsi/ginkgo"
-	"github.com/onsi/ginkgo/reporters"
+	ginkgoconfig "github.com/onsi/ginkgo/config"
 	. "github.com/onsi/gomega"
 )
 
@@ -72,8 +78,11 @@ type TestConfig struct {
 	Address string
 
 	// DialOptions specifies the options that are to be used
-	// when connecting to Address. The default is grpc.WithInsecure().
-	// A dialer will be added for Unix Domain Sockets.
+	// when connecting to Address, for example transport
+	// credentials, keepalive parameters, interceptors or
+	// grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(...)).
+	// The default is grpc.WithInsecure(). A dialer will be added
+	// for Unix Domain Sockets.
 	DialOptions []grpc.DialOption
 
 	// ControllerAddress optionally provides the gRPC endpoint of
@@ -81,29 +90,175 @@ type TestConfig struct {
 	ControllerAddress string
 
 	// ControllerDialOptions specifies the options that are to be used
-	// for ControllerAddress.
+	// for ControllerAddress. DialOptions are used when it is empty.
 	ControllerDialOptions []grpc.DialOption
 
+	// ExpectedSocketMode, if non-zero, are the permission bits (for
+	// example 0660) which the unix domain sockets of Address and
+	// ControllerAddress must have. The actual mode is recorded in
+	// the results regardless.
+	ExpectedSocketMode os.FileMode
+
 	// SecretsFile is the filename of a .yaml file which is used
 	// to populate CSISecrets which are then used for calls to the
-	// CSI driver.
+	// CSI driver. ${VAR} gets replaced with the value of the
+	// environment variable VAR and the content is processed as a
+	// text/template before parsing it.
 	SecretsFile string
 
+	// Secrets are used for calls to the CSI driver when SecretsFile
+	// is empty.
+	Secrets *CSISecrets
+
+	// Metadata is attached as gRPC metadata to every call, for
+	// drivers which expect for example an authorization header in
+	// addition to the CSI secrets. Keys must be lower case.
+	Metadata map[string]string
+	// MetadataFile is the filename of a .yaml file with additional
+	// Metadata entries.
+	MetadataFile string
+
+	// ExpandSecrets enables ${VAR} and template functions like
+	// {{ file "/path" }} in the values of SecretsFile and
+	// BadSecretsFile, see expandSecret. Without it, the values
+	// are used as they are.
+	ExpandSecrets bool
+	// SecretsTemplateFuncs are available with ExpandSecrets in
+	// addition to the default functions.
+	SecretsTemplateFuncs template.FuncMap
+
+	// BadSecretsFile is the filename of a .yaml file in the same
+	// format as SecretsFile, but with deliberately wrong
+	// credentials. When set, the calls for which it has a secret
+	// are tested with those credentials and must fail with
+	// Unauthenticated or PermissionDenied.
+	BadSecretsFile string
+
 	TestVolumeSize int64
 
 	// Target size for ExpandVolume requests. If not specified it defaults to TestVolumeSize + 1 GB
-	TestVolumeExpandSize      int64
+	TestVolumeExpandSize int64
+
+	// NoDefaultCapacity declares that the driver has no default
+	// volume size. Only then CreateVolume with a capacity range
+	// where neither required nor limit bytes are set must fail,
+	// otherwise the spec allows the driver to pick a size.
+	NoDefaultCapacity bool
+
+	// TestVolumeParametersFile is a YAML file with the parameters
+	// for CreateVolume. When it contains a list of parameter maps
+	// instead of a single map, the Controller Service and Node
+	// Service tests run once per entry, labelled with
+	// [parameters:<key>=<value>,...] in their names, and all
+	// other tests use the first entry.
 	TestVolumeParametersFile  string
 	TestVolumeParameters      map[string]string
 	TestNodeVolumeAttachLimit bool
 	TestVolumeAccessType      string
 
+	// TestVolumeAccessModes are the access modes for volumes that
+	// the tests create, for drivers which do not support
+	// SINGLE_NODE_WRITER. CreateVolume asks for all of them,
+	// the other calls use the first one, always with the
+	// TestVolumeAccessType.
+	TestVolumeAccessModes []csi.VolumeCapability_AccessMode_Mode
+	// TestVolumeCapabilities replaces TestVolumeAccessModes and
+	// TestVolumeAccessType with complete capabilities, for
+	// example to set an fs type or mount flags. CreateVolume asks
+	// for all of them, the other calls use the first one.
+	TestVolumeCapabilities []*csi.VolumeCapability
+
+	// TestVolumeNamePrefix is prepended to the names of all volumes
+	// that the tests create, for example to route them to a
+	// dedicated pool or to find them for cleaning up after an
+	// aborted run. The names themselves start with "sanity".
+	TestVolumeNamePrefix string
+
+	// RunID, if set, is appended to the names of all volumes and
+	// snapshots that the tests create, for example the ID of the CI
+	// job. Resources of an aborted run can then be deleted with
+	// CleanupRun.
+	RunID string
+	// RunIDParameter, if set together with RunID, is a parameter
+	// key which is added with the RunID as value to the parameters
+	// of all CreateVolume and CreateSnapshot calls. This helps
+	// CleanupRun to find volumes of drivers which return their
+	// parameters in the volume context. The volume created by the
+	// createvolume/parameters test does not get it because that
+	// test checks whether parameters are optional.
+	RunIDParameter string
+
+	// UnaryInterceptors and StreamInterceptors are added to the
+	// connections to the driver, for example for tracing. The
+	// unary interceptors run after those of the sanity package,
+	// once per attempt when calls get retried, and see the values
+	// of the context returned by SpecTracer.StartSpec.
+	UnaryInterceptors  []grpc.UnaryClientInterceptor
+	StreamInterceptors []grpc.StreamClientInterceptor
+	// ConnectionDiagnostics, if set, gets called when a call fails
+	// with Unavailable or DeadlineExceeded and the returned JSON
+	// ends up in Results.ConnectionDiagnostics. channelz.Dump from
+	// the channelz subpackage captures the gRPC connection state.
+	ConnectionDiagnostics func(ctx context.Context) (json.RawMessage, error)
+	// SpecTracer, if set, gets called by Test for each spec.
+	SpecTracer SpecTracer
+
+	// Logger receives the log output, by default it goes to klog.
+	// NewWriterLogger(GinkgoWriter, 0) shows it only for failed
+	// specs.
+	Logger Logger
+
 	// JUnitFile is used by Test to store test results in JUnit
-	// format. When using GinkgoTest, the caller is responsible
+	// format, with the driver name and version, CSI spec version
+	// and suite version as properties. When using GinkgoTest, the caller is responsible
 	// for configuring the Ginkgo runner.
 	JUnitFile string
 
-	// TestSnapshotParametersFile for setting CreateVolumeRequest.Parameters.
+	// JSONReportFile is used by Test to store a JSONReport with
+	// the outcome of each spec, for tools which do not want to
+	// parse JUnit.
+	JSONReportFile string
+
+	// TAPFile is used by Test to store the results in the Test
+	// Anything Protocol, for CI harnesses which consume TAP.
+	TAPFile string
+
+	// HTMLReportFile is used by Test to store a single-page HTML
+	// report with the outcome per area, failure details and
+	// timings, for release notes or certification submissions.
+	HTMLReportFile string
+
+	// LatencyReportFile is used by Test to store the table of
+	// Results.LatencyReport, which Test also logs.
+	LatencyReportFile string
+
+	// DumpMessages enables printing every request and response in
+	// protobuf text format, with secrets masked, into the output of
+	// the spec, next to its steps.
+	DumpMessages bool
+
+	// ArtifactsDir, if set, gets a directory per failed spec with
+	// its name and ID in spec.txt, the most recent calls as TrafficRecords in rpcs.jsonl and a
+	// listing of the target and staging paths in paths.txt,
+	// collected before the cleanup of the spec. ArtifactRPCs is
+	// the number of calls, 20 if zero.
+	ArtifactsDir string
+	ArtifactRPCs int
+	// OnFailure, if set, gets called with the name of each failed
+	// spec and its directory in ArtifactsDir, or an empty string,
+	// for collecting additional artifacts like driver logs while
+	// the state of the spec still exists.
+	OnFailure func(specName string, artifactsDir string)
+
+	// RecordTrafficFile, if set, gets one TrafficRecord per line
+	// with the method, request and response of every call, for
+	// replaying what the tests sent to the driver. Secrets are
+	// redacted.
+	RecordTrafficFile string
+
+	// TestSnapshotParametersFile is a YAML file with the parameters
+	// for CreateSnapshot, like those of a VolumeSnapshotClass. It
+	// replaces TestSnapshotParameters when set.
 	TestSnapshotParametersFile string
 	TestSnapshotParameters     map[string]string
 
@@ -153,6 +308,12 @@ type TestConfig struct {
 	// Timeout for the executed commands for path removal.
 	RemovePathCmdTimeout time.Duration
 
+	// SSH, if set, manages the target and staging paths on a
+	// remote node: the callbacks above which are not set get
+	// implemented with ssh, as does CheckPath. Commands like
+	// CreateTargetPathCmd still take precedence.
+	SSH *SSHConfig
+
 	// IDGen is an interface for callers to provide a
 	// generator for valid Volume and Node IDs. Defaults to
 	// DefaultIDGenerator.
@@ -173,6 +334,218 @@ type TestConfig struct {
 	CheckPathCmd string
 	// Timeout for the executed command to check a given path.
 	CheckPathCmdTimeout time.Duration
+
+	// Commands to be executed before and after creating a snapshot of a
+	// published volume, for example to freeze and thaw the file system.
+	// The commands must be available on the host where sanity runs and
+	// are passed the path where the volume is published. Without
+	// QuiesceCmd, snapshots of published volumes are only
+	// crash-consistent.
+	QuiesceCmd   string
+	UnquiesceCmd string
+	// Timeout for the executed quiesce and unquiesce commands.
+	QuiesceCmdTimeout time.Duration
+
+	// Callback functions which write data into a published volume
+	// and read it back. When set, the data integrity test writes a
+	// known pattern, unpublishes and republishes the volume, and
+	// then checks that the same data is read. Both functions are
+	// passed the path where the volume is published.
+	WriteData func(path string, data []byte) error
+	ReadData  func(path string) ([]byte, error)
+	// Commands to be executed instead of WriteData and ReadData.
+	// The commands must be available on the host where sanity runs
+	// and are passed the path where the volume is published.
+	// WriteDataCmd receives the data on stdin, ReadDataCmd must print
+	// it on stdout.
+	WriteDataCmd string
+	ReadDataCmd  string
+	// Timeout for the executed write and read commands.
+	DataCmdTimeout time.Duration
+
+	// StrictCapabilities turns tests that would be skipped because the
+	// driver lacks a capability into failures, for those capabilities
+	// which are listed in RequiredCapabilities. This ensures that a
+	// driver does not silently drop features between releases.
+	StrictCapabilities bool
+	// RequiredCapabilities lists capabilities by name, for example
+	// "Controller.CREATE_DELETE_SNAPSHOT". See PluginCapability,
+	// ControllerCapability and NodeCapability.
+	RequiredCapabilities []string
+	// RequiredCapabilitiesFile is a YAML file with a list of
+	// capability names. When set, it replaces RequiredCapabilities
+	// and enables StrictCapabilities.
+	RequiredCapabilitiesFile string
+
+	// RPCTimeout is the deadline for each gRPC call made by the
+	// tests, unless the call already has one. Zero disables it.
+	RPCTimeout time.Duration
+
+	// RetryPolicy repeats calls which failed with a transient
+	// error, for example while the driver restarts. Retries are
+	// disabled by default. Each attempt gets its own RPCTimeout.
+	RetryPolicy RetryPolicy
+
+	// Context, if set, cancels all gRPC calls of the tests once it
+	// is done, for example when a surrounding test gets aborted.
+	Context context.Context
+
+	// ProbeTimeout is how long the Probe test waits for a driver
+	// which reports that it is not ready yet.
+	ProbeTimeout time.Duration
+
+	// VendorVersionPattern is an optional regular expression that the
+	// vendor_version returned by GetPluginInfo must match, for example
+	// `^v?\d+\.\d+\.\d+$` for a semantic version.
+	VendorVersionPattern string
+	// ManifestKeys lists keys which must be present in the manifest
+	// returned by GetPluginInfo.
+	ManifestKeys []string
+
+	// MigratedInTreePluginName enables checks that the driver can
+	// replace the given in-tree volume plugin (for example
+	// "kubernetes.io/gce-pd") with Kubernetes CSI migration: the
+	// driver name, topology key and default file system type must
+	// match what the migration expects.
+	MigratedInTreePluginName string
+
+	// MaxTotalProvisionedBytes limits the total capacity of all
+	// volumes which exist at the same time. A CreateVolume call
+	// which would exceed it fails the test without reaching the
+	// driver, which protects shared storage systems. Zero means no
+	// limit.
+	MaxTotalProvisionedBytes int64
+
+	// UnimplementedPolicies determines per RPC what happens when
+	// the driver returns Unimplemented. The key is the method name
+	// without the service, for example "NodeGetVolumeStats". This
+	// allows drivers in development to skip the tests for RPCs
+	// which are not done yet, or to ensure that RPCs are implemented
+	// even where the CSI spec allows Unimplemented.
+	UnimplementedPolicies map[string]UnimplementedPolicy
+
+	// ResponseValidators are called with the request and response
+	// of each successful call of the RPC, keyed by the method name
+	// like UnimplementedPolicies. They allow checking driver
+	// specific invariants, for example the format of volume IDs,
+	// in all tests of the suite.
+	ResponseValidators map[string]ResponseValidator
+
+	// CapacityTolerance is the fraction (for example 0.1 for 10%)
+	// by which the available capacity returned by repeated
+	// GetCapacity calls may vary. Other tests running in parallel
+	// may create volumes, so it should not be zero.
+	CapacityTolerance float64
+
+	// LeakCheck enables listing volumes and snapshots before and
+	// after running the suite in Test. Resources created by the
+	// suite which were not deleted are reported in the Results and,
+	// depending on the mode, cause the test to fail. Requires
+	// support for ListVolumes and/or ListSnapshots in the driver.
+	LeakCheck LeakCheckMode
+
+	// RandomSeed determines the order of the tests as well as the
+	// names and IDs which they generate. Test uses the Ginkgo seed
+	// (-ginkgo.seed) when it is zero and logs the seed, so that
+	// a failing run can be repeated exactly. Resources left behind
+	// by the failed run must be deleted first because they have the
+	// same names.
+	RandomSeed int64
+
+	// DryRun runs the suite without calls which could modify the
+	// state of the driver: each spec gets skipped when it would
+	// make its first such call. Results.DryRunReport then lists
+	// which specs would run and which get skipped.
+	DryRun bool
+
+	// FailFast skips all tests except those for the Identity Service
+	// and the endpoint when the driver fails the fundamental checks:
+	// Probe, GetPluginInfo, GetPluginCapabilities and getting the
+	// controller and node capabilities. This avoids running the
+	// entire suite against a driver which does not work at all.
+	FailFast bool
+
+	// FocusTests and SkipTests are regular expressions which are
+	// added to -ginkgo.focus and -ginkgo.skip by Test. They are
+	// matched against the full spec text, which includes the
+	// identifier added by SpecID, so known-unsupported tests can be
+	// excluded with for example `\[id:createvolume/`.
+	FocusTests []string
+	SkipTests  []string
+
+	// SuiteVersion is reported as the version of the test suite,
+	// for example the version of csi-sanity. The version of the
+	// csi-test module is used when empty.
+	SuiteVersion string
+
+	// SpecRetries maps spec identifiers (see SpecID) to the number
+	// of times that the spec is repeated after a failure, for
+	// specs which are known to be flaky with a certain backend.
+	// Specs which are not listed are repeated as configured with
+	// -ginkgo.flakeAttempts. Test raises -ginkgo.flakeAttempts as
+	// needed, when embedding the tests with GinkgoTest it must be
+	// set to at least the highest number of attempts.
+	SpecRetries map[string]int
+
+	// OnInterrupt is called by Test when the suite gets
+	// interrupted by SIGINT or SIGTERM, after cleaning up the
+	// resources of the current test and before Ginkgo exits the
+	// process. It can be used to write partial reports.
+	OnInterrupt func(results *Results)
+	// InterruptTimeout is how long Test waits after SIGINT or
+	// SIGTERM for the current test to finish, including its
+	// cleanup. Afterwards the remaining resources of that test get
+	// cleaned up while it may still be running. Zero does not
+	// wait. A second signal ends the process immediately.
+	InterruptTimeout time.Duration
+
+	// IdentityOnly restricts the suite to the Identity Service and
+	// endpoint tests, for drivers which do not implement anything
+	// else yet. Such a driver must not report any plugin
+	// capabilities and must return the same information from
+	// repeated Probe and GetPluginInfo calls. It is read when the
+	// tests get registered.
+	IdentityOnly bool
+
+	// PauseBetweenAreas is the name of a file. When set, the suite
+	// pauses before the tests of a different area (a DescribeSanity
+	// block like "Node Service", once per parameter set with a
+	// list in TestVolumeParametersFile) until that file exists,
+	// then removes it and reconnects to the driver. This checks
+	// that the suite can continue with a driver that was replaced,
+	// for example by an upgrade. A volume which gets created before
+	// the first pause is kept until Finalize and must still be
+	// known to the driver after each pause; the volumes of the
+	// tests themselves are deleted by each test. The order of the
+	// areas is randomized by Ginkgo, use -ginkgo.seed to reproduce
+	// a certain order.
+	PauseBetweenAreas string
+
+	// PauseTimeout is how long the suite waits for the
+	// PauseBetweenAreas file before the test fails. Zero waits
+	// forever.
+	PauseTimeout time.Duration
+
+	// SpecGroupsFile is the filename of a .yaml file which maps
+	// spec identifiers (see SpecID) to lists of vendor-defined
+	// groups, for example "tier1" or "known-slow". The groups are
+	// added to the spec text as [group:<name>], so they can be
+	// used with -ginkgo.focus and -ginkgo.skip, and are reported
+	// in SpecResult.Groups. It is read when the tests get
+	// registered.
+	SpecGroupsFile string
+
+	// CallbackListenAddress enables the callback tests for drivers
+	// which call back to an HTTP endpoint of the CO while
+	// publishing a volume. The suite listens on this address (for
+	// example ":8080") and expects a POST with a JSON object that
+	// contains the "volume_id" of the published volume.
+	CallbackListenAddress string
+	// CallbackAllowedIPs, if not empty, lists the only addresses
+	// from which callbacks are accepted.
+	CallbackAllowedIPs []string
+	// CallbackTimeout is how long the suite waits for a callback.
+	CallbackTimeout time.Duration
 }
 
 // TestContext gets initialized by the sanity package before each test
@@ -186,6 +559,56 @@ type TestContext struct {
 	connAddress           string
 	controllerConnAddress string
 
+	results      *resultsCollector
+	provisioning *provisioningTracker
+
+	// Result of checkFundamentals.
+	fundamentalsChecked bool
+	fundamentalsErr     error
+
+	// Area of the current test, for PauseBetweenAreas.
+	currentArea string
+	// The volume kept across pauses, if any, and the Resources
+	// which delete it in Finalize. paused is set at the first
+	// pause.
+	paused       *Resources
+	pausedVolume *csi.Volume
+
+	// inSpecBody is true while the It of a test runs, but not
+	// during its BeforeEach and AfterEach, for
+	// UnimplementedPolicies.
+	inSpecBody bool
+
+	// Listener for CallbackListenAddress, started on demand.
+	callbacks *callbackServer
+
+	// Attempts per spec text and the value of
+	// -ginkgo.flakeAttempts before Test changed it, for
+	// SpecRetries.
+	specAttempts  map[string]int
+	flakeAttempts int
+	// notRetried is true during an attempt which only repeats
+	// the failure of the previous one.
+	notRetried bool
+
+	// Outcome of the checks at the end of the suite, see
+	// finishReporter.
+	leakedVolumes     []string
+	leakedSnapshots   []string
+	suiteChecksFailed bool
+
+	// Resources which need to be cleaned up when interrupted.
+	active activeResources
+
+	// Collected by the first call of Results.
+	environment *Environment
+
+	// Output for RecordTrafficFile.
+	recorder trafficRecorder
+
+	// Context of the running spec from SpecTracer.
+	tracing specTracing
+
 	// Target and staging paths derived from the sanity config.
 	TargetPath  string
 	StagingPath string
@@ -195,8 +618,8 @@ type TestContext struct {
 // their defaults.
 func NewTestConfig() TestConfig {
 	return TestConfig{
-		TargetPath:           os.TempDir() + "/csi-mount",
-		StagingPath:          os.TempDir() + "/csi-staging",
+		TargetPath:           filepath.Join(os.TempDir(), "csi-mount"),
+		StagingPath:          filepath.Join(os.TempDir(), "csi-staging"),
 		CreatePathCmdTimeout: 10 * time.Second,
 		RemovePathCmdTimeout: 10 * time.Second,
 		TestVolumeSize:       10 * 1024 * 1024 * 1024, // 10 GiB
@@ -204,9 +627,16 @@ func NewTestConfig() TestConfig {
 		IDGen:                &DefaultIDGenerator{},
 		IdempotentCount:      10,
 		CheckPathCmdTimeout:  10 * time.Second,
-
-		DialOptions:           []grpc.DialOption{grpc.WithInsecure()},
-		ControllerDialOptions: []grpc.DialOption{grpc.WithInsecure()},
+		QuiesceCmdTimeout:    10 * time.Second,
+		DataCmdTimeout:       10 * time.Second,
+		ProbeTimeout:         30 * time.Second,
+		RPCTimeout:           30 * time.Second,
+		CallbackTimeout:      30 * time.Second,
+		PauseTimeout:         time.Hour,
+		InterruptTimeout:     20 * time.Second,
+		CapacityTolerance:    0.1,
+
+		DialOptions: []grpc.DialOption{grpc.WithInsecure()},
 	}
 }
 
@@ -215,23 +645,111 @@ func NewTestConfig() TestConfig {
 // between the sanity package and the caller.
 func NewTestContext(config *TestConfig) *TestContext {
 	return &TestContext{
-		Config: config,
+		Config:       config,
+		results:      newResultsCollector(config.ConnectionDiagnostics),
+		provisioning: newProvisioningTracker(config),
 	}
 }
 
 // Test will test the CSI driver at the specified address by
-// setting up a Ginkgo suite and running it.
-func Test(t GinkgoTestingT, config TestConfig) {
+// setting up a Ginkgo suite and running it. The returned Results
+// describe the outcome of each spec, the capabilities reported by
+// the driver and the gRPC calls made during the run.
+func Test(t GinkgoTestingT, config TestConfig) *Results {
+	defer restoreTestSelection(ginkgoconfig.GinkgoConfig)
+	if config.RandomSeed == 0 {
+		config.RandomSeed = ginkgoconfig.GinkgoConfig.RandomSeed
+	}
+	ginkgoconfig.GinkgoConfig.RandomSeed = config.RandomSeed
+
+	// An invalid selection must fail before the tests get
+	// registered or the driver gets contacted.
+	if err := applyTestSelection(&config); err != nil {
+		config.logger().Error(err, "invalid test selection")
+		t.Fail()
+		results := NewTestContext(&config).Results()
+		results.Succeeded = false
+		return results
+	}
+	config.logger().Info(0, "running the sanity tests", "seed", config.RandomSeed)
+
 	sc := GinkgoTest(&config)
 	RegisterFailHandler(Fail)
 
-	var specReporters []Reporter
+	var before *resourceList
+	if config.LeakCheck != LeakCheckDisabled && !config.DryRun {
+		var err error
+		before, err = listResources(&config)
+		if err != nil {
+			config.logger().Error(err, "listing resources before the test suite failed")
+			t.Fail()
+		}
+	}
+
+	interrupts := newInterruptReporter(sc)
+	defer interrupts.stop()
+	specReporters := []Reporter{interrupts, sc.results, &finishReporter{sc: sc, t: t, interrupts: interrupts, before: before}}
 	if config.JUnitFile != "" {
-		junitReporter := reporters.NewJUnitReporter(config.JUnitFile)
-		specReporters = append(specReporters, junitReporter)
+		specReporters = append(specReporters, newJUnitReporter(sc, config.JUnitFile))
 	}
+	if config.JSONReportFile != "" {
+		specReporters = append(specReporters, &jsonReporter{sc: sc, path: config.JSONReportFile})
+	}
+	if config.SpecTracer != nil {
+		specReporters = append(specReporters, &tracingReporter{sc: sc})
+	}
+	if config.HTMLReportFile != "" {
+		specReporters = append(specReporters, &htmlReporter{sc: sc, path: config.HTMLReportFile})
+	}
+	if config.TAPFile != "" {
+		specReporters = append(specReporters, &tapReporter{sc: sc, path: config.TAPFile})
+	}
+	specReporters = append(specReporters, interrupts.completion())
+
+	sc.flakeAttempts = ginkgoconfig.GinkgoConfig.FlakeAttempts
+	if sc.flakeAttempts < 1 {
+		sc.flakeAttempts = 1
+	}
+	ginkgoconfig.GinkgoConfig.FlakeAttempts = maxSpecAttempts(&config, sc.flakeAttempts)
+
 	RunSpecsWithDefaultAndCustomReporters(t, "CSI Driver Test Suite", specReporters)
-	sc.Finalize()
+
+	results := sc.Results()
+	if config.DryRun {
+		results.DryRun = true
+		config.logger().Info(1, "dry run finished", "specs", len(results.Specs), "report", results.DryRunReport())
+	}
+	if len(results.RPCs) > 0 && !config.DryRun {
+		config.logger().Info(2, "latency per gRPC method", "report", results.LatencyReport())
+	}
+	if config.LatencyReportFile != "" {
+		if err := ioutil.WriteFile(config.LatencyReportFile, []byte(results.LatencyReport()), 0644); err != nil {
+			config.logger().Error(err, "writing latency report failed", "path", config.LatencyReportFile)
+		}
+	}
+	return results
+}
+
+// applyTestSelection adds FocusTests and SkipTests to the Ginkgo
+// configuration.
+func applyTestSelection(config *TestConfig) error {
+	for _, expr := range append(append([]string(nil), config.FocusTests...), config.SkipTests...) {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("%q: %v", expr, err)
+		}
+	}
+	ginkgoconfig.GinkgoConfig.FocusStrings = append(append([]string(nil), ginkgoconfig.GinkgoConfig.FocusStrings...), config.FocusTests...)
+	ginkgoconfig.GinkgoConfig.SkipStrings = append(append([]string(nil), ginkgoconfig.GinkgoConfig.SkipStrings...), config.SkipTests...)
+	return nil
+}
+
+// restoreTestSelection undoes applyTestSelection and the changes for
+// SpecRetries and RandomSeed.
+func restoreTestSelection(saved ginkgoconfig.GinkgoConfigType) {
+	ginkgoconfig.GinkgoConfig.FocusStrings = saved.FocusStrings
+	ginkgoconfig.GinkgoConfig.SkipStrings = saved.SkipStrings
+	ginkgoconfig.GinkgoConfig.FlakeAttempts = saved.FlakeAttempts
+	ginkgoconfig.GinkgoConfig.RandomSeed = saved.RandomSeed
 }
 
 // GinkoTest is another entry point for sanity testing: instead of
@@ -242,6 +760,9 @@ func Test(t GinkgoTestingT, config TestConfig) {
 // still be modified in a BeforeEach. The sanity package itself treats
 // it as read-only.
 func GinkgoTest(config *TestConfig) *TestContext {
+	if config.RandomSeed != 0 {
+		seedRandomness(config.RandomSeed)
+	}
 	sc := NewTestContext(config)
 	registerTestsInGinkgo(sc)
 	return sc
@@ -250,51 +771,46 @@ func GinkgoTest(config *TestConfig) *TestContext {
 // Setup must be invoked before each test. It initialize per-test
 // variables in the context.
 func (sc *TestContext) Setup() {
-	var err error
-
-	// Get StorageClass parameters from TestVolumeParametersFile
-	loadFromFile(sc.Config.TestVolumeParametersFile, &sc.Config.TestVolumeParameters)
+	// Get StorageClass parameters from TestVolumeParametersFile. With
+	// a list of parameter sets, the first one is used unless the
+	// test replaces it.
+	parameterSets, err := loadVolumeParameterSets(sc.Config.TestVolumeParametersFile)
+	Expect(err).NotTo(HaveOccurred())
+	if len(parameterSets) > 0 {
+		sc.Config.TestVolumeParameters = parameterSets[0]
+	} else {
+		loadFromFile(sc.Config.TestVolumeParametersFile, &sc.Config.TestVolumeParameters)
+	}
 	// Get VolumeSnapshotClass parameters from TestSnapshotParametersFile
 	loadFromFile(sc.Config.TestSnapshotParametersFile, &sc.Config.TestSnapshotParameters)
-
-	if len(sc.Config.SecretsFile) > 0 {
-		sc.Secrets, err = loadSecrets(sc.Config.SecretsFile)
-		Expect(err).NotTo(HaveOccurred())
-	} else {
-		sc.Secrets = &CSISecrets{}
+	// Get the capabilities for strict mode from RequiredCapabilitiesFile
+	if sc.Config.RequiredCapabilitiesFile != "" {
+		loadFromFile(sc.Config.RequiredCapabilitiesFile, &sc.Config.RequiredCapabilities)
+		sc.Config.StrictCapabilities = true
 	}
+	// Get additional gRPC metadata from MetadataFile
+	loadFromFile(sc.Config.MetadataFile, &sc.Config.Metadata)
+
+	sc.Secrets, err = sc.Config.LoadSecrets()
+	Expect(err).NotTo(HaveOccurred())
 
 	// It is possible that a test sets sc.Config.Address
 	// dynamically (and differently!) in a BeforeEach, so only
 	// reuse the connection if the address is still the same.
-	if sc.Conn == nil || sc.connAddress != sc.Config.Address {
-		if sc.Conn != nil {
-			sc.Conn.Close()
-		}
-		By("connecting to CSI driver")
-		sc.Conn, err = utils.Connect(sc.Config.Address, sc.Config.DialOptions...)
-		Expect(err).NotTo(HaveOccurred())
-		sc.connAddress = sc.Config.Address
-	} else {
+	if sc.Conn != nil && sc.connAddress == sc.Config.Address {
 		By(fmt.Sprintf("reusing connection to CSI driver at %s", sc.connAddress))
-	}
-
-	if sc.ControllerConn == nil || sc.controllerConnAddress != sc.Config.ControllerAddress {
-		// If controller address is empty, use the common connection.
-		if sc.Config.ControllerAddress == "" {
-			sc.ControllerConn = sc.Conn
-			sc.controllerConnAddress = sc.Config.Address
-		} else {
-			sc.ControllerConn, err = utils.Connect(sc.Config.ControllerAddress, sc.Config.ControllerDialOptions...)
-			Expect(err).NotTo(HaveOccurred())
-			sc.controllerConnAddress = sc.Config.ControllerAddress
-		}
 	} else {
-		By(fmt.Sprintf("reusing connection to CSI driver controller at %s", sc.controllerConnAddress))
+		By("connecting to CSI driver")
 	}
+	err = sc.Connect(context.Background())
+	Expect(err).NotTo(HaveOccurred())
 
 	By("creating mount and staging directories")
 
+	if sc.Config.SSH != nil && sc.Config.SSH.Host != "" {
+		sc.Config.SSH.install(sc.Config)
+	}
+
 	// If callback function for creating target dir is specified, use it.
 	targetPath, err := createMountTargetLocation(sc.Config.TargetPath, sc.Config.CreateTargetPathCmd, sc.Config.CreateTargetDir, sc.Config.CreatePathCmdTimeout)
 	Expect(err).NotTo(HaveOccurred(), "failed to create target directory %s", targetPath)
@@ -325,14 +841,131 @@ func (sc *TestContext) Teardown() {
 	// (https://github.com/kubernetes-csi/csi-test/pull/98).
 }
 
+// Connect establishes the connections to the CSI driver at the
+// configured addresses. Existing connections are reused if the
+// addresses have not changed. Setup calls Connect automatically,
+// calling it explicitly is only needed to control when connections
+// are made, for example after restarting the driver and calling
+// Close.
+func (sc *TestContext) Connect(ctx context.Context) error {
+	if sc.Conn == nil || sc.connAddress != sc.Config.Address {
+		if sc.Conn != nil {
+			sc.Close()
+		}
+		conn, err := utils.ConnectContext(ctx, sc.Config.Address, sc.dialOptions(sc.Config.DialOptions)...)
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return fmt.Errorf("connecting to CSI driver at %s: %v", sc.Config.Address, err)
+		}
+		sc.Conn = conn
+		sc.connAddress = sc.Config.Address
+	}
+
+	// If controller address is empty, use the common connection.
+	if sc.Config.ControllerAddress == "" {
+		if sc.ControllerConn != nil && sc.ControllerConn != sc.Conn {
+			sc.ControllerConn.Close()
+		}
+		sc.ControllerConn = sc.Conn
+		sc.controllerConnAddress = sc.Config.Address
+		return nil
+	}
+	if sc.ControllerConn == nil || sc.ControllerConn == sc.Conn || sc.controllerConnAddress != sc.Config.ControllerAddress {
+		if sc.ControllerConn != nil && sc.ControllerConn != sc.Conn {
+			sc.ControllerConn.Close()
+		}
+		sc.ControllerConn = nil
+		conn, err := utils.ConnectContext(ctx, sc.Config.ControllerAddress, sc.dialOptions(sc.Config.controllerDialOptions())...)
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return fmt.Errorf("connecting to CSI driver controller at %s: %v", sc.Config.ControllerAddress, err)
+		}
+		sc.ControllerConn = conn
+		sc.controllerConnAddress = sc.Config.ControllerAddress
+	}
+	return nil
+}
+
+// Close closes the connections to the CSI driver. The next call of
+// Connect or Setup connects again.
+func (sc *TestContext) Close() {
+	if sc.ControllerConn != nil && sc.ControllerConn != sc.Conn {
+		sc.ControllerConn.Close()
+	}
+	if sc.Conn != nil {
+		sc.Conn.Close()
+	}
+	sc.Conn = nil
+	sc.ControllerConn = nil
+	sc.connAddress = ""
+	sc.controllerConnAddress = ""
+}
+
+// controllerDialOptions returns the options for ControllerAddress.
+func (config *TestConfig) controllerDialOptions() []grpc.DialOption {
+	if len(config.ControllerDialOptions) == 0 {
+		return config.DialOptions
+	}
+	return config.ControllerDialOptions
+}
+
+// dialOptions returns the given options plus those that are needed
+// by the sanity package itself. The input slice is not modified.
+func (sc *TestContext) dialOptions(opts []grpc.DialOption) []grpc.DialOption {
+	result := append([]grpc.DialOption{}, opts...)
+	result = append(result, grpc.WithChainUnaryInterceptor(sc.interceptors()...))
+	if len(sc.Config.StreamInterceptors) > 0 {
+		result = append(result, grpc.WithChainStreamInterceptor(sc.Config.StreamInterceptors...))
+	}
+	return result
+}
+
+// interceptors returns the interceptors which the sanity package
+// adds to every gRPC call, in the order in which they get invoked.
+func (sc *TestContext) interceptors() []grpc.UnaryClientInterceptor {
+	interceptors := []grpc.UnaryClientInterceptor{sc.dryRunInterceptor, sc.results.interceptor, sc.provisioning.interceptor, sc.unimplementedInterceptor, sc.validationInterceptor, sc.retryInterceptor, sc.timeoutInterceptor, sc.recordInterceptor, sc.dumpInterceptor, sc.metadataInterceptor}
+	if len(sc.Config.UnaryInterceptors) > 0 {
+		interceptors = append(interceptors, sc.specContextInterceptor)
+		interceptors = append(interceptors, sc.Config.UnaryInterceptors...)
+	}
+	return interceptors
+}
+
+// Results returns the results collected so far. Spec outcomes are
+// only recorded when running the suite with Test.
+func (sc *TestContext) Results() *Results {
+	results := sc.results.results()
+	results.ProvisionedBytes, results.PeakProvisionedBytes = sc.provisioning.provisioned()
+	if sc.environment == nil {
+		env := collectEnvironment(sc.Config.SuiteVersion)
+		sc.environment = &env
+	}
+	results.Environment = *sc.environment
+	results.RandomSeed = sc.Config.RandomSeed
+	results.LeakedVolumes, results.LeakedSnapshots = sc.leakedVolumes, sc.leakedSnapshots
+	if sc.suiteChecksFailed {
+		results.Succeeded = false
+	}
+	return results
+}
+
 // Finalize frees any resources that might be still cached in the context.
 // It should be called after running all tests.
 func (sc *TestContext) Finalize() {
-	if sc.Conn != nil {
-		sc.Conn.Close()
+	if sc.paused != nil {
+		sc.cleanupOutsideSpec(sc.paused, "deleting the volume kept across pauses failed")
+		sc.paused = nil
+		sc.pausedVolume = nil
 	}
-	if sc.ControllerConn != nil {
-		sc.ControllerConn.Close()
+	sc.Close()
+	sc.recorder.close()
+	if sc.callbacks != nil {
+		sc.callbacks.stop()
+		sc.callbacks = nil
 	}
 }
 
@@ -410,7 +1043,82 @@ func removeMountTargetLocation(targetPath string, removePathCmd string, customRe
 	return nil
 }
 
-func loadSecrets(path string) (*CSISecrets, error) {
+// runQuiesceCmd executes a quiesce or unquiesce command for the volume
+// published at the given path. Empty commands are ignored.
+func runQuiesceCmd(quiesceCmd string, volumePath string, timeout time.Duration) error {
+	if quiesceCmd == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, quiesceCmd, volumePath)
+	cmd.Stderr = os.Stderr
+	if _, err := cmd.Output(); err != nil {
+		return fmt.Errorf("quiesce command %s failed: %v", quiesceCmd, err)
+	}
+	return nil
+}
+
+// dataAccessConfigured returns true if data can be written into and
+// read from published volumes.
+func dataAccessConfigured(config *TestConfig) bool {
+	canWrite := config.WriteDataCmd != "" || config.WriteData != nil
+	canRead := config.ReadDataCmd != "" || config.ReadData != nil
+	return canWrite && canRead
+}
+
+// writeData writes the data into the volume published at the given
+// path using the write command or the WriteData callback.
+func writeData(config *TestConfig, volumePath string, data []byte) error {
+	if config.WriteDataCmd != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), config.DataCmdTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, config.WriteDataCmd, volumePath)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stderr = os.Stderr
+		if _, err := cmd.Output(); err != nil {
+			return fmt.Errorf("write data command %s failed: %v", config.WriteDataCmd, err)
+		}
+		return nil
+	}
+	return config.WriteData(volumePath, data)
+}
+
+// readData reads the data from the volume published at the given
+// path using the read command or the ReadData callback.
+func readData(config *TestConfig, volumePath string) ([]byte, error) {
+	if config.ReadDataCmd != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), config.DataCmdTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, config.ReadDataCmd, volumePath)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("read data command %s failed: %v", config.ReadDataCmd, err)
+		}
+		return out, nil
+	}
+	return config.ReadData(volumePath)
+}
+
+// LoadSecrets returns the secrets from SecretsFile if set, otherwise
+// Secrets or, if that is nil, empty secrets. Test uses the same
+// secrets; calls outside of Test like CleanupRun can get them here.
+func (config *TestConfig) LoadSecrets() (*CSISecrets, error) {
+	if len(config.SecretsFile) > 0 {
+		return loadSecrets(config.SecretsFile, config.ExpandSecrets, config.SecretsTemplateFuncs)
+	}
+	if config.Secrets != nil {
+		return config.Secrets, nil
+	}
+	return &CSISecrets{}, nil
+}
+
+func loadSecrets(path string, expand bool, funcs template.FuncMap) (*CSISecrets, error) {
 	var creds CSISecrets
 
 	yamlFile, err := ioutil.ReadFile(path)
@@ -423,6 +1131,12 @@ func loadSecrets(path string) (*CSISecrets, error) {
 		return &creds, fmt.Errorf("error unmarshaling yaml: #%v", err)
 	}
 
+	if expand {
+		if err := expandSecrets(&creds, funcs); err != nil {
+			return &creds, fmt.Errorf("failed to expand secrets in %q: %v", path, err)
+		}
+	}
+
 	return &creds, nil
 }
 
@@ -440,6 +1154,36 @@ func loadFromFile(from string, to interface{}) {
 	}
 }
 
+// loadVolumeParameterSets returns the entries of a
+// TestVolumeParametersFile which contains a list of parameter maps,
+// nil if the file is not set or contains a single map.
+func loadVolumeParameterSets(path string) ([]map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %q: %v", path, err)
+	}
+	var content interface{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("error unmarshaling yaml from %q: %v", path, err)
+	}
+	if _, ok := content.([]interface{}); !ok {
+		return nil, nil
+	}
+	var sets []map[string]string
+	if err := yaml.Unmarshal(data, &sets); err != nil {
+		return nil, fmt.Errorf("error unmarshaling yaml from %q: %v", path, err)
+	}
+	for i := range sets {
+		if sets[i] == nil {
+			sets[i] = map[string]string{}
+		}
+	}
+	return sets, nil
+}
+
 var uniqueSuffix = "-" + PseudoUUID()
 
 // PseudoUUID returns a unique string generated from random
@@ -460,6 +1204,19 @@ func UniqueString(prefix string) string {
 	return prefix + uniqueSuffix
 }
 
+// uniqueNameOfLength is like UniqueString, but fills the name up to
+// exactly length bytes with repetitions of the filler between prefix
+// and suffix. Bytes that are left because a multi-byte filler does
+// not fit are filled with "-".
+func uniqueNameOfLength(length int, prefix, filler string) string {
+	fill := length - len(prefix) - len(uniqueSuffix)
+	if fill < 0 {
+		fill = 0
+	}
+	count := fill / len(filler)
+	return prefix + strings.Repeat(filler, count) + strings.Repeat("-", fill-count*len(filler)) + uniqueSuffix
+}
+
 // Return codes for CheckPath
 type PathKind string
 
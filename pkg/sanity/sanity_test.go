@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestUniqueNameOfLength(t *testing.T) {
+	for name, tc := range map[string]struct {
+		length         int
+		prefix, filler string
+	}{
+		"ascii":         {MaxNameLength, "sanity-", "a"},
+		"two bytes":     {MaxNameLength, "sanity-", "ü"},
+		"odd remainder": {MaxNameLength, "sanity-x", "ü"},
+		"three bytes":   {MaxNameLength, "sanity-", "名"},
+		"shorter":       {64, "sanity-", "a"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			name := uniqueNameOfLength(tc.length, tc.prefix, tc.filler)
+			if len(name) != tc.length {
+				t.Errorf("expected %d bytes, got %d: %q", tc.length, len(name), name)
+			}
+			if !utf8.ValidString(name) {
+				t.Errorf("invalid UTF-8: %q", name)
+			}
+			if !strings.HasPrefix(name, tc.prefix) || !strings.HasSuffix(name, uniqueSuffix) {
+				t.Errorf("expected prefix %q and suffix %q: %q", tc.prefix, uniqueSuffix, name)
+			}
+			if !strings.Contains(name, tc.filler) {
+				t.Errorf("expected filler %q: %q", tc.filler, name)
+			}
+		})
+	}
+}
+
+func TestConfigSecrets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secrets.yaml")
+	if err := ioutil.WriteFile(file, []byte("CreateVolumeSecret:\n  key: from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fromConfig := &CSISecrets{CreateVolumeSecret: map[string]string{"key": "from-config"}}
+
+	for name, tc := range map[string]struct {
+		config   TestConfig
+		expected string
+	}{
+		"none":         {TestConfig{}, ""},
+		"config":       {TestConfig{Secrets: fromConfig}, "from-config"},
+		"file":         {TestConfig{SecretsFile: file}, "from-file"},
+		"file wins":    {TestConfig{SecretsFile: file, Secrets: fromConfig}, "from-file"},
+		"missing file": {TestConfig{SecretsFile: file + ".missing", Secrets: fromConfig}, "error"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			secrets, err := tc.config.LoadSecrets()
+			if tc.expected == "error" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if secrets == nil {
+				t.Fatal("expected secrets, got nil")
+			}
+			if actual := secrets.CreateVolumeSecret["key"]; actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInvalidTestSelection(t *testing.T) {
+	config := NewTestConfig()
+	config.Address = filepath.Join(t.TempDir(), "no-driver.sock")
+	config.LeakCheck = LeakCheckFail
+	config.FocusTests = []string{"[id:createvolume/"}
+	config.Logger = NewWriterLogger(ioutil.Discard, 0)
+	ft := &fakeTestingT{}
+
+	// Without the driver, listing the resources for the leak check
+	// would block until the connection times out.
+	results := Test(ft, config)
+	if !ft.failed || results.Succeeded {
+		t.Error("an invalid regular expression must fail the test")
+	}
+	if len(results.Specs) != 0 {
+		t.Errorf("expected no specs, got %d", len(results.Specs))
+	}
+}
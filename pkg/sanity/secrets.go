@@ -0,0 +1,236 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// expectSecretsRejected checks that a call with bad secrets failed
+// because of those secrets.
+func expectSecretsRejected(err error, method string) {
+	ExpectWithOffset(1, err).To(HaveOccurred(), "%s succeeded with bad secrets", method)
+	serverError, ok := status.FromError(err)
+	ExpectWithOffset(1, ok).To(BeTrue())
+	ExpectWithOffset(1, serverError.Code()).To(Or(Equal(codes.Unauthenticated), Equal(codes.PermissionDenied)),
+		"unexpected error for %s with bad secrets: %s", method, serverError.Message())
+}
+
+// skipWithoutBadSecret skips the current test if the bad secrets file
+// has no entry for the method.
+func skipWithoutBadSecret(secret map[string]string, method string) {
+	if len(secret) == 0 {
+		Skip(fmt.Sprintf("Config.BadSecretsFile has no secret for %s", method))
+	}
+}
+
+var _ = DescribeSanity("Bad Secrets", func(sc *TestContext) {
+	var (
+		r          *Resources
+		badSecrets *CSISecrets
+
+		providesControllerService bool
+	)
+
+	BeforeEach(func() {
+		if sc.Config.BadSecretsFile == "" {
+			Skip("Config.BadSecretsFile not set")
+		}
+		var err error
+		badSecrets, err = loadSecrets(sc.Config.BadSecretsFile, sc.Config.ExpandSecrets, sc.Config.SecretsTemplateFuncs)
+		Expect(err).NotTo(HaveOccurred())
+
+		r = &Resources{
+			Context:          sc,
+			ControllerClient: csi.NewControllerClient(sc.ControllerConn),
+			NodeClient:       csi.NewNodeClient(sc.Conn),
+		}
+		providesControllerService = isPluginCapabilitySupported(csi.NewIdentityClient(sc.Conn), csi.PluginCapability_Service_CONTROLLER_SERVICE)
+	})
+
+	AfterEach(func() {
+		if r != nil {
+			r.Cleanup()
+		}
+	})
+
+	// requireController skips the current test unless the driver
+	// supports the controller capability.
+	requireController := func(capType csi.ControllerServiceCapability_RPC_Type) {
+		if !providesControllerService {
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided")
+		}
+		if !isControllerCapabilitySupported(r, capType) {
+			skipUnsupported(sc, ControllerCapability(capType), fmt.Sprintf("%s not supported", capType))
+		}
+	}
+
+	// prepareNodeVolume creates a volume and controller publishes it
+	// if supported, as needed before calling the node service.
+	prepareNodeVolume := func(name string) (*csi.Volume, map[string]string) {
+		requireController(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME)
+
+		By("creating a volume")
+		vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, name))
+
+		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME) {
+			return vol.GetVolume(), nil
+		}
+
+		By("getting a node id")
+		nid, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("controller publishing the volume")
+		conpubvol := r.MustControllerPublishVolume(
+			context.Background(),
+			MakeControllerPublishVolumeReq(sc, vol.GetVolume().GetVolumeId(), nid.GetNodeId()),
+		)
+		return vol.GetVolume(), conpubvol.GetPublishContext()
+	}
+
+	It(SpecID("secrets/create-volume", "should reject CreateVolume with bad secrets"), func() {
+		skipWithoutBadSecret(badSecrets.CreateVolumeSecret, "CreateVolume")
+		requireController(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME)
+
+		req := MakeCreateVolumeReq(sc, UniqueString("sanity-bad-secrets-create"))
+		req.Secrets = badSecrets.CreateVolumeSecret
+		_, err := r.CreateVolume(context.Background(), req)
+		expectSecretsRejected(err, "CreateVolume")
+	})
+
+	It(SpecID("secrets/delete-volume", "should reject DeleteVolume with bad secrets"), func() {
+		skipWithoutBadSecret(badSecrets.DeleteVolumeSecret, "DeleteVolume")
+		requireController(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME)
+
+		By("creating a volume")
+		vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-bad-secrets-delete")))
+
+		By("deleting the volume with bad secrets")
+		req := MakeDeleteVolumeReq(sc, vol.GetVolume().GetVolumeId())
+		req.Secrets = badSecrets.DeleteVolumeSecret
+		_, err := r.DeleteVolume(context.Background(), req)
+		expectSecretsRejected(err, "DeleteVolume")
+	})
+
+	It(SpecID("secrets/controller-publish-volume", "should reject ControllerPublishVolume with bad secrets"), func() {
+		skipWithoutBadSecret(badSecrets.ControllerPublishVolumeSecret, "ControllerPublishVolume")
+		requireController(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME)
+		requireController(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME)
+
+		By("creating a volume")
+		vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-bad-secrets-publish")))
+
+		By("getting a node id")
+		nid, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("controller publishing the volume with bad secrets")
+		req := MakeControllerPublishVolumeReq(sc, vol.GetVolume().GetVolumeId(), nid.GetNodeId())
+		req.Secrets = badSecrets.ControllerPublishVolumeSecret
+		_, err = r.ControllerPublishVolume(context.Background(), req)
+		expectSecretsRejected(err, "ControllerPublishVolume")
+	})
+
+	It(SpecID("secrets/create-snapshot", "should reject CreateSnapshot with bad secrets"), func() {
+		skipWithoutBadSecret(badSecrets.CreateSnapshotSecret, "CreateSnapshot")
+		requireController(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME)
+		requireController(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT)
+
+		By("creating a volume")
+		vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-bad-secrets-snapshot-source")))
+
+		By("creating a snapshot with bad secrets")
+		req := MakeCreateSnapshotReq(sc, UniqueString("sanity-bad-secrets-snapshot"), vol.GetVolume().GetVolumeId())
+		req.Secrets = badSecrets.CreateSnapshotSecret
+		_, err := r.CreateSnapshot(context.Background(), req)
+		expectSecretsRejected(err, "CreateSnapshot")
+	})
+
+	It(SpecID("secrets/node-stage-volume", "should reject NodeStageVolume with bad secrets"), func() {
+		skipWithoutBadSecret(badSecrets.NodeStageVolumeSecret, "NodeStageVolume")
+		if !isNodeCapabilitySupported(r, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME) {
+			skipUnsupported(sc, NodeCapability(csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME), "NodeStageVolume not supported")
+		}
+
+		vol, publishContext := prepareNodeVolume(UniqueString("sanity-bad-secrets-stage"))
+
+		By("node staging the volume with bad secrets")
+		_, err := r.NodeStageVolume(
+			context.Background(),
+			&csi.NodeStageVolumeRequest{
+				VolumeId:          vol.GetVolumeId(),
+				VolumeCapability:  TestVolumeCapability(sc),
+				StagingTargetPath: sc.StagingPath,
+				VolumeContext:     vol.GetVolumeContext(),
+				PublishContext:    publishContext,
+				Secrets:           badSecrets.NodeStageVolumeSecret,
+			},
+		)
+		expectSecretsRejected(err, "NodeStageVolume")
+	})
+
+	It(SpecID("secrets/node-publish-volume", "should reject NodePublishVolume with bad secrets"), func() {
+		skipWithoutBadSecret(badSecrets.NodePublishVolumeSecret, "NodePublishVolume")
+		nodeStageSupported := isNodeCapabilitySupported(r, csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME)
+
+		vol, publishContext := prepareNodeVolume(UniqueString("sanity-bad-secrets-node-publish"))
+		volCap := TestVolumeCapability(sc)
+
+		var stagingPath string
+		if nodeStageSupported {
+			By("node staging the volume")
+			stagingPath = sc.StagingPath
+			_, err := r.NodeStageVolume(
+				context.Background(),
+				&csi.NodeStageVolumeRequest{
+					VolumeId:          vol.GetVolumeId(),
+					VolumeCapability:  volCap,
+					StagingTargetPath: stagingPath,
+					VolumeContext:     vol.GetVolumeContext(),
+					PublishContext:    publishContext,
+					Secrets:           sc.Secrets.NodeStageVolumeSecret,
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("publishing the volume with bad secrets")
+		_, err := r.NodePublishVolume(
+			context.Background(),
+			&csi.NodePublishVolumeRequest{
+				VolumeId:          vol.GetVolumeId(),
+				TargetPath:        filepath.Join(sc.TargetPath, "target"),
+				StagingTargetPath: stagingPath,
+				VolumeCapability:  volCap,
+				VolumeContext:     vol.GetVolumeContext(),
+				PublishContext:    publishContext,
+				Secrets:           badSecrets.NodePublishVolumeSecret,
+			},
+		)
+		expectSecretsRejected(err, "NodePublishVolume")
+	})
+})
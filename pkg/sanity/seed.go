@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// seededIDs is the source for the IDs of DefaultIDGenerator after
+// seedRandomness, nil otherwise. It is separate from the global
+// source of the uuid package, which other code in the process may
+// rely on.
+var seededIDs *lockedReader
+
+// lockedReader makes a math/rand source safe for concurrent use.
+type lockedReader struct {
+	mutex sync.Mutex
+	rng   *rand.Rand
+}
+
+func (r *lockedReader) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rng.Read(p)
+}
+
+// seedRandomness derives the suffix of UniqueString and the IDs of
+// DefaultIDGenerator from the seed instead of crypto/rand, so that a
+// run can be repeated with the same names and IDs.
+func seedRandomness(seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	b := make([]byte, 8)
+	rng.Read(b)
+	uniqueSuffix = fmt.Sprintf("-%08X-%08X", b[0:4], b[4:8])
+	seededIDs = &lockedReader{rng: rng}
+}
+
+// newUUID returns a random UUID from the seeded source, if there is
+// one.
+func newUUID() string {
+	if seededIDs == nil {
+		return uuid.New().String()
+	}
+	return uuid.Must(uuid.NewRandomFromReader(seededIDs)).String()
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSeedRandomness(t *testing.T) {
+	defer func(suffix string, ids *lockedReader) {
+		uniqueSuffix, seededIDs = suffix, ids
+	}(uniqueSuffix, seededIDs)
+
+	run := func(seed int64) (suffix, volumeID, nodeID, globalUUID string) {
+		seedRandomness(seed)
+		var gen DefaultIDGenerator
+		return uniqueSuffix, gen.GenerateUniqueValidVolumeID(), gen.GenerateUniqueValidNodeID(), uuid.New().String()
+	}
+	suffix1, volume1, node1, global1 := run(42)
+	suffix2, volume2, node2, global2 := run(42)
+	suffix3, volume3, _, _ := run(43)
+
+	if suffix1 != suffix2 || volume1 != volume2 || node1 != node2 {
+		t.Errorf("same seed, different names: %q/%q/%q and %q/%q/%q", suffix1, volume1, node1, suffix2, volume2, node2)
+	}
+	if suffix1 == suffix3 || volume1 == volume3 {
+		t.Errorf("different seeds, same names: %q/%q", suffix1, volume1)
+	}
+	if global1 == global2 {
+		t.Errorf("the seed must not affect the uuid package: %q", global1)
+	}
+}
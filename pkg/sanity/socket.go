@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/kubernetes-csi/csi-test/v4/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// socketPath returns the path of the unix domain socket for a gRPC
+// endpoint, using the same rules as utils.Connect, or an empty string
+// for other kinds of endpoints.
+func socketPath(address string) string {
+	if network, path := utils.SocketPath(address); network == "unix" {
+		return path
+	}
+	return ""
+}
+
+var _ = describeFundamental("Endpoint", func(sc *TestContext) {
+	It(SpecID("endpoint/socket-mode", "should have the expected socket permissions"), func() {
+		if runtime.GOOS == "windows" {
+			Skip("socket permissions are not supported on Windows")
+		}
+		addresses := []string{sc.Config.Address}
+		if sc.Config.ControllerAddress != "" && sc.Config.ControllerAddress != sc.Config.Address {
+			addresses = append(addresses, sc.Config.ControllerAddress)
+		}
+
+		checked := 0
+		for _, address := range addresses {
+			path := socketPath(address)
+			if path == "" {
+				continue
+			}
+
+			info, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred(), "checking socket of %s", address)
+			Expect(info.Mode()&os.ModeSocket).NotTo(BeZero(), "%s is not a unix domain socket", path)
+			mode := info.Mode().Perm()
+			sc.results.addSocket(SocketInfo{Path: path, Mode: fmt.Sprintf("%#o", mode)})
+			checked++
+
+			if sc.Config.ExpectedSocketMode == 0 {
+				By(fmt.Sprintf("socket %s has mode %#o", path, mode))
+				continue
+			}
+			By(fmt.Sprintf("verifying mode of socket %s", path))
+			Expect(mode).To(Equal(sc.Config.ExpectedSocketMode.Perm()),
+				"socket %s has mode %#o, expected %#o", path, mode, sc.Config.ExpectedSocketMode.Perm())
+		}
+
+		if checked == 0 {
+			Skip("driver is not listening on a unix domain socket")
+		}
+		if sc.Config.ExpectedSocketMode == 0 {
+			Skip("Config.ExpectedSocketMode not set")
+		}
+	})
+})
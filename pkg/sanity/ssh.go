@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSHConfig describes how to reach the node on which the node
+// plugin runs, for testing a driver through a TCP endpoint from
+// another host. The target and staging paths are then created,
+// removed and checked on that node with the ssh command line tool,
+// which must be able to log in without a password.
+type SSHConfig struct {
+	// Host is the name or address of the node.
+	Host string
+	// Port defaults to the port configured for ssh, usually 22.
+	Port int
+	// User defaults to the user configured for ssh.
+	User string
+	// KeyFile is the private key for logging in.
+	KeyFile string
+	// KnownHostsFile replaces the default known_hosts file.
+	KnownHostsFile string
+	// Command is the ssh binary, "ssh" if empty.
+	Command string
+	// Options are additional -o options, for example
+	// "StrictHostKeyChecking=accept-new".
+	Options []string
+}
+
+// install sets the path callbacks of the config which are not set
+// yet to the remote implementations.
+func (s *SSHConfig) install(config *TestConfig) {
+	if config.CreateTargetDir == nil {
+		config.CreateTargetDir = func(path string) (string, error) {
+			return path, s.run(config.CreatePathCmdTimeout, "mkdir -- "+shellQuote(path), nil)
+		}
+	}
+	if config.CreateStagingDir == nil {
+		config.CreateStagingDir = config.CreateTargetDir
+	}
+	if config.RemoveTargetPath == nil {
+		config.RemoveTargetPath = func(path string) error {
+			return s.run(config.RemovePathCmdTimeout, "rmdir -- "+shellQuote(path), nil)
+		}
+	}
+	if config.RemoveStagingPath == nil {
+		config.RemoveStagingPath = config.RemoveTargetPath
+	}
+	if config.CheckPath == nil {
+		config.CheckPath = func(path string) (PathKind, error) {
+			p := shellQuote(path)
+			var out bytes.Buffer
+			script := fmt.Sprintf("if [ -f %s ]; then echo %s; elif [ -d %s ]; then echo %s; elif [ -e %s ]; then echo %s; else echo %s; fi",
+				p, PathIsFile, p, PathIsDir, p, PathIsOther, PathIsNotFound)
+			if err := s.run(config.CheckPathCmdTimeout, script, &out); err != nil {
+				return "", err
+			}
+			return IsPathKind(strings.TrimSpace(out.String()))
+		}
+	}
+}
+
+// args returns the ssh arguments for running the command remotely.
+func (s *SSHConfig) args(command string) []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if s.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(s.Port))
+	}
+	if s.User != "" {
+		args = append(args, "-l", s.User)
+	}
+	if s.KeyFile != "" {
+		args = append(args, "-i", s.KeyFile)
+	}
+	if s.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+s.KnownHostsFile)
+	}
+	for _, option := range s.Options {
+		args = append(args, "-o", option)
+	}
+	return append(args, s.Host, command)
+}
+
+// run executes the shell command on the node and stores its output
+// in stdout, if not nil.
+func (s *SSHConfig) run(timeout time.Duration, command string, stdout *bytes.Buffer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	name := s.Command
+	if name == "" {
+		name = "ssh"
+	}
+	cmd := exec.CommandContext(ctx, name, s.args(command)...)
+	var stderr bytes.Buffer
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s on %s failed: %v: %s", command, s.Host, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// shellQuote returns s as a single-quoted POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"google.golang.org/grpc"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// Option modifies the configuration of a TestSuite.
+type Option func(*TestConfig)
+
+// TestSuite is an alternative to calling Test with a TestConfig:
+// the configuration starts with the defaults from NewTestConfig and
+// is then modified by the options.
+type TestSuite struct {
+	config TestConfig
+}
+
+// NewTestSuite returns a suite with the default configuration and
+// the given options applied in order.
+func NewTestSuite(opts ...Option) *TestSuite {
+	s := &TestSuite{config: NewTestConfig()}
+	for _, opt := range opts {
+		opt(&s.config)
+	}
+	return s
+}
+
+// Config returns a copy of the configuration of the suite.
+func (s *TestSuite) Config() TestConfig {
+	return s.config
+}
+
+// Run runs the tests like Test does.
+func (s *TestSuite) Run(t GinkgoTestingT) *Results {
+	return Test(t, s.config)
+}
+
+// WithAddress sets the endpoint of the driver and, optionally, a
+// separate endpoint for the controller service.
+func WithAddress(address string, controllerAddress ...string) Option {
+	return func(config *TestConfig) {
+		config.Address = address
+		if len(controllerAddress) > 0 {
+			config.ControllerAddress = controllerAddress[0]
+		}
+	}
+}
+
+// WithDialOptions replaces the dial options for both Address and
+// ControllerAddress.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(config *TestConfig) {
+		config.DialOptions = opts
+		config.ControllerDialOptions = opts
+	}
+}
+
+// WithSecrets sets the secrets which are used for calls to the
+// driver instead of loading them from a file.
+func WithSecrets(secrets *CSISecrets) Option {
+	return func(config *TestConfig) {
+		config.Secrets = secrets
+		config.SecretsFile = ""
+	}
+}
+
+// WithVolumeSize sets the size of the volumes created by the tests
+// and, if non-zero, the size that they get expanded to.
+func WithVolumeSize(size, expandSize int64) Option {
+	return func(config *TestConfig) {
+		config.TestVolumeSize = size
+		if expandSize != 0 {
+			config.TestVolumeExpandSize = expandSize
+		}
+	}
+}
+
+// WithVolumeParameters sets the parameters for CreateVolume.
+func WithVolumeParameters(parameters map[string]string) Option {
+	return func(config *TestConfig) {
+		config.TestVolumeParameters = parameters
+		config.TestVolumeParametersFile = ""
+	}
+}
+
+// WithSnapshotParameters sets the parameters for CreateSnapshot.
+func WithSnapshotParameters(parameters map[string]string) Option {
+	return func(config *TestConfig) {
+		config.TestSnapshotParameters = parameters
+		config.TestSnapshotParametersFile = ""
+	}
+}
+
+// WithPaths sets the parent directory for target paths and the
+// staging path.
+func WithPaths(targetPath, stagingPath string) Option {
+	return func(config *TestConfig) {
+		config.TargetPath = targetPath
+		config.StagingPath = stagingPath
+	}
+}
+
+// WithJUnitFile enables writing a JUnit report.
+func WithJUnitFile(path string) Option {
+	return func(config *TestConfig) {
+		config.JUnitFile = path
+	}
+}
+
+// WithConfig applies an arbitrary modification, for settings which
+// have no dedicated option.
+func WithConfig(modify func(config *TestConfig)) Option {
+	return Option(modify)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+// TAPReport formats the results in the Test Anything Protocol,
+// version 13. Skipped specs get a SKIP directive, pending ones a TODO
+// directive, and failures are described in a YAML block.
+func (r *Results) TAPReport() string {
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%d\n", len(r.Specs))
+	for i, spec := range r.Specs {
+		name := spec.Name
+		if spec.Area != "" && !strings.HasPrefix(name, spec.Area) {
+			name = spec.Area + " " + name
+		}
+		// "#" would start a directive.
+		name = strings.ReplaceAll(name, "#", "\\#")
+		switch spec.State {
+		case SpecPassed:
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, name)
+		case SpecSkipped:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP %s\n", i+1, name, firstLineOf(spec.Failure))
+		case SpecPending:
+			fmt.Fprintf(&b, "not ok %d - %s # TODO pending\n", i+1, name)
+		default:
+			fmt.Fprintf(&b, "not ok %d - %s\n", i+1, name)
+			b.WriteString("  ---\n")
+			fmt.Fprintf(&b, "  duration_ms: %d\n", spec.Duration.Milliseconds())
+			if spec.ID != "" {
+				fmt.Fprintf(&b, "  id: %s\n", spec.ID)
+			}
+			if spec.LastErrorCode != "" {
+				fmt.Fprintf(&b, "  grpc_method: %s\n", spec.LastErrorMethod)
+				fmt.Fprintf(&b, "  grpc_code: %s\n", spec.LastErrorCode)
+			}
+			b.WriteString("  message: |\n")
+			for _, line := range strings.Split(strings.TrimRight(spec.Failure, "\n"), "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+			b.WriteString("  ...\n")
+		}
+	}
+	return b.String()
+}
+
+// tapReporter writes TestConfig.TAPFile at the end of the suite.
+// The whole file is written at once, because the number of specs
+// is only known when retries are done.
+type tapReporter struct {
+	sc   *TestContext
+	path string
+}
+
+func (tr *tapReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (tr *tapReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (tr *tapReporter) SpecWillRun(specSummary *types.SpecSummary) {}
+
+func (tr *tapReporter) SpecDidComplete(specSummary *types.SpecSummary) {}
+
+func (tr *tapReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (tr *tapReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	if err := ioutil.WriteFile(tr.path, []byte(tr.sc.Results().TAPReport()), 0644); err != nil {
+		tr.sc.Config.logger().Error(err, "writing TAP report failed", "path", tr.path)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// envVarRE matches ${VAR} and the escaped $$.
+var envVarRE = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// secretsTemplateFuncs are available in all secrets files.
+var secretsTemplateFuncs = template.FuncMap{
+	// env returns the value of an environment variable, an
+	// empty string if it is not set.
+	"env": os.Getenv,
+	// file returns the content of a file without leading and
+	// trailing white space.
+	"file": func(path string) (string, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	},
+	"b64enc": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"b64dec": func(s string) (string, error) {
+		data, err := base64.StdEncoding.DecodeString(s)
+		return string(data), err
+	},
+}
+
+// expandSecrets expands all values of the secrets with
+// expandSecret.
+func expandSecrets(secrets *CSISecrets, funcs template.FuncMap) error {
+	fields := reflect.ValueOf(secrets).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		values := fields.Field(i).Interface().(map[string]string)
+		for key, value := range values {
+			expanded, err := expandSecret(value, funcs)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", fields.Type().Field(i).Name, key, err)
+			}
+			values[key] = expanded
+		}
+	}
+	return nil
+}
+
+// expandSecret replaces ${VAR} with the value of the environment
+// variable VAR, which must be set, and $$ with $. The value is
+// executed as a text/template with secretsTemplateFuncs and the
+// additional functions, so {{ file "/var/run/secrets/token" }} reads
+// a mounted secret and {{ "{{" }} produces a literal {{. The values
+// of environment variables and the output of functions are inserted
+// as they are, without expanding them again.
+func expandSecret(value string, funcs template.FuncMap) (string, error) {
+	// ${VAR} in the text outside of actions becomes an action
+	// which prints the value as a quoted string constant.
+	var source strings.Builder
+	var missing []string
+	rest := value
+	for rest != "" {
+		text := rest
+		action := ""
+		if start := strings.Index(rest, "{{"); start >= 0 {
+			text = rest[:start]
+			action = rest[start:]
+			if end := strings.Index(action, "}}"); end >= 0 {
+				action = action[:end+2]
+			}
+		}
+		source.WriteString(envVarRE.ReplaceAllStringFunc(text, func(match string) string {
+			if match == "$$" {
+				return "$"
+			}
+			name := envVarRE.FindStringSubmatch(match)[1]
+			envValue, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return "{{" + strconv.Quote(envValue) + "}}"
+		}))
+		source.WriteString(action)
+		rest = rest[len(text)+len(action):]
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+
+	tmpl, err := template.New("secret").Option("missingkey=error").Funcs(secretsTemplateFuncs).Funcs(funcs).Parse(source.String())
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, nil); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
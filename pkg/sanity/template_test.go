@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// setenv sets the environment variable and restores the original
+// state when the test is done. t.Setenv is not available in Go 1.16.
+func setenv(t *testing.T, key, value string) {
+	original, set := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if set {
+			os.Setenv(key, original)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandSecret(t *testing.T) {
+	setenv(t, "SANITY_TOKEN", "s3cr3t")
+	setenv(t, "SANITY_TEMPLATE", `{{ file "/etc/passwd" }}`)
+	setenv(t, "SANITY_DOLLAR", "${SANITY_TOKEN}")
+	file := filepath.Join(t.TempDir(), "password")
+	if err := ioutil.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		value    string
+		expected string
+		err      string
+	}{
+		"plain":              {value: "abc", expected: "abc"},
+		"env":                {value: "token-${SANITY_TOKEN}", expected: "token-s3cr3t"},
+		"env function":       {value: `{{ env "SANITY_TOKEN" }}`, expected: "s3cr3t"},
+		"file":               {value: `{{ file "` + file + `" }}`, expected: "from-file"},
+		"custom function":    {value: `{{ upper "abc" }}`, expected: "ABC"},
+		"escaped dollar":     {value: "$${SANITY_TOKEN}", expected: "${SANITY_TOKEN}"},
+		"lone dollar":        {value: "a$b", expected: "a$b"},
+		"literal braces":     {value: `{{ "{{" }}x}}`, expected: "{{x}}"},
+		"env not executed":   {value: "${SANITY_TEMPLATE}", expected: `{{ file "/etc/passwd" }}`},
+		"env not expanded":   {value: "${SANITY_DOLLAR}", expected: "${SANITY_TOKEN}"},
+		"env in action":      {value: `{{ "${SANITY_TOKEN}" }}`, expected: "${SANITY_TOKEN}"},
+		"quotes":             {value: `"${SANITY_TOKEN}"`, expected: `"s3cr3t"`},
+		"missing env":        {value: "${SANITY_MISSING_1}${SANITY_MISSING_2}", err: "SANITY_MISSING_1, SANITY_MISSING_2"},
+		"unknown function":   {value: `{{ nosuchfunc }}`, err: "nosuchfunc"},
+		"unterminated":       {value: `{{ env "SANITY_TOKEN"`, err: "unclosed action"},
+		"missing file":       {value: `{{ file "/does/not/exist" }}`, err: "no such file"},
+		"b64 round trip":     {value: `{{ b64enc "abc" | b64dec }}`, expected: "abc"},
+		"invalid b64":        {value: `{{ b64dec "%%" }}`, err: "illegal base64"},
+		"single braces kept": {value: "{x}", expected: "{x}"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actual, err := expandSecret(tc.value, template.FuncMap{"upper": strings.ToUpper})
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLoadSecretsExpansion(t *testing.T) {
+	// The value of the environment variable would break the YAML
+	// file if it was inserted before parsing.
+	setenv(t, "SANITY_TOKEN", "a: b\n- \"c\" # d")
+	file := filepath.Join(t.TempDir(), "secrets.yaml")
+	content := `CreateVolumeSecret:
+  token: "${SANITY_TOKEN}"
+  literal: '{{ "{{" }}'
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		expand   bool
+		expected map[string]string
+	}{
+		"disabled": {false, map[string]string{"token": "${SANITY_TOKEN}", "literal": `{{ "{{" }}`}},
+		"enabled":  {true, map[string]string{"token": "a: b\n- \"c\" # d", "literal": "{{"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := TestConfig{SecretsFile: file, ExpandSecrets: tc.expand}
+			secrets, err := config.LoadSecrets()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(secrets.CreateVolumeSecret, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, secrets.CreateVolumeSecret)
+			}
+		})
+	}
+}
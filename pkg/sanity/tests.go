@@ -17,14 +17,34 @@ limitations under the License.
 package sanity
 
 import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
 	. "github.com/onsi/ginkgo"
 )
 
 type test struct {
-	text string
-	body func(*TestContext)
+	text   string
+	weight int
+	body   func(*TestContext)
+	// parameterized blocks are registered once per entry in a
+	// TestVolumeParametersFile which contains a list.
+	parameterized bool
 }
 
+// Blocks with a lower weight are registered first. The fundamental
+// blocks check whether the driver works at all and never get skipped
+// in FailFast mode.
+const (
+	weightFundamental = 0
+	weightDefault     = 1
+)
+
 var tests []test
 
 // DescribeSanity must be used instead of the usual Ginkgo Describe to
@@ -32,26 +52,222 @@ var tests []test
 // will be called multiple times with the right context (when
 // setting up a Ginkgo suite or a testing.T test, with the right
 // configuration).
+//
+// It is also the way for drivers to add their own tests, which then
+// share the TestContext, and thus connections, secrets and cleanup,
+// with the built-in tests. The block must be registered before Test
+// or GinkgoTest are called.
 func DescribeSanity(text string, body func(*TestContext)) bool {
-	tests = append(tests, test{text, body})
+	tests = append(tests, test{text, weightDefault, body, false})
+	return true
+}
+
+// describeParameterized is like DescribeSanity for blocks which
+// provision volumes with TestVolumeParameters and therefore run once
+// per parameter set.
+func describeParameterized(text string, body func(*TestContext)) bool {
+	tests = append(tests, test{text, weightDefault, body, true})
 	return true
 }
 
+// describeFundamental is like DescribeSanity for blocks which must
+// run before all others.
+func describeFundamental(text string, body func(*TestContext)) bool {
+	tests = append(tests, test{text, weightFundamental, body, false})
+	return true
+}
+
+// SpecID adds a stable identifier to the text of a spec. The
+// identifier has the form <area>/<slug> and must not change when the
+// text gets modified, so waiver files, -ginkgo.focus/skip filters for
+// rerunning failed tests and dashboards can refer to it instead of the
+// text. It is part of the spec text and thus of all reports, and
+// available as SpecResult.ID.
+//
+// Specs which run once per volume parameter set get the parameters
+// appended to the identifier as @<key>=<value>,..., so that each
+// run has its own identifier.
+//
+// Groups assigned to the identifier, with or without the
+// parameters, in TestConfig.SpecGroupsFile are appended as
+// [group:<name>].
+func SpecID(id, text string) string {
+	fullID := id + specIDSuffix
+	text = fmt.Sprintf("%s [id:%s]", text, fullID)
+	groups := specGroups[id]
+	if fullID != id {
+		groups = append(append([]string(nil), groups...), specGroups[fullID]...)
+	}
+	for _, group := range groups {
+		text += fmt.Sprintf(" [group:%s]", group)
+	}
+	return text
+}
+
+// baseSpecID strips the parameters added by SpecID from an
+// identifier.
+func baseSpecID(id string) string {
+	if i := strings.Index(id, "@"); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+var (
+	specIDRE    = regexp.MustCompile(`\s*\[id:([^\]]+)\]`)
+	specGroupRE = regexp.MustCompile(`\s*\[group:([^\]]+)\]`)
+)
+
+// specGroups maps spec identifiers to the groups from
+// TestConfig.SpecGroupsFile. It is set while registering the tests.
+var specGroups map[string][]string
+
+// specIDSuffix is added by SpecID to the identifiers of the specs
+// which are currently being registered for a parameter set.
+var specIDSuffix string
+
+// parseSpecID extracts the identifier added by SpecID and returns it together
+// with the remaining text.
+func parseSpecID(text string) (id, remaining string) {
+	match := specIDRE.FindStringSubmatchIndex(text)
+	if match == nil {
+		return "", text
+	}
+	return text[match[2]:match[3]], text[:match[0]] + text[match[1]:]
+}
+
+// parseSpecGroups extracts the groups added by SpecID and returns
+// them together with the remaining text.
+func parseSpecGroups(text string) (groups []string, remaining string) {
+	for _, match := range specGroupRE.FindAllStringSubmatch(text, -1) {
+		groups = append(groups, match[1])
+	}
+	return groups, specGroupRE.ReplaceAllString(text, "")
+}
+
+// loadSpecGroups reads a YAML file which maps spec identifiers to a
+// list of groups. Group names must not contain brackets or spaces.
+func loadSpecGroups(path string) (map[string][]string, error) {
+	groups := map[string][]string{}
+	if path == "" {
+		return groups, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %q: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("error unmarshaling yaml from %q: %v", path, err)
+	}
+	for id, names := range groups {
+		for _, name := range names {
+			if name == "" || strings.ContainsAny(name, "[] \t") {
+				return nil, fmt.Errorf("invalid group %q for spec %s in %q", name, id, path)
+			}
+		}
+	}
+	return groups, nil
+}
+
 // registerTestsInGinkgo invokes the actual Gingko Describe
 // for the tests registered earlier with DescribeSanity.
+//
+// Blocks are registered in the order of their weight. Ginkgo
+// preserves that order when the tests are embedded in another
+// container, but randomizes top-level containers, as in Test.
+// Therefore FailFast does not depend on the order and runs the
+// fundamental checks itself before the first other test.
 func registerTestsInGinkgo(sc *TestContext) {
-	for _, test := range tests {
+	var err error
+	specGroups, err = loadSpecGroups(sc.Config.SpecGroupsFile)
+	if err != nil {
+		panic(err)
+	}
+
+	ordered := append([]test(nil), tests...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].weight < ordered[j].weight
+	})
+	parameterSets, err := loadVolumeParameterSets(sc.Config.TestVolumeParametersFile)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, test := range ordered {
 		test := test
-		Describe(test.text, func() {
-			BeforeEach(func() {
-				sc.Setup()
-			})
+		if sc.Config.IdentityOnly && test.weight > weightFundamental {
+			continue
+		}
+		if !test.parameterized || len(parameterSets) == 0 {
+			registerTest(sc, test, nil)
+			continue
+		}
+		for _, parameters := range parameterSets {
+			registerTest(sc, test, parameters)
+		}
+	}
+}
+
+// registerTest wraps the body of a test in a Describe. Non-nil
+// parameters replace TestVolumeParameters for the specs in it and are
+// added to the text as [parameters:<key>=<value>,...] and to the
+// spec identifiers.
+func registerTest(sc *TestContext, test test, parameters map[string]string) {
+	text := test.text
+	if parameters != nil {
+		text = fmt.Sprintf("%s [parameters:%s]", text, parameterLabel(parameters))
+	}
+	Describe(text, func() {
+		BeforeEach(func() {
+			sc.inSpecBody = false
+			sc.limitSpecRetries()
+			sc.pauseBeforeArea(text)
+			sc.Setup()
+			if parameters != nil {
+				sc.Config.TestVolumeParameters = parameters
+			}
+			if test.weight > weightFundamental {
+				sc.skipIfFundamentalsFailed()
+			}
+		})
+
+		JustBeforeEach(func() {
+			sc.inSpecBody = true
+		})
+
+		if parameters != nil {
+			specIDSuffix = "@" + parameterLabel(parameters)
+			defer func() { specIDSuffix = "" }()
+		}
+		test.body(sc)
 
-			test.body(sc)
+		JustAfterEach(func() {
+			sc.inSpecBody = false
+			if sc.notRetried {
+				return
+			}
+			sc.collectFailureArtifacts()
+		})
 
-			AfterEach(func() {
-				sc.Teardown()
-			})
+		AfterEach(func() {
+			if sc.notRetried {
+				return
+			}
+			sc.Teardown()
 		})
+	})
+}
+
+// parameterLabel returns the sorted parameters as key=value pairs,
+// "default" if there are none.
+func parameterLabel(parameters map[string]string) string {
+	if len(parameters) == 0 {
+		return "default"
+	}
+	pairs := make([]string, 0, len(parameters))
+	for key, value := range parameters {
+		pairs = append(pairs, key+"="+value)
 	}
+	sort.Strings(pairs)
+	return strings.NewReplacer("[", "(", "]", ")").Replace(strings.Join(pairs, ","))
 }
@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// timeoutInterceptor applies TestConfig.RPCTimeout to calls without a
+// deadline and cancels all calls when TestConfig.Context is done.
+func (sc *TestContext) timeoutInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var cancel context.CancelFunc
+	if _, ok := ctx.Deadline(); !ok && sc.Config.RPCTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, sc.Config.RPCTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	if parent := sc.Config.Context; parent != nil {
+		if parent.Err() != nil {
+			return parent.Err()
+		}
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-parent.Done():
+				cancel()
+			case <-stop:
+			}
+		}()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
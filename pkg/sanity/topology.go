@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Limits for topology keys and values. They are used as labels in
+// Kubernetes and therefore must follow the rules for label keys and
+// values.
+const (
+	maxTopologyPrefixLength = 253
+	maxTopologyNameLength   = 63
+	maxTopologyValueLength  = 63
+)
+
+var (
+	topologyPrefixRE = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)+$`)
+	topologyNameRE   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+)
+
+// validateTopologyKey checks that the key has a domain prefix, like
+// topology.example.com/zone, and is a valid label key.
+func validateTopologyKey(key string) error {
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("topology key %q must consist of a domain prefix and a name separated by a slash", key)
+	}
+	prefix, name := parts[0], parts[1]
+	if len(prefix) > maxTopologyPrefixLength {
+		return fmt.Errorf("prefix of topology key %q is longer than %d characters", key, maxTopologyPrefixLength)
+	}
+	if !topologyPrefixRE.MatchString(prefix) {
+		return fmt.Errorf("prefix of topology key %q is not a DNS domain like topology.example.com", key)
+	}
+	if len(name) > maxTopologyNameLength {
+		return fmt.Errorf("name of topology key %q is longer than %d characters", key, maxTopologyNameLength)
+	}
+	if !topologyNameRE.MatchString(name) {
+		return fmt.Errorf("name of topology key %q must consist of alphanumeric characters, '-', '_' or '.' and start and end with an alphanumeric character", key)
+	}
+	return nil
+}
+
+// validateTopologyValue checks that the value is a valid label value.
+func validateTopologyValue(key, value string) error {
+	if len(value) > maxTopologyValueLength {
+		return fmt.Errorf("value %q of topology key %q is longer than %d characters", value, key, maxTopologyValueLength)
+	}
+	if value != "" && !topologyNameRE.MatchString(value) {
+		return fmt.Errorf("value %q of topology key %q must consist of alphanumeric characters, '-', '_' or '.' and start and end with an alphanumeric character", value, key)
+	}
+	return nil
+}
+
+// validateTopology checks all keys and values of the segments.
+func validateTopology(topology *csi.Topology) error {
+	for key, value := range topology.GetSegments() {
+		if err := validateTopologyKey(key); err != nil {
+			return err
+		}
+		if err := validateTopologyValue(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ = DescribeSanity("Topology", func(sc *TestContext) {
+	var r *Resources
+
+	BeforeEach(func() {
+		r = &Resources{
+			Context:          sc,
+			ControllerClient: csi.NewControllerClient(sc.ControllerConn),
+			NodeClient:       csi.NewNodeClient(sc.Conn),
+		}
+
+		if !isPluginCapabilitySupported(csi.NewIdentityClient(sc.Conn), csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS) {
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS), "volume accessibility constraints not supported")
+		}
+	})
+
+	AfterEach(func() {
+		r.Cleanup()
+	})
+
+	It(SpecID("topology/node-keys", "should return valid topology keys in NodeGetInfo"), func() {
+		nid, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nid).NotTo(BeNil())
+		Expect(nid.GetAccessibleTopology().GetSegments()).NotTo(BeEmpty(), "node reports no accessible topology")
+		Expect(validateTopology(nid.GetAccessibleTopology())).To(Succeed())
+	})
+
+	It(SpecID("topology/volume-keys", "should return the topology keys of the node in CreateVolume"), func() {
+		if !isPluginCapabilitySupported(csi.NewIdentityClient(sc.Conn), csi.PluginCapability_Service_CONTROLLER_SERVICE) {
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+		}
+		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME) {
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME), "CreateVolume not supported")
+		}
+
+		nid, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nid).NotTo(BeNil())
+		nodeSegments := nid.GetAccessibleTopology().GetSegments()
+
+		By("creating a volume")
+		vol := r.MustCreateVolume(context.Background(), MakeCreateVolumeReq(sc, UniqueString("sanity-topology-keys")))
+
+		for _, topology := range vol.GetVolume().GetAccessibleTopology() {
+			Expect(validateTopology(topology)).To(Succeed())
+			for key := range topology.GetSegments() {
+				Expect(nodeSegments).To(HaveKey(key), "topology key %q of the volume is not used by NodeGetInfo", key)
+			}
+		}
+	})
+
+	It(SpecID("topology/node-in-volume", "should return a volume topology which contains the node when requested"), func() {
+		if !isPluginCapabilitySupported(csi.NewIdentityClient(sc.Conn), csi.PluginCapability_Service_CONTROLLER_SERVICE) {
+			skipUnsupported(sc, PluginCapability(csi.PluginCapability_Service_CONTROLLER_SERVICE), "Controller Service not provided: CreateVolume not supported")
+		}
+		if !isControllerCapabilitySupported(r, csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME) {
+			skipUnsupported(sc, ControllerCapability(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME), "CreateVolume not supported")
+		}
+
+		nid, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nid).NotTo(BeNil())
+		nodeTopology := nid.GetAccessibleTopology()
+
+		By("getting the node info again")
+		nid2, err := r.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nid2.GetAccessibleTopology().GetSegments()).To(Equal(nodeTopology.GetSegments()), "NodeGetInfo returned a different topology when called again")
+
+		By("creating a volume which must be accessible from the node")
+		req := MakeCreateVolumeReq(sc, UniqueString("sanity-topology-node-in-volume"))
+		req.AccessibilityRequirements = &csi.TopologyRequirement{
+			Requisite: []*csi.Topology{nodeTopology},
+			Preferred: []*csi.Topology{nodeTopology},
+		}
+		vol := r.MustCreateVolume(context.Background(), req)
+
+		volumeTopologies := vol.GetVolume().GetAccessibleTopology()
+		if len(volumeTopologies) == 0 {
+			// The volume is accessible everywhere.
+			return
+		}
+		for _, topology := range volumeTopologies {
+			for key := range nodeTopology.GetSegments() {
+				Expect(topology.GetSegments()).To(HaveKey(key), "topology key %q of the node is missing in the volume topology %v", key, topology.GetSegments())
+			}
+		}
+		Expect(volumeTopologies).To(ContainElement(WithTransform(func(t *csi.Topology) map[string]string {
+			return t.GetSegments()
+		}, Equal(nodeTopology.GetSegments()))), "volume topology does not include the topology of the node")
+	})
+})
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"sync"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"google.golang.org/grpc"
+)
+
+// SpecTracer gets notified about each spec, for example to start an
+// OpenTelemetry span per spec. Together with an OpenTelemetry
+// interceptor in TestConfig.UnaryInterceptors, like the one from
+// go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc,
+// the calls of a spec then become child spans of the spec span and
+// the trace context gets propagated to the driver.
+type SpecTracer interface {
+	// StartSpec is called before a spec runs. The values of the
+	// returned context are visible to the interceptors of all
+	// calls made by the spec.
+	StartSpec(ctx context.Context, name string) context.Context
+	// EndSpec is called with the context returned by StartSpec
+	// after the spec completed.
+	EndSpec(ctx context.Context, state SpecState, failure string)
+}
+
+// specTracing holds the context returned by SpecTracer.StartSpec for
+// the running spec.
+type specTracing struct {
+	mutex sync.Mutex
+	ctx   context.Context
+}
+
+func (st *specTracing) set(ctx context.Context) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.ctx = ctx
+}
+
+func (st *specTracing) get() context.Context {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.ctx
+}
+
+// specValuesContext is the context of a call, with the values of the
+// spec context as fallback. Deadline and cancellation remain those of
+// the call.
+type specValuesContext struct {
+	context.Context
+	spec context.Context
+}
+
+func (ctx specValuesContext) Value(key interface{}) interface{} {
+	if value := ctx.Context.Value(key); value != nil {
+		return value
+	}
+	return ctx.spec.Value(key)
+}
+
+// specContextInterceptor makes the values of the spec context
+// visible to TestConfig.UnaryInterceptors.
+func (sc *TestContext) specContextInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if spec := sc.tracing.get(); spec != nil {
+		ctx = specValuesContext{Context: ctx, spec: spec}
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// tracingReporter calls TestConfig.SpecTracer for each spec.
+type tracingReporter struct {
+	sc *TestContext
+}
+
+func (tr *tracingReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (tr *tracingReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (tr *tracingReporter) SpecWillRun(specSummary *types.SpecSummary) {
+	if specSummary.Skipped() || specSummary.Pending() {
+		return
+	}
+	ctx := context.Background()
+	if tr.sc.Config.Context != nil {
+		ctx = tr.sc.Config.Context
+	}
+	tr.sc.tracing.set(tr.sc.Config.SpecTracer.StartSpec(ctx, specName(specSummary)))
+}
+
+func (tr *tracingReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+	ctx := tr.sc.tracing.get()
+	if ctx == nil {
+		return
+	}
+	tr.sc.tracing.set(nil)
+	state := SpecPassed
+	switch {
+	case specSummary.HasFailureState():
+		state = SpecFailed
+	case specSummary.Skipped():
+		state = SpecSkipped
+	}
+	tr.sc.Config.SpecTracer.EndSpec(ctx, state, specSummary.Failure.Message)
+}
+
+func (tr *tracingReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {}
+
+func (tr *tracingReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {}
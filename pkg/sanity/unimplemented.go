@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// UnimplementedPolicy determines what happens when the driver
+// returns Unimplemented for a certain RPC.
+type UnimplementedPolicy string
+
+const (
+	// UnimplementedDefault leaves it to the test, which usually
+	// fails unless the RPC is optional.
+	UnimplementedDefault UnimplementedPolicy = ""
+	// UnimplementedSkip skips the test which made the call.
+	UnimplementedSkip UnimplementedPolicy = "skip"
+	// UnimplementedFail fails the test which made the call, even
+	// if the test would accept Unimplemented.
+	UnimplementedFail UnimplementedPolicy = "fail"
+)
+
+// unimplementedInterceptor applies TestConfig.UnimplementedPolicies
+// to the calls made by the It of a test. Calls made while setting up
+// or cleaning up just return the error, because skipping or failing
+// there would abort the cleanup. It must be invoked on the goroutine
+// of the test, which is the case for all calls made by the sanity
+// package.
+func (sc *TestContext) unimplementedInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if status.Code(err) != codes.Unimplemented || !sc.inSpecBody {
+		return err
+	}
+
+	name := path.Base(method)
+	switch sc.Config.UnimplementedPolicies[name] {
+	case UnimplementedSkip:
+		Skip(fmt.Sprintf("%s not implemented (Config.UnimplementedPolicies)", name))
+	case UnimplementedFail:
+		Fail(fmt.Sprintf("%s must be implemented (Config.UnimplementedPolicies): %v", name, err))
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnimplementedInterceptor(t *testing.T) {
+	unimplemented := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unimplemented, "not yet")
+	}
+	for name, tc := range map[string]struct {
+		policy     UnimplementedPolicy
+		inSpecBody bool
+		aborts     bool
+	}{
+		"default":         {UnimplementedDefault, true, false},
+		"skip":            {UnimplementedSkip, true, true},
+		"fail":            {UnimplementedFail, true, true},
+		"skip in cleanup": {UnimplementedSkip, false, false},
+		"fail in cleanup": {UnimplementedFail, false, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.UnimplementedPolicies = map[string]UnimplementedPolicy{"NodeUnstageVolume": tc.policy}
+			sc := NewTestContext(&config)
+			sc.inSpecBody = tc.inSpecBody
+
+			aborted := true
+			var err error
+			func() {
+				// Skip and Fail panic to abort the spec.
+				defer func() {
+					recover()
+				}()
+				err = sc.unimplementedInterceptor(context.Background(), "/csi.v1.Node/NodeUnstageVolume", nil, nil, nil, unimplemented)
+				aborted = false
+			}()
+			if aborted != tc.aborts {
+				t.Fatalf("expected aborted %v, got %v", tc.aborts, aborted)
+			}
+			if !aborted && status.Code(err) != codes.Unimplemented {
+				t.Errorf("expected the Unimplemented error, got %v", err)
+			}
+		})
+	}
+}
@@ -18,8 +18,6 @@ package sanity
 
 import (
 	"fmt"
-
-	"github.com/google/uuid"
 )
 
 // IDGenerator generates valid and invalid Volume and Node IDs to be used in
@@ -44,13 +42,23 @@ type IDGenerator interface {
 	GenerateInvalidNodeID() string
 }
 
+// SnapshotIDGenerator can be implemented in addition to IDGenerator
+// to enable tests which need snapshot IDs. It is a separate interface
+// to keep existing IDGenerator implementations working.
+type SnapshotIDGenerator interface {
+	// GenerateUniqueValidSnapshotID must generate a unique Snapshot ID
+	// that the CSI Driver considers in valid form
+	GenerateUniqueValidSnapshotID() string
+}
+
 var _ IDGenerator = &DefaultIDGenerator{}
+var _ SnapshotIDGenerator = &DefaultIDGenerator{}
 
 type DefaultIDGenerator struct {
 }
 
 func (d DefaultIDGenerator) GenerateUniqueValidVolumeID() string {
-	return fmt.Sprintf("fake-vol-id-%s", uuid.New().String()[:10])
+	return fmt.Sprintf("fake-vol-id-%s", newUUID()[:10])
 }
 
 func (d DefaultIDGenerator) GenerateInvalidVolumeID() string {
@@ -58,9 +66,23 @@ func (d DefaultIDGenerator) GenerateInvalidVolumeID() string {
 }
 
 func (d DefaultIDGenerator) GenerateUniqueValidNodeID() string {
-	return fmt.Sprintf("fake-node-id-%s", uuid.New().String()[:10])
+	return fmt.Sprintf("fake-node-id-%s", newUUID()[:10])
 }
 
 func (d DefaultIDGenerator) GenerateInvalidNodeID() string {
 	return "fake-node-id"
 }
+
+func (d DefaultIDGenerator) GenerateUniqueValidSnapshotID() string {
+	return fmt.Sprintf("fake-snapshot-id-%s", newUUID()[:10])
+}
+
+// nonExistentSnapshotID returns a snapshot ID which does not refer to
+// an existing snapshot, generated by Config.IDGen if it implements
+// SnapshotIDGenerator so that it has a valid form.
+func nonExistentSnapshotID(sc *TestContext) string {
+	if idGen, ok := sc.Config.IDGen.(SnapshotIDGenerator); ok {
+		return idGen.GenerateUniqueValidSnapshotID()
+	}
+	return "non-existing-snapshot-id"
+}
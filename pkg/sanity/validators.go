@@ -0,0 +1,52 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sanity
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"google.golang.org/grpc"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// ResponseValidator checks driver-specific invariants of a
+// successful response. req and resp are the request and response
+// messages of the RPC, for example *csi.CreateVolumeRequest and
+// *csi.CreateVolumeResponse. An error fails the test which made the
+// call.
+type ResponseValidator func(req, resp interface{}) error
+
+// validationInterceptor applies TestConfig.ResponseValidators. Like
+// unimplementedInterceptor, it must be invoked on the goroutine of
+// the test.
+func (sc *TestContext) validationInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		return err
+	}
+
+	name := path.Base(method)
+	if validate := sc.Config.ResponseValidators[name]; validate != nil {
+		if err := validate(req, reply); err != nil {
+			Fail(fmt.Sprintf("invalid %s response (Config.ResponseValidators): %v", name, err))
+		}
+	}
+	return nil
+}
@@ -17,15 +17,30 @@ package test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
 	mock_driver "github.com/kubernetes-csi/csi-test/v4/driver"
 	mock_utils "github.com/kubernetes-csi/csi-test/v4/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 func TestPluginInfoResponse(t *testing.T) {
@@ -186,3 +201,197 @@ func TestGRPCAttach(t *testing.T) {
 		t.Errorf("Invalid publish info: %v", info)
 	}
 }
+
+func TestGRPCFaults(t *testing.T) {
+
+	// Setup mock
+	m := gomock.NewController(&mock_utils.SafeGoroutineTester{})
+	defer m.Finish()
+	driver := mock_driver.NewMockIdentityServer(m)
+
+	// Only the call after the injected faults reaches the server
+	in := &csi.ProbeRequest{}
+	driver.EXPECT().Probe(gomock.Any(), pbMatch(in)).Return(&csi.ProbeResponse{}, nil).Times(1)
+
+	server := mock_driver.NewMockCSIDriver(&mock_driver.MockCSIDriverServers{
+		Identity: driver,
+	})
+	conn, err := server.Nexus()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	defer server.Close()
+
+	fault, err := mock_driver.ParseFault("Probe:ResourceExhausted:2")
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	server.Faults().Add(fault)
+
+	c := csi.NewIdentityClient(conn)
+	for i := 0; i < 2; i++ {
+		_, err := c.Probe(context.Background(), in)
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("call #%d: expected ResourceExhausted, got: %v", i+1, err)
+		}
+	}
+	if _, err := c.Probe(context.Background(), in); err != nil {
+		t.Errorf("Error: %s", err.Error())
+	}
+	if faults := server.Faults().List(); len(faults) != 0 {
+		t.Errorf("Faults left: %v", faults)
+	}
+}
+
+func TestGRPCLatency(t *testing.T) {
+
+	// Setup mock
+	m := gomock.NewController(&mock_utils.SafeGoroutineTester{})
+	defer m.Finish()
+	driver := mock_driver.NewMockIdentityServer(m)
+
+	in := &csi.ProbeRequest{}
+	driver.EXPECT().Probe(gomock.Any(), pbMatch(in)).Return(&csi.ProbeResponse{}, nil).Times(1)
+
+	server := mock_driver.NewMockCSIDriver(&mock_driver.MockCSIDriverServers{
+		Identity: driver,
+	})
+	conn, err := server.Nexus()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	defer server.Close()
+
+	delay := 200 * time.Millisecond
+	server.Faults().Add(mock_driver.Fault{Method: "Probe", Code: codes.OK, Count: 2, Delay: delay})
+
+	// The first call times out before the delay is over and
+	// never reaches the server
+	c := csi.NewIdentityClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), delay/10)
+	defer cancel()
+	if _, err := c.Probe(ctx, in); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := c.Probe(context.Background(), in); err != nil {
+		t.Errorf("Error: %s", err.Error())
+	}
+	if duration := time.Since(start); duration < delay {
+		t.Errorf("call took %s, expected at least %s", duration, delay)
+	}
+}
+
+// selfSignedCert returns a certificate for the loopback address and
+// a pool which trusts it.
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mock"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestGRPCTLS(t *testing.T) {
+
+	// Setup mock
+	m := gomock.NewController(&mock_utils.SafeGoroutineTester{})
+	defer m.Finish()
+	driver := mock_driver.NewMockIdentityServer(m)
+
+	in := &csi.ProbeRequest{}
+	driver.EXPECT().Probe(gomock.Any(), pbMatch(in)).Return(&csi.ProbeResponse{}, nil).Times(1)
+
+	cert, pool := selfSignedCert(t)
+	server := mock_driver.NewMockCSIDriver(&mock_driver.MockCSIDriverServers{
+		Identity: driver,
+	})
+	server.SetServerOptions(grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	conn, err := server.NexusWithDialOptions(grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "")))
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	defer server.Close()
+
+	c := csi.NewIdentityClient(conn)
+	if _, err := c.Probe(context.Background(), in); err != nil {
+		t.Errorf("Error: %s", err.Error())
+	}
+}
+
+func TestGRPCScriptedFaults(t *testing.T) {
+
+	// Setup mock
+	m := gomock.NewController(&mock_utils.SafeGoroutineTester{})
+	defer m.Finish()
+	driver := mock_driver.NewMockIdentityServer(m)
+
+	in := &csi.ProbeRequest{}
+	driver.EXPECT().Probe(gomock.Any(), pbMatch(in)).Return(&csi.ProbeResponse{}, nil).Times(3)
+
+	script := filepath.Join(t.TempDir(), "faults.yaml")
+	if err := ioutil.WriteFile(script, []byte(`
+- method: Probe
+  code: Internal
+  message: scripted
+  after: 2
+  count: 1
+- method: GetPluginInfo
+  delay: 5s
+`), 0644); err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	faults, err := mock_driver.LoadFaults(script)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	if len(faults) != 2 || faults[1].Code != codes.OK || faults[1].Delay != 5*time.Second {
+		t.Fatalf("Unexpected faults: %+v", faults)
+	}
+
+	server := mock_driver.NewMockCSIDriver(&mock_driver.MockCSIDriverServers{
+		Identity: driver,
+	})
+	conn, err := server.Nexus()
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	defer server.Close()
+	server.Faults().Add(faults[0])
+
+	// Only the third call fails
+	c := csi.NewIdentityClient(conn)
+	for i := 1; i <= 4; i++ {
+		_, err := c.Probe(context.Background(), in)
+		if i == 3 {
+			if status.Code(err) != codes.Internal {
+				t.Errorf("call #%d: expected Internal, got: %v", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("call #%d: Error: %s", i, err.Error())
+		}
+	}
+}
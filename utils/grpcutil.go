@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"runtime"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
@@ -29,12 +31,26 @@ import (
 
 // Connect address by grpc
 func Connect(address string, dialOptions ...grpc.DialOption) (*grpc.ClientConn, error) {
-	u, err := url.Parse(address)
-	if err == nil && (!u.IsAbs() || u.Scheme == "unix") {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return ConnectContext(ctx, address, dialOptions...)
+}
+
+// ConnectContext is like Connect, but waits for the connection to
+// become ready only until the context is done.
+func ConnectContext(ctx context.Context, address string, dialOptions ...grpc.DialOption) (*grpc.ClientConn, error) {
+	switch network, path := SocketPath(address); network {
+	case "unix":
 		dialOptions = append(dialOptions,
 			grpc.WithDialer(
 				func(addr string, timeout time.Duration) (net.Conn, error) {
-					return net.DialTimeout("unix", u.Path, timeout)
+					return net.DialTimeout("unix", path, timeout)
+				}))
+	case "npipe":
+		dialOptions = append(dialOptions,
+			grpc.WithDialer(
+				func(addr string, timeout time.Duration) (net.Conn, error) {
+					return dialPipe(path, timeout)
 				}))
 	}
 
@@ -43,8 +59,6 @@ func Connect(address string, dialOptions ...grpc.DialOption) (*grpc.ClientConn,
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
 	for {
 		if !conn.WaitForStateChange(ctx, conn.GetState()) {
 			return conn, fmt.Errorf("Connection timed out")
@@ -54,3 +68,32 @@ func Connect(address string, dialOptions ...grpc.DialOption) (*grpc.ClientConn,
 		}
 	}
 }
+
+// SocketPath returns the network ("unix" or "npipe") and the path for
+// endpoints which are local sockets: relative URLs and unix:// URLs
+// are unix domain sockets, npipe:// URLs like npipe:////./pipe/csi
+// are Windows named pipes. The network is empty for all other
+// endpoints, which are handled by gRPC itself.
+func SocketPath(address string) (network, path string) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", ""
+	}
+	switch {
+	case !u.IsAbs() || u.Scheme == "unix":
+		path = u.Path
+		if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+			// unix:///C:/csi/csi.sock
+			path = path[1:]
+		}
+		return "unix", path
+	case u.Scheme == "npipe":
+		path = u.Path
+		if u.Host != "" {
+			path = "//" + u.Host + path
+		}
+		return "npipe", strings.ReplaceAll(path, "/", `\`)
+	default:
+		return "", ""
+	}
+}